@@ -6,13 +6,16 @@ import (
 	"embed"
 	"encoding/hex"
 	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/YoungY620/memo/internal"
 
-	agent "github.com/MoonshotAI/kimi-agent-sdk/go"
 	"github.com/MoonshotAI/kimi-agent-sdk/go/wire"
 )
 
@@ -23,9 +26,14 @@ var promptFS embed.FS
 // This distinguishes memo sessions from user interactive sessions.
 const sessionPrefix = "memo-"
 
-// maxFilesPerBatch is the threshold for splitting files into batches.
-// When file count exceeds this, files are split by directory.
-const maxFilesPerBatch = 100
+// DefaultTokenBudget is the estimated number of source tokens per batch
+// when no budget is configured for the model.
+const DefaultTokenBudget = 60000
+
+const (
+	bytesPerToken     = 4  // rough average for source code
+	fileTokenOverhead = 20 // path listing and bookkeeping per file
+)
 
 func loadPrompt(name string) string {
 	data, err := promptFS.ReadFile("prompts/" + name + ".md")
@@ -38,16 +46,39 @@ func loadPrompt(name string) string {
 
 // AgentConfig holds the agent configuration
 type AgentConfig struct {
-	APIKey string
-	Model  string
+	Backend   string // kimi (default), openai or scripted
+	APIKey    string
+	Model     string
+	BaseURL   string // endpoint for the openai backend
+	ScriptDir string // turn files for the scripted backend
 }
 
 // Analyser performs code analysis using AI
 type Analyser struct {
-	agentCfg  AgentConfig
-	indexDir  string
-	workDir   string
-	sessionID string
+	agentCfg      AgentConfig
+	backend       Backend
+	indexDir      string
+	workDir       string
+	sourceDir     string // project files issue locations point at; workDir except in replays
+	sessionID     string
+	workers       int                // concurrent batches; <= 1 analyses batches in order in one session
+	manifest      *Manifest          // updated after each successful batch, if set
+	tokenBudget   int                // estimated source tokens per batch; DefaultTokenBudget if unset
+	maxDiffTokens int                // largest diff sent for a modified file; DefaultMaxDiffTokens if unset, < 0 disables diffs
+	batchStrategy string             // BatchByDirectory (default) or BatchByDeps
+	queue         *Queue             // batch plan of the run in progress, if set
+	snapshots     *SnapshotStore     // history of the index after each successful run, if set
+	background    bool               // rebuilding a copy of the index: status.json is left alone
+	prompts       map[string]*Prompt // prompt templates by name; built-in if unset
+	language      string             // language of the index content, for prompt templates
+	indexFiles    *IndexFiles        // files the index consists of
+
+	renamesMu sync.Mutex
+	renames   map[string]string // renames noted by the watcher, new -> old (relative paths)
+
+	record bool        // write a recording of every Analyse call
+	rec    *recorder   // recording of the run in progress, if any
+	usage  *usageMeter // token usage of the run in progress
 }
 
 // generateSessionID creates a deterministic session ID based on work directory
@@ -71,127 +102,381 @@ func toRelativePaths(files []string, workDir string) []string {
 	return rel
 }
 
-// splitIntoBatches splits files into batches by directory when count > threshold
-func splitIntoBatches(files []string, threshold int) [][]string {
-	if len(files) <= threshold {
+// splitIntoBatches splits files into batches whose total weight stays within
+// budget. Files are grouped by directory: a directory that fits is kept whole,
+// one that does not is split by subdirectory, and the groups are then packed
+// in path order. A single file heavier than budget gets a batch of its own.
+func splitIntoBatches(files []string, budget int, weight func(string) int) [][]string {
+	if totalWeight(files, weight) <= budget {
 		return [][]string{files}
 	}
 
-	// Group by first path component (top-level dir)
+	return packGroups(groupFiles(files, budget, weight), budget, weight)
+}
+
+// packGroups fills batches with whole groups, in order, up to budget
+func packGroups(groups [][]string, budget int, weight func(string) int) [][]string {
+	var batches [][]string
+	var current []string
+	currentWeight := 0
+	for _, group := range groups {
+		w := totalWeight(group, weight)
+		if len(current) > 0 && currentWeight+w > budget {
+			batches = append(batches, current)
+			current, currentWeight = nil, 0
+		}
+		current = append(current, group...)
+		currentWeight += w
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// groupFiles splits files by top-level directory, recursing into directories
+// heavier than budget. Groups are returned in path order.
+func groupFiles(files []string, budget int, weight func(string) int) [][]string {
 	groups := make(map[string][]string)
 	for _, f := range files {
 		parts := strings.SplitN(f, string(filepath.Separator), 2)
-		dir := parts[0]
-		groups[dir] = append(groups[dir], f)
+		groups[parts[0]] = append(groups[parts[0]], f)
 	}
-
-	var batches [][]string
-	for dir, dirFiles := range groups {
-		if len(dirFiles) <= threshold {
-			batches = append(batches, dirFiles)
-		} else {
-			// Strip prefix, recurse, restore prefix
-			var sub []string
-			for _, f := range dirFiles {
-				if idx := strings.Index(f, string(filepath.Separator)); idx >= 0 {
-					sub = append(sub, f[idx+1:])
-				} else {
-					sub = append(sub, f)
-				}
-			}
-			for _, b := range splitIntoBatches(sub, threshold) {
-				restored := make([]string, len(b))
-				for i, f := range b {
-					restored[i] = filepath.Join(dir, f)
-				}
-				batches = append(batches, restored)
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var result [][]string
+	for _, dir := range dirs {
+		dirFiles := groups[dir]
+		if len(dirFiles) == 1 || totalWeight(dirFiles, weight) <= budget {
+			result = append(result, dirFiles)
+			continue
+		}
+		// Strip prefix, recurse, restore prefix
+		sub := make([]string, len(dirFiles))
+		for i, f := range dirFiles {
+			sub[i] = strings.TrimPrefix(f, dir+string(filepath.Separator))
+		}
+		subWeight := func(f string) int { return weight(filepath.Join(dir, f)) }
+		for _, g := range groupFiles(sub, budget, subWeight) {
+			restored := make([]string, len(g))
+			for i, f := range g {
+				restored[i] = filepath.Join(dir, f)
 			}
+			result = append(result, restored)
 		}
 	}
-	return batches
+	return result
+}
+
+func totalWeight(files []string, weight func(string) int) int {
+	total := 0
+	for _, f := range files {
+		total += weight(f)
+	}
+	return total
+}
+
+// scratchDir returns the directory under .memo for a temporary copy of the
+// index (staging, fragments). Analysers of another index, such as the rebuild
+// copy, get their own so they never share a working directory.
+func (a *Analyser) scratchDir(name string) string {
+	if base := filepath.Base(a.indexDir); base != "index" {
+		name += "-" + strings.TrimPrefix(base, "index-")
+	}
+	return filepath.Join(filepath.Dir(a.indexDir), name)
 }
 
-// NewAnalyser creates a new Analyser instance
-func NewAnalyser(agentCfg AgentConfig, workDir string) *Analyser {
+// relPath returns path relative to the work directory, for prompts
+func (a *Analyser) relPath(path string) string {
+	rel, err := filepath.Rel(a.workDir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// estimateTokens estimates the prompt cost of a file (relative to workDir)
+// from its size; deleted files only cost their path
+func (a *Analyser) estimateTokens(relFile string) int {
+	info, err := os.Stat(filepath.Join(a.workDir, relFile))
+	if err != nil || info.IsDir() {
+		return fileTokenOverhead
+	}
+	return int(info.Size())/bytesPerToken + fileTokenOverhead
+}
+
+// budget returns the token budget per batch
+func (a *Analyser) budget() int {
+	if a.tokenBudget > 0 {
+		return a.tokenBudget
+	}
+	return DefaultTokenBudget
+}
+
+// NewAnalyser creates a new Analyser instance using the backend selected in agentCfg
+func NewAnalyser(agentCfg AgentConfig, workDir string) (*Analyser, error) {
+	backend, err := NewBackend(agentCfg)
+	if err != nil {
+		return nil, err
+	}
+
 	sessionID := generateSessionID(workDir)
 	internal.LogInfo("Using session ID: %s for workDir: %s", sessionID, workDir)
 
 	return &Analyser{
-		agentCfg:  agentCfg,
-		indexDir:  filepath.Join(workDir, ".memo", "index"),
-		workDir:   workDir,
-		sessionID: sessionID,
+		agentCfg:   agentCfg,
+		backend:    backend,
+		indexDir:   filepath.Join(workDir, ".memo", "index"),
+		workDir:    workDir,
+		sourceDir:  workDir,
+		sessionID:  sessionID,
+		indexFiles: builtinIndexFiles,
+	}, nil
+}
+
+// SetWorkers sets how many batches are analysed concurrently
+func (a *Analyser) SetWorkers(n int) {
+	a.workers = n
+}
+
+// SetTokenBudget sets the estimated source tokens per batch
+func (a *Analyser) SetTokenBudget(tokens int) {
+	a.tokenBudget = tokens
+}
+
+// SetMaxDiffTokens sets the largest diff sent instead of asking the agent to
+// reread a modified file; a negative value disables diffs
+func (a *Analyser) SetMaxDiffTokens(tokens int) {
+	a.maxDiffTokens = tokens
+}
+
+// SetBatchStrategy selects how files are grouped into batches
+func (a *Analyser) SetBatchStrategy(strategy string) error {
+	switch strategy {
+	case "", BatchByDirectory, BatchByDeps:
+		a.batchStrategy = strategy
+		return nil
+	default:
+		return fmt.Errorf("unknown batch strategy: %q (available: %s, %s)", strategy, BatchByDirectory, BatchByDeps)
 	}
 }
 
+// SetIndexFiles sets the files the index consists of, including the
+// project-defined ones
+func (a *Analyser) SetIndexFiles(files *IndexFiles) {
+	a.indexFiles = files
+}
+
+// SetQueue sets the queue persisting the batch plan, so interrupted runs can resume
+func (a *Analyser) SetQueue(q *Queue) {
+	a.queue = q
+}
+
+// SetSnapshots sets the store receiving a snapshot of the index after each successful run
+func (a *Analyser) SetSnapshots(s *SnapshotStore) {
+	a.snapshots = s
+}
+
+// SetManifest sets the manifest recording the files of each successful batch
+func (a *Analyser) SetManifest(m *Manifest) {
+	a.manifest = m
+}
+
+// SetRecording enables writing a recording of each analysis run to .memo/recordings
+func (a *Analyser) SetRecording(enabled bool) {
+	a.record = enabled
+}
+
 // Analyse performs analysis on the given changed files
 func (a *Analyser) Analyse(ctx context.Context, changedFiles []string) error {
 	// Convert to relative paths
 	relFiles := toRelativePaths(changedFiles, a.workDir)
 
-	// Split into batches if needed
-	batches := splitIntoBatches(relFiles, maxFilesPerBatch)
-	internal.LogInfo("Starting analysis for %d files in %d batch(es)", len(changedFiles), len(batches))
+	// Fold in files of batches left incomplete by an earlier run
+	if a.queue != nil {
+		relFiles = appendMissing(relFiles, slices.Concat(a.queue.Pending()...))
+	}
+
+	// Split into batches that fit the token budget
+	var batches [][]string
+	if a.batchStrategy == BatchByDeps {
+		batches = splitByDeps(relFiles, a.budget(), a.estimateTokens, importGraph(a.workDir, relFiles))
+	} else {
+		batches = splitIntoBatches(relFiles, a.budget(), a.estimateTokens)
+	}
+	batches = keepRenamesTogether(batches, a.findRenames(relFiles))
+	internal.LogInfo("Starting analysis for %d files in %d batch(es)", len(relFiles), len(batches))
+
+	if a.queue != nil {
+		if err := a.queue.Plan(batches); err != nil {
+			internal.LogError("Failed to save queue: %v", err)
+		}
+	}
+	return a.runBatches(ctx, batches)
+}
+
+// Resume analyses the batches left incomplete by an earlier run, keeping
+// their original plan. It does nothing if no queue is set or nothing is pending.
+func (a *Analyser) Resume(ctx context.Context) error {
+	if a.queue == nil {
+		return nil
+	}
+	pending := a.queue.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+	internal.LogInfo("Resuming %d of %d batch(es) from an interrupted run", len(pending), a.queue.Len())
+	return a.runBatches(ctx, pending)
+}
+
+// appendMissing appends the entries of extra not already in files
+func appendMissing(files, extra []string) []string {
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f] = true
+	}
+	for _, f := range extra {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	return files
+}
 
+// runBatches analyses the given batches, in order or with the worker pool
+func (a *Analyser) runBatches(ctx context.Context, batches [][]string) (err error) {
 	// Mark analysis in progress
 	memoDir := filepath.Dir(a.indexDir)
-	if err := SetStatus(memoDir, "analyzing"); err != nil {
-		internal.LogError("Failed to set status: %v", err)
+	if !a.background {
+		if err := SetStatus(memoDir, "analyzing"); err != nil {
+			internal.LogError("Failed to set status: %v", err)
+		}
+		defer func() {
+			if err := SetStatus(memoDir, "idle"); err != nil {
+				internal.LogError("Failed to clear status: %v", err)
+			}
+		}()
 	}
+
+	a.usage = newUsageMeter(memoDir, a.agentCfg, a.sessionID)
 	defer func() {
-		if err := SetStatus(memoDir, "idle"); err != nil {
-			internal.LogError("Failed to clear status: %v", err)
-		}
+		a.usage.finish(err)
+		a.usage = nil
 	}()
 
-	// Process each batch
-	for i, batch := range batches {
-		if err := a.analyseBatch(ctx, batch, i+1, len(batches)); err != nil {
-			return fmt.Errorf("batch %d/%d failed: %w", i+1, len(batches), err)
+	if a.record {
+		rec, recErr := newRecorder(memoDir, a.indexDir, a.workDir, a.agentCfg, a.sessionID, batches, a.workers, a.indexFiles.Custom())
+		if recErr != nil {
+			internal.LogError("Failed to start recording: %v", recErr)
+		} else {
+			a.rec = rec
+			defer func() {
+				rec.finish(err)
+				a.rec = nil
+			}()
 		}
 	}
 
-	return nil
+	// Batches committed before a failure stay in the index, so they are
+	// snapshotted either way
+	var committed [][]string
+	if a.workers > 1 && len(batches) > 1 {
+		committed, err = a.runParallel(ctx, batches)
+	} else {
+		// Process each batch
+		for i, batch := range batches {
+			read := HashFiles(a.workDir, batch)
+			if err = a.runStagedBatch(ctx, batch, read, i+1, len(batches)); err != nil {
+				err = fmt.Errorf("batch %d/%d failed: %w", i+1, len(batches), err)
+				break
+			}
+			a.batchDone(batch, read)
+			committed = append(committed, batch)
+		}
+	}
+
+	if len(committed) > 0 {
+		a.saveSnapshot(committed)
+	}
+	return err
+}
+
+// saveSnapshot adds the index produced by the committed batches of a run to
+// the history
+func (a *Analyser) saveSnapshot(batches [][]string) {
+	if a.snapshots == nil {
+		return
+	}
+	if _, err := a.snapshots.Save(a.indexDir, slices.Concat(batches...), ""); err != nil {
+		internal.LogError("Failed to save snapshot: %v", err)
+	}
 }
 
-func (a *Analyser) analyseBatch(ctx context.Context, files []string, batchNum, totalBatches int) error {
+// batchDone records a successfully analysed batch in the manifest and queue.
+// read holds the hashes of the files when the batch was built.
+func (a *Analyser) batchDone(files []string, read map[string]string) {
+	a.forgetRenames(files)
+	if a.manifest != nil {
+		a.manifest.UpdateRead(files, read)
+		if err := a.manifest.Save(); err != nil {
+			internal.LogError("Failed to save manifest: %v", err)
+		}
+	}
+	if a.queue != nil {
+		if err := a.queue.Complete(files); err != nil {
+			internal.LogError("Failed to save queue: %v", err)
+		}
+	}
+}
+
+// analyseBatch runs one batch in the given session against indexDir, a working
+// copy of the index (the staging directory, or a fragment for parallel batches)
+func (a *Analyser) analyseBatch(ctx context.Context, files []string, batchNum, totalBatches int, indexDir, sessionID string) (err error) {
 	internal.LogInfo("Processing batch %d/%d (%d files)", batchNum, totalBatches, len(files))
 
-	var session *agent.Session
-	var err error
+	var initialUsage, feedbackUsage TokenUsage
+	defer func() { a.usage.addBatch(batchNum, len(files), initialUsage, feedbackUsage, err) }()
+
+	var locationInfo string
+	if indexDir != a.indexDir {
+		locationInfo = fmt.Sprintf("\n\n## Index Location\n\nRead and write the index files in `%s` instead of `.memo/index`. This is a working copy: it is validated and then applied to `%s` when the batch succeeds.", a.relPath(indexDir), a.relPath(a.indexDir))
+	}
 
 	// Use local MCP config to prevent loading ~/.kimi/mcp.json
 	// (which may contain memo itself, causing infinite recursion)
-	mcpFile := filepath.Join(a.workDir, ".memo", "mcp.json")
-
-	// Use kimi defaults if agent config is not set
-	if a.agentCfg.APIKey != "" && a.agentCfg.Model != "" {
-		internal.LogDebug("Using configured model: %s", a.agentCfg.Model)
-		session, err = agent.NewSession(
-			agent.WithAPIKey(a.agentCfg.APIKey),
-			agent.WithModel(a.agentCfg.Model),
-			agent.WithWorkDir(a.workDir),
-			agent.WithAutoApprove(),
-			agent.WithMCPConfigFile(mcpFile),
-			agent.WithSession(a.sessionID),
-		)
-	} else {
-		internal.LogDebug("Using kimi default configuration")
-		session, err = agent.NewSession(
-			agent.WithWorkDir(a.workDir),
-			agent.WithAutoApprove(),
-			agent.WithMCPConfigFile(mcpFile),
-			agent.WithSession(a.sessionID),
-		)
-	}
+	session, err := a.backend.NewSession(SessionOptions{
+		SessionID:     sessionID,
+		WorkDir:       a.workDir,
+		IndexDir:      indexDir,
+		MCPConfigFile: filepath.Join(a.workDir, ".memo", "mcp.json"),
+		Batch:         batchNum,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
+	// The feedback loop only holds the batch to what it changed
+	before := readIndexFiles(indexDir)
+
+	// Deleted files are listed, but not handed out to read
+	changes := a.classify(files)
+	var readable []string
+	for _, c := range changes {
+		if c.Kind != ChangeDeleted {
+			readable = append(readable, c.Path)
+		}
+	}
+
 	// Build initial prompt
-	contextPrompt := loadPrompt("context")
-	analysePrompt := loadPrompt("analyse")
+	data := PromptData{Files: readable, Changes: changes, Batch: batchNum, TotalBatches: totalBatches, IndexDir: a.relPath(indexDir)}
+	contextPrompt := a.prompt("context", data) + a.indexFiles.prompt()
+	analysePrompt := a.prompt("analyse", data)
 
 	// Add batch info if multiple batches
 	var batchInfo string
@@ -199,23 +484,25 @@ func (a *Analyser) analyseBatch(ctx context.Context, files []string, batchNum, t
 		batchInfo = fmt.Sprintf("\n\n## Batch %d of %d\n\nThis is batch %d of %d. Previous batches have been processed. Focus on the files in this batch.", batchNum, totalBatches, batchNum, totalBatches)
 	}
 
-	filesInfo := "\n\nChanged files (relative to working directory):\n" + strings.Join(files, "\n")
-	initialPrompt := contextPrompt + "\n\n" + analysePrompt + batchInfo + filesInfo
+	initialPrompt := contextPrompt + "\n\n" + analysePrompt + batchInfo + locationInfo + a.largeFilesInfo(readable) + changesInfo(changes) + a.diffsInfo(changes)
 
 	// Send initial prompt
 	internal.LogDebug("Batch %d/%d: sending initial prompt, files=%v", batchNum, totalBatches, files)
 	start := time.Now()
-	if err := a.runPrompt(ctx, session, initialPrompt); err != nil {
+	if err := a.runPrompt(ctx, session, initialPrompt, a.rec.beginTurn(batchNum, "initial", initialPrompt, indexDir), &initialUsage); err != nil {
 		internal.LogError("Batch %d/%d: initial prompt failed: %v", batchNum, totalBatches, err)
 		return err
 	}
 	internal.LogDebug("Batch %d/%d: initial prompt completed, duration=%s", batchNum, totalBatches, time.Since(start))
 
-	// Validation loop
+	// Validation loop: schema, then semantic rules on what the batch touched
 	maxRetries := 5
 	for i := 0; i < maxRetries; i++ {
 		internal.LogDebug("Validating .memo/index files (attempt %d/%d)", i+1, maxRetries)
-		result := ValidateIndex(a.indexDir)
+		result := a.indexFiles.Validate(indexDir)
+		if result.Valid {
+			result = validateSemantics(indexDir, a.sourceDir, a.batchScope(files, before, indexDir))
+		}
 		if result.Valid {
 			internal.LogInfo("Batch %d/%d validation passed", batchNum, totalBatches)
 			return nil
@@ -224,13 +511,17 @@ func (a *Analyser) analyseBatch(ctx context.Context, files []string, batchNum, t
 		errMsg := FormatValidationErrors(result)
 		internal.LogError("Batch %d/%d: validation failed (attempt %d/%d): %s", batchNum, totalBatches, i+1, maxRetries, errMsg)
 
-		// Send feedback prompt
-		feedbackPrompt := loadPrompt("feedback")
-		errorInfo := "Validation errors:\n" + FormatValidationErrors(result)
-		fullFeedback := loadPrompt("context") + "\n\n" + feedbackPrompt + "\n\n" + errorInfo
+		// Send feedback prompt. The errors follow it unless a template
+		// already placed them with {{.Errors}}.
+		data.Errors = errMsg
+		feedbackPrompt := a.prompt("feedback", data)
+		fullFeedback := a.prompt("context", data) + a.indexFiles.prompt() + "\n\n" + feedbackPrompt + locationInfo
+		if !strings.Contains(feedbackPrompt, errMsg) {
+			fullFeedback += "\n\nValidation errors:\n" + errMsg
+		}
 
 		internal.LogDebug("Batch %d/%d: sending feedback prompt (attempt %d)", batchNum, totalBatches, i+1)
-		if err := a.runPrompt(ctx, session, fullFeedback); err != nil {
+		if err := a.runPrompt(ctx, session, fullFeedback, a.rec.beginTurn(batchNum, "feedback", fullFeedback, indexDir), &feedbackUsage); err != nil {
 			internal.LogError("Batch %d/%d: feedback prompt failed: %v", batchNum, totalBatches, err)
 			return err
 		}
@@ -239,8 +530,31 @@ func (a *Analyser) analyseBatch(ctx context.Context, files []string, batchNum, t
 	return fmt.Errorf("validation failed after %d attempts", maxRetries)
 }
 
-func (a *Analyser) runPrompt(ctx context.Context, session *agent.Session, prompt string) error {
-	turn, err := session.Prompt(ctx, wire.NewStringContent(prompt))
+// largeFilesInfo flags files that alone exceed the token budget, so the agent
+// reads them in parts instead of overflowing its context
+func (a *Analyser) largeFilesInfo(files []string) string {
+	budget := a.budget()
+	var lines []string
+	for _, f := range files {
+		if tokens := a.estimateTokens(f); tokens > budget {
+			internal.LogNotice("File exceeds token budget (~%d > %d tokens): %s", tokens, budget, f)
+			lines = append(lines, fmt.Sprintf("- %s (~%d tokens)", f, tokens))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n## Large Files\n\nThese files are larger than the context budget for one batch (~%d tokens). Do not read them in one go: start from their outline (package, declarations, exports) and read only the sections you need, in ranges.\n%s", budget, strings.Join(lines, "\n"))
+}
+
+// runPrompt sends a prompt and consumes the streamed response, adding the
+// reported token usage to usage. tr, if non-nil, records the prompt, messages
+// and resulting index changes.
+func (a *Analyser) runPrompt(ctx context.Context, session Session, prompt string, tr *recordedTurn, usage *TokenUsage) (err error) {
+	defer func() { tr.end(err) }()
+	usage.Prompts++
+
+	turn, err := session.Prompt(ctx, prompt)
 	if err != nil {
 		return fmt.Errorf("prompt failed: %w", err)
 	}
@@ -248,30 +562,42 @@ func (a *Analyser) runPrompt(ctx context.Context, session *agent.Session, prompt
 	lb := internal.NewLineBuffer(500 * time.Millisecond)
 
 	// Consume all messages
-	for step := range turn.Steps {
-		for msg := range step.Messages {
-			switch m := msg.(type) {
-			case wire.ApprovalRequest:
-				internal.LogDebug("Auto-approving request")
-				_ = m.Respond(wire.ApprovalRequestResponseApprove)
-			case wire.ContentPart:
-				if m.Type == wire.ContentPartTypeText && m.Text.Valid {
-					lb.Write(m.Text.Value)
-					if lines := lb.Flush(false); lines != "" {
-						internal.LogDebug("Agent output: %s", lines)
-					}
-				}
-			case wire.StatusUpdate:
-				// StatusUpdate usually means a generation round is complete
-				if lines := lb.Flush(true); lines != "" {
+	for msg := range turn.Messages() {
+		var response string
+		switch m := msg.(type) {
+		case wire.StepBegin:
+			// Previous step ended, force flush remaining content
+			if lines := lb.Flush(true); lines != "" {
+				internal.LogDebug("Agent output: %s", lines)
+			}
+		case wire.ApprovalRequest:
+			internal.LogDebug("Auto-approving request")
+			_ = m.Respond(wire.ApprovalRequestResponseApprove)
+			response = string(wire.ApprovalRequestResponseApprove)
+		case wire.ContentPart:
+			if m.Type == wire.ContentPartTypeText && m.Text.Valid {
+				lb.Write(m.Text.Value)
+				if lines := lb.Flush(false); lines != "" {
 					internal.LogDebug("Agent output: %s", lines)
 				}
 			}
+		case wire.StatusUpdate:
+			// StatusUpdate usually means a generation round is complete
+			if lines := lb.Flush(true); lines != "" {
+				internal.LogDebug("Agent output: %s", lines)
+			}
+			if m.TokenUsage.Valid {
+				usage.Input += m.TokenUsage.Value.InputOther
+				usage.Output += m.TokenUsage.Value.Output
+				usage.CacheRead += m.TokenUsage.Value.InputCacheRead
+				usage.CacheCreation += m.TokenUsage.Value.InputCacheCreation
+			}
 		}
-		// Step ended, force flush remaining content
-		if lines := lb.Flush(true); lines != "" {
-			internal.LogDebug("Agent output: %s", lines)
-		}
+		tr.record(msg, response)
+	}
+	// Turn ended, force flush remaining content
+	if lines := lb.Flush(true); lines != "" {
+		internal.LogDebug("Agent output: %s", lines)
 	}
 
 	if err := turn.Err(); err != nil {
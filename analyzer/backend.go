@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MoonshotAI/kimi-agent-sdk/go/wire"
+)
+
+// Backend names accepted in agent.backend
+const (
+	BackendKimi   = "kimi"
+	BackendOpenAI = "openai"
+)
+
+// Backend creates agent sessions for the Analyser.
+// The kimi SDK is the default; other runtimes are selected via agent.backend.
+type Backend interface {
+	NewSession(opts SessionOptions) (Session, error)
+}
+
+// SessionOptions describes the session an Analyser needs
+type SessionOptions struct {
+	SessionID     string // stable ID so the runtime can resume context
+	WorkDir       string // project root the agent operates in
+	IndexDir      string // directory holding the index files the agent maintains
+	MCPConfigFile string // local MCP config (prevents loading ~/.kimi/mcp.json)
+	Batch         int    // 1-based number of the batch the session analyses
+}
+
+// Session is a conversation with an agent runtime
+type Session interface {
+	// Prompt sends a prompt and returns the turn streaming the agent's response
+	Prompt(ctx context.Context, prompt string) (Turn, error)
+	Close() error
+}
+
+// Turn streams the messages produced in response to a single prompt
+type Turn interface {
+	// Messages yields wire messages until the turn ends.
+	// A wire.StepBegin is emitted at the start of every step.
+	Messages() <-chan wire.Message
+	// Err returns the turn error; only valid after Messages is drained
+	Err() error
+}
+
+// NewBackend returns the backend selected by cfg.Backend
+func NewBackend(cfg AgentConfig) (Backend, error) {
+	switch cfg.Backend {
+	case "", BackendKimi:
+		return &kimiBackend{cfg: cfg}, nil
+	case BackendOpenAI:
+		return newOpenAIBackend(cfg), nil
+	case BackendScripted:
+		return newScriptedBackend(cfg)
+	default:
+		return nil, fmt.Errorf("unknown agent backend: %q (available: %s, %s, %s)", cfg.Backend, BackendKimi, BackendOpenAI, BackendScripted)
+	}
+}
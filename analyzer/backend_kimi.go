@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"context"
+
+	"github.com/YoungY620/memo/internal"
+
+	agent "github.com/MoonshotAI/kimi-agent-sdk/go"
+	"github.com/MoonshotAI/kimi-agent-sdk/go/wire"
+)
+
+// kimiBackend drives the kimi CLI through kimi-agent-sdk
+type kimiBackend struct {
+	cfg AgentConfig
+}
+
+func (b *kimiBackend) NewSession(opts SessionOptions) (Session, error) {
+	options := []agent.Option{
+		agent.WithWorkDir(opts.WorkDir),
+		agent.WithAutoApprove(),
+		agent.WithMCPConfigFile(opts.MCPConfigFile),
+		agent.WithSession(opts.SessionID),
+	}
+
+	// Use kimi defaults if agent config is not set
+	if b.cfg.APIKey != "" && b.cfg.Model != "" {
+		internal.LogDebug("Using configured model: %s", b.cfg.Model)
+		options = append(options, agent.WithAPIKey(b.cfg.APIKey), agent.WithModel(b.cfg.Model))
+	} else {
+		internal.LogDebug("Using kimi default configuration")
+	}
+
+	session, err := agent.NewSession(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &kimiSession{session: session}, nil
+}
+
+type kimiSession struct {
+	session *agent.Session
+}
+
+func (s *kimiSession) Prompt(ctx context.Context, prompt string) (Turn, error) {
+	turn, err := s.session.Prompt(ctx, wire.NewStringContent(prompt))
+	if err != nil {
+		return nil, err
+	}
+	t := &kimiTurn{turn: turn, msgs: make(chan wire.Message)}
+	go t.pump()
+	return t, nil
+}
+
+func (s *kimiSession) Close() error {
+	return s.session.Close()
+}
+
+// kimiTurn flattens the SDK's step/message channels into a single stream
+type kimiTurn struct {
+	turn *agent.Turn
+	msgs chan wire.Message
+}
+
+func (t *kimiTurn) pump() {
+	defer close(t.msgs)
+	n := 0
+	for step := range t.turn.Steps {
+		n++
+		t.msgs <- wire.StepBegin{N: n}
+		for msg := range step.Messages {
+			t.msgs <- msg
+		}
+	}
+
+	// The SDK consumes status updates itself and only keeps their sum
+	if usage := t.turn.Usage(); usage != nil && usage.Tokens != (wire.TokenUsage{}) {
+		t.msgs <- wire.StatusUpdate{TokenUsage: wire.Optional[wire.TokenUsage]{Value: usage.Tokens, Valid: true}}
+	}
+}
+
+func (t *kimiTurn) Messages() <-chan wire.Message {
+	return t.msgs
+}
+
+func (t *kimiTurn) Err() error {
+	return t.turn.Err()
+}
@@ -0,0 +1,346 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+
+	"github.com/MoonshotAI/kimi-agent-sdk/go/wire"
+)
+
+const (
+	// defaultOpenAIBaseURL is used when agent.base_url is not set
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+	// openAIMaxSteps bounds the tool-call loop of a single prompt
+	openAIMaxSteps = 50
+
+	// openAIMaxReadBytes truncates read_file results to keep context bounded
+	openAIMaxReadBytes = 256 * 1024
+)
+
+const openAISystemPrompt = `You are memo's analysis agent. You cannot see the codebase directly: use the list_dir and read_file tools to inspect it, and the write_file tool to save index files. Paths are relative to the project root. Only files inside the index directory may be written.`
+
+// openAIBackend talks to any OpenAI-compatible chat-completions endpoint
+// and implements the file tools the analysis prompts rely on.
+type openAIBackend struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func newOpenAIBackend(cfg AgentConfig) *openAIBackend {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	return &openAIBackend{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   cfg.Model,
+		client:  &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+func (b *openAIBackend) NewSession(opts SessionOptions) (Session, error) {
+	if b.model == "" {
+		return nil, fmt.Errorf("agent.model is required for the %s backend", BackendOpenAI)
+	}
+	internal.LogDebug("Using OpenAI-compatible backend: %s, model=%s", b.baseURL, b.model)
+	return &openAISession{
+		backend:  b,
+		workDir:  opts.WorkDir,
+		indexDir: opts.IndexDir,
+		history:  []chatMessage{{Role: "system", Content: openAISystemPrompt}},
+	}, nil
+}
+
+// ============== Chat Completions Wire Format ==============
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function chatFunctionCall `json:"function"`
+}
+
+type chatFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Tools    []chatTool    `json:"tools,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+var openAITools = []chatTool{
+	{Type: "function", Function: chatFunction{
+		Name:        "list_dir",
+		Description: "List the entries of a directory. Directories end with '/'.",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"directory relative to the project root"}},"required":["path"]}`),
+	}},
+	{Type: "function", Function: chatFunction{
+		Name:        "read_file",
+		Description: "Read a text file.",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"file relative to the project root"}},"required":["path"]}`),
+	}},
+	{Type: "function", Function: chatFunction{
+		Name:        "write_file",
+		Description: "Overwrite a file inside the index directory with the given content.",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"file relative to the project root"},"content":{"type":"string"}},"required":["path","content"]}`),
+	}},
+}
+
+// ============== Session ==============
+
+type openAISession struct {
+	backend  *openAIBackend
+	workDir  string
+	indexDir string
+	history  []chatMessage
+}
+
+func (s *openAISession) Prompt(ctx context.Context, prompt string) (Turn, error) {
+	s.history = append(s.history, chatMessage{Role: "user", Content: prompt})
+	t := &openAITurn{msgs: make(chan wire.Message)}
+	go t.run(ctx, s)
+	return t, nil
+}
+
+func (s *openAISession) Close() error {
+	return nil
+}
+
+// complete sends the conversation so far and returns the assistant reply
+func (s *openAISession) complete(ctx context.Context) (*chatResponse, error) {
+	body, err := json.Marshal(chatRequest{Model: s.backend.model, Messages: s.history, Tools: openAITools})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.backend.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.backend.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.backend.apiKey)
+	}
+
+	resp, err := s.backend.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result chatResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("API error (HTTP %d): %s", resp.StatusCode, result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned HTTP %d", resp.StatusCode)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("API returned no choices")
+	}
+	return &result, nil
+}
+
+// ============== Turn ==============
+
+type openAITurn struct {
+	msgs chan wire.Message
+	err  error
+}
+
+func (t *openAITurn) Messages() <-chan wire.Message {
+	return t.msgs
+}
+
+func (t *openAITurn) Err() error {
+	return t.err
+}
+
+// run drives the tool-call loop until the model stops calling tools
+func (t *openAITurn) run(ctx context.Context, s *openAISession) {
+	defer close(t.msgs)
+
+	for step := 1; step <= openAIMaxSteps; step++ {
+		t.msgs <- wire.StepBegin{N: step}
+
+		resp, err := s.complete(ctx)
+		if err != nil {
+			t.err = err
+			return
+		}
+		if resp.Usage != nil {
+			t.msgs <- wire.StatusUpdate{TokenUsage: wire.Optional[wire.TokenUsage]{
+				Value: wire.TokenUsage{InputOther: resp.Usage.PromptTokens, Output: resp.Usage.CompletionTokens},
+				Valid: true,
+			}}
+		}
+
+		reply := resp.Choices[0].Message
+		reply.Role = "assistant"
+		s.history = append(s.history, reply)
+		if reply.Content != "" {
+			t.msgs <- wire.NewTextContentPart(reply.Content)
+		}
+		if len(reply.ToolCalls) == 0 {
+			return
+		}
+
+		for _, call := range reply.ToolCalls {
+			t.msgs <- wire.ToolCall{
+				Type: wire.ToolCallTypeFunction,
+				ID:   call.ID,
+				Function: wire.ToolCallFunction{
+					Name:      call.Function.Name,
+					Arguments: wire.Optional[string]{Value: call.Function.Arguments, Valid: true},
+				},
+			}
+			output, err := s.execTool(call.Function.Name, call.Function.Arguments)
+			result := wire.ToolResult{ToolCallID: call.ID}
+			if err != nil {
+				output = err.Error()
+				result.ReturnValue.IsError = true
+				result.ReturnValue.Message = output
+			}
+			result.ReturnValue.Output = wire.NewStringContent(output)
+			t.msgs <- result
+			s.history = append(s.history, chatMessage{Role: "tool", Content: output, ToolCallID: call.ID})
+		}
+	}
+	t.err = fmt.Errorf("exceeded %d steps without finishing", openAIMaxSteps)
+}
+
+// ============== Tools ==============
+
+func (s *openAISession) execTool(name, arguments string) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	path, err := s.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "list_dir":
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return "", err
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if e.IsDir() {
+				names = append(names, e.Name()+"/")
+			} else {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+		return strings.Join(names, "\n"), nil
+	case "read_file":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if len(data) > openAIMaxReadBytes {
+			return string(data[:openAIMaxReadBytes]) + "\n... (truncated)", nil
+		}
+		return string(data), nil
+	case "write_file":
+		if !isWithin(s.indexDir, path) {
+			return "", fmt.Errorf("write denied: %s is outside the index directory", args.Path)
+		}
+		if err := os.WriteFile(path, []byte(args.Content), 0644); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("wrote %d bytes to %s", len(args.Content), args.Path), nil
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+// resolve maps a tool path to an absolute path inside the work directory
+func (s *openAISession) resolve(p string) (string, error) {
+	if p == "" {
+		p = "."
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.workDir, p)
+	}
+	p = filepath.Clean(p)
+	if !isWithin(s.workDir, p) {
+		return "", fmt.Errorf("access denied: %s is outside the project", p)
+	}
+	return p, nil
+}
+
+// isWithin reports whether path is base or located below it
+func isWithin(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
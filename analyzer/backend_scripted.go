@@ -0,0 +1,284 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/YoungY620/memo/internal"
+
+	"github.com/MoonshotAI/kimi-agent-sdk/go/wire"
+)
+
+// BackendScripted replays canned agent turns from a directory, for offline runs
+const BackendScripted = "scripted"
+
+// ScriptedTurn is one canned agent response, stored as a JSON file in the script
+// directory. Turn files are consumed in lexical order, one per prompt, across
+// all sessions created by the backend; a turn with a batch number is only
+// served to sessions for that batch. Recordings (see recorder.go) use the
+// same format, so a recording's turns/ directory is a valid script.
+type ScriptedTurn struct {
+	Batch    int                        `json:"batch,omitempty"`    // serve only to this batch's sessions; 0 matches any
+	Text     string                     `json:"text,omitempty"`     // assistant output
+	Messages []RecordedMessage          `json:"messages,omitempty"` // exact wire messages to replay instead of Text
+	Files    map[string]json.RawMessage `json:"files,omitempty"`    // index file -> content; a JSON string is written verbatim, null deletes
+	Usage    *ScriptedUsage             `json:"usage,omitempty"`    // reported token usage; estimated when omitted
+	Error    string                     `json:"error,omitempty"`    // fail the turn with this error
+}
+
+// ScriptedUsage is the token usage reported for a scripted turn
+type ScriptedUsage struct {
+	Input  int `json:"input"`
+	Output int `json:"output"`
+}
+
+// scriptedBackend hands out the turns of a script directory in order
+type scriptedBackend struct {
+	dir string
+
+	mu    sync.Mutex
+	turns []string // turn file paths, sorted
+	used  []bool
+}
+
+func newScriptedBackend(cfg AgentConfig) (*scriptedBackend, error) {
+	if cfg.ScriptDir == "" {
+		return nil, fmt.Errorf("agent.script_dir is required for the %s backend", BackendScripted)
+	}
+	turns, err := filepath.Glob(filepath.Join(cfg.ScriptDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(turns) == 0 {
+		return nil, fmt.Errorf("no turn files (*.json) found in script dir: %s", cfg.ScriptDir)
+	}
+	sort.Strings(turns)
+	internal.LogDebug("Using scripted backend: %s (%d turns)", cfg.ScriptDir, len(turns))
+	return &scriptedBackend{dir: cfg.ScriptDir, turns: turns, used: make([]bool, len(turns))}, nil
+}
+
+func (b *scriptedBackend) NewSession(opts SessionOptions) (Session, error) {
+	return &scriptedSession{backend: b, indexDir: opts.IndexDir, batch: opts.Batch}, nil
+}
+
+// nextTurn loads the first unused turn file matching batch; an exhausted
+// script yields empty turns
+func (b *scriptedBackend) nextTurn(batch int) (*ScriptedTurn, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, path := range b.turns {
+		if b.used[i] {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, path, err
+		}
+		var turn ScriptedTurn
+		if err := json.Unmarshal(data, &turn); err != nil {
+			return nil, path, fmt.Errorf("invalid turn file %s: %w", path, err)
+		}
+		if turn.Batch != 0 && batch != 0 && turn.Batch != batch {
+			continue
+		}
+		b.used[i] = true
+		return &turn, path, nil
+	}
+	return &ScriptedTurn{}, "", nil
+}
+
+type scriptedSession struct {
+	backend  *scriptedBackend
+	indexDir string
+	batch    int
+}
+
+func (s *scriptedSession) Prompt(ctx context.Context, prompt string) (Turn, error) {
+	turn, path, err := s.backend.nextTurn(s.batch)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		internal.LogDebug("Scripted backend: script exhausted, returning empty turn")
+	} else {
+		internal.LogDebug("Scripted backend: replaying %s", filepath.Base(path))
+	}
+
+	t := &scriptedTurn{msgs: make(chan wire.Message)}
+	go t.play(ctx, s.indexDir, prompt, turn)
+	return t, nil
+}
+
+func (s *scriptedSession) Close() error {
+	return nil
+}
+
+type scriptedTurn struct {
+	msgs chan wire.Message
+	err  error
+}
+
+func (t *scriptedTurn) Messages() <-chan wire.Message {
+	return t.msgs
+}
+
+func (t *scriptedTurn) Err() error {
+	return t.err
+}
+
+// play emits the turn as the same wire messages a real agent produces:
+// text, then an approval-gated write_file tool call per index file, then usage.
+func (t *scriptedTurn) play(ctx context.Context, indexDir, prompt string, turn *ScriptedTurn) {
+	defer close(t.msgs)
+
+	if len(turn.Messages) > 0 {
+		t.replay(indexDir, turn)
+		return
+	}
+
+	t.msgs <- wire.StepBegin{N: 1}
+	if turn.Text != "" {
+		t.msgs <- wire.NewTextContentPart(turn.Text)
+	}
+
+	names := make([]string, 0, len(turn.Files))
+	for name := range turn.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		callID := fmt.Sprintf("scripted_%d", i+1)
+		path := filepath.Join(indexDir, name)
+		t.msgs <- wire.ToolCall{
+			Type: wire.ToolCallTypeFunction,
+			ID:   callID,
+			Function: wire.ToolCallFunction{
+				Name:      "write_file",
+				Arguments: wire.Optional[string]{Value: fmt.Sprintf(`{"path": %q}`, path), Valid: true},
+			},
+		}
+
+		responder := &scriptedResponder{ch: make(chan wire.RequestResponse, 1)}
+		t.msgs <- wire.ApprovalRequest{
+			Responder:   responder,
+			ID:          callID,
+			ToolCallID:  callID,
+			Sender:      BackendScripted,
+			Action:      "write_file",
+			Description: "Write " + path,
+		}
+
+		var response wire.RequestResponse
+		select {
+		case response = <-responder.ch:
+		case <-ctx.Done():
+			t.err = ctx.Err()
+			return
+		}
+
+		result := wire.ToolResult{ToolCallID: callID}
+		if response != wire.ApprovalRequestResponseApprove && response != wire.ApprovalRequestResponseApproveForSession {
+			result.ReturnValue.IsError = true
+			result.ReturnValue.Message = "rejected by user"
+		} else if err := writeScriptedFile(path, turn.Files[name]); err != nil {
+			result.ReturnValue.IsError = true
+			result.ReturnValue.Message = err.Error()
+		}
+		result.ReturnValue.Output = wire.NewStringContent(result.ReturnValue.Message)
+		t.msgs <- result
+	}
+
+	usage := turn.Usage
+	if usage == nil {
+		usage = &ScriptedUsage{Input: len(prompt) / 4, Output: len(turn.Text) / 4}
+	}
+	t.msgs <- wire.StatusUpdate{TokenUsage: wire.Optional[wire.TokenUsage]{
+		Value: wire.TokenUsage{InputOther: usage.Input, Output: usage.Output},
+		Valid: true,
+	}}
+
+	if turn.Error != "" {
+		t.err = fmt.Errorf("scripted error: %s", turn.Error)
+	}
+}
+
+// replay emits recorded wire messages verbatim, then applies the recorded
+// index changes. Approval requests are answered but the answer is ignored,
+// since the recorded outcome is already captured in Files.
+func (t *scriptedTurn) replay(indexDir string, turn *ScriptedTurn) {
+	for _, rm := range turn.Messages {
+		msg, err := decodeMessage(rm)
+		if err != nil {
+			internal.LogDebug("Scripted backend: skipping message: %v", err)
+			continue
+		}
+		if req, ok := msg.(wire.ApprovalRequest); ok {
+			req.Responder = &scriptedResponder{ch: make(chan wire.RequestResponse, 1)}
+			msg = req
+		}
+		t.msgs <- msg
+	}
+
+	names := make([]string, 0, len(turn.Files))
+	for name := range turn.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeScriptedFile(filepath.Join(indexDir, name), turn.Files[name]); err != nil {
+			t.err = err
+			return
+		}
+	}
+
+	if turn.Error != "" {
+		t.err = fmt.Errorf("scripted error: %s", turn.Error)
+	}
+}
+
+// writeScriptedFile writes raw turn content; strings are written verbatim so
+// scripts can produce invalid JSON, and null removes the file.
+func writeScriptedFile(path string, content json.RawMessage) error {
+	raw := strings.TrimSpace(string(content))
+	if raw == "null" {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data := []byte(raw)
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal(content, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// scriptedResponder delivers the approval decision back to the playing turn
+type scriptedResponder struct {
+	ch chan wire.RequestResponse
+}
+
+func (r *scriptedResponder) Respond(resp wire.RequestResponse) error {
+	select {
+	case r.ch <- resp:
+		return nil
+	default:
+		return fmt.Errorf("request already answered")
+	}
+}
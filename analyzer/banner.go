@@ -32,6 +32,7 @@ type BannerOptions struct {
 	WorkDir    string
 	Version    string
 	UpdateInfo *UpdateInfo // Optional: update information to display
+	Throttle   *Throttle   // Optional: spending budget already exhausted
 }
 
 // UpdateInfo contains information about an available update
@@ -82,6 +83,15 @@ func getGreeting() string {
 	return ""
 }
 
+// throttleNotice describes an exhausted budget in one line
+func throttleNotice(t *Throttle) string {
+	notice := "Throttled: " + t.Reason
+	if t.Until != nil {
+		notice += " until " + t.Until.Format("15:04")
+	}
+	return notice
+}
+
 // ============== Full Banner (>= 60) ==============
 
 func printFullBanner(opts BannerOptions, greeting string, termWidth int) {
@@ -140,6 +150,12 @@ func printFullBanner(opts BannerOptions, greeting string, termWidth int) {
 		colored2 := "    " + colorDim + opts.UpdateInfo.UpdateCommand + colorReset
 		fmt.Println(line(plain2, colored2))
 	}
+	// Budget notice
+	if opts.Throttle != nil {
+		fmt.Println(simpleLine(""))
+		notice := throttleNotice(opts.Throttle)
+		fmt.Println(line("  ⏸ "+notice, "  "+colorCyan+"⏸ "+notice+colorReset))
+	}
 	fmt.Println(simpleLine(""))
 	fmt.Println(colorDim + "╰" + strings.Repeat("─", innerWidth) + "╯" + colorReset)
 	fmt.Println()
@@ -164,6 +180,11 @@ func printCompactBanner(opts BannerOptions, greeting string) {
 		fmt.Println("  " + colorCyan + "⬆ New version " + opts.UpdateInfo.LatestVersion + " available" + colorReset)
 		fmt.Println("    " + colorDim + opts.UpdateInfo.UpdateCommand + colorReset)
 	}
+	// Budget notice
+	if opts.Throttle != nil {
+		fmt.Println()
+		fmt.Println("  " + colorCyan + "⏸ " + throttleNotice(opts.Throttle) + colorReset)
+	}
 	fmt.Println()
 }
 
@@ -179,6 +200,10 @@ func printMinimalBanner(opts BannerOptions, greeting string) {
 		fmt.Println(colorCyan + "⬆ " + opts.UpdateInfo.LatestVersion + " available" + colorReset)
 		fmt.Println(colorDim + opts.UpdateInfo.UpdateCommand + colorReset)
 	}
+	// Budget notice
+	if opts.Throttle != nil {
+		fmt.Println(colorCyan + "⏸ " + throttleNotice(opts.Throttle) + colorReset)
+	}
 }
 
 // ============== Helper Functions ==============
@@ -0,0 +1,220 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// branchStateFiles are the files under .memo describing what a branch's
+// index reflects, or what is left to analyse in it, parked and restored with it
+var branchStateFiles = []string{manifestFileName, revisionFileName, provenanceFileName, queueFileName}
+
+// Branches keeps one index per git branch. .memo/index is the index of the
+// checked-out branch; when HEAD moves to another branch, the index and its
+// state files (manifest, revision, provenance, batch queue) are parked in
+// .memo/branches/<old>/ and those of the new branch take their place. A
+// branch seen for the first time is seeded from the known branch it shares
+// the most recent merge-base with, and the files differing from that
+// branch's last analysis are then analysed as usual.
+type Branches struct {
+	workDir, memoDir string
+
+	mu      sync.Mutex
+	current string // branch .memo/index belongs to
+}
+
+// OpenBranches tracks the branches of the git repository at workDir. The
+// branch of .memo/index is read from .memo/branch.json, or taken to be the
+// checked-out one.
+func OpenBranches(workDir string) (*Branches, error) {
+	b := &Branches{workDir: workDir, memoDir: filepath.Join(workDir, ".memo")}
+	b.current = ActiveBranch(b.memoDir)
+	if b.current != "" {
+		return b, nil
+	}
+	branch, err := internal.GitBranch(workDir)
+	if err != nil {
+		return nil, err
+	}
+	if branch != "" {
+		b.current = branch
+		if err := b.save(); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// ActiveBranch returns the branch .memo/index belongs to; "" if branches are
+// not tracked
+func ActiveBranch(memoDir string) string {
+	return internal.ActiveBranch(memoDir)
+}
+
+// BranchIndexDir returns the parked index of a branch
+func BranchIndexDir(memoDir, branch string) string {
+	return internal.BranchIndexDir(memoDir, branch)
+}
+
+// Current returns the branch .memo/index belongs to
+func (b *Branches) Current() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current
+}
+
+// Moved reports whether HEAD is on another branch than .memo/index
+func (b *Branches) Moved() bool {
+	branch, err := internal.GitBranch(b.workDir)
+	return err == nil && branch != "" && branch != b.Current()
+}
+
+// Watch calls onMove whenever HEAD is found on another branch, checking
+// every interval until ctx is done
+func (b *Branches) Watch(ctx context.Context, interval time.Duration, onMove func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if b.Moved() {
+				onMove()
+			}
+		}
+	}
+}
+
+// Sync switches .memo/index to the checked-out branch if HEAD moved to
+// another one, and reloads m and q from the switched manifest and queue. It
+// returns the new branch, or "" if nothing changed. A detached HEAD keeps the
+// current index. Must not run concurrently with an analysis.
+func (b *Branches) Sync(m *Manifest, q *Queue) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	branch, err := internal.GitBranch(b.workDir)
+	if err != nil || branch == "" || branch == b.current {
+		return "", err
+	}
+
+	old := b.current
+	if old != "" {
+		if err := b.park(old); err != nil {
+			return "", fmt.Errorf("failed to park index of branch %s: %w", old, err)
+		}
+	}
+	from := branch
+	if !internal.IsParked(b.memoDir, branch) {
+		from = b.seedBranch(branch)
+		internal.LogInfo("Branch %s has no index yet, seeding it from %s", branch, from)
+	}
+	if from != old {
+		if err := b.restore(from); err != nil {
+			return "", fmt.Errorf("failed to restore index of branch %s: %w", from, err)
+		}
+	}
+
+	b.current = branch
+	if err := b.save(); err != nil {
+		return "", err
+	}
+	if m != nil {
+		if err := m.Reload(); err != nil {
+			internal.LogError("Failed to load manifest: %v", err)
+		}
+	}
+	if q != nil {
+		if err := q.Reload(); err != nil {
+			internal.LogError("Failed to load queue: %v", err)
+		}
+	}
+	internal.LogInfo("Switched index from branch %s to %s", old, branch)
+	return branch, nil
+}
+
+// park copies .memo/index and its state files to the branch's directory,
+// marking it parked once complete
+func (b *Branches) park(branch string) error {
+	indexDir := BranchIndexDir(b.memoDir, branch)
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		return err
+	}
+	if err := commitIndex(filepath.Join(b.memoDir, "index"), indexDir); err != nil {
+		return err
+	}
+	if err := copyStateFiles(b.memoDir, filepath.Dir(indexDir)); err != nil {
+		return err
+	}
+	return internal.MarkParked(b.memoDir, branch)
+}
+
+// restore puts the parked index and state files of a branch in place
+func (b *Branches) restore(branch string) error {
+	indexDir := BranchIndexDir(b.memoDir, branch)
+	if err := commitIndex(indexDir, filepath.Join(b.memoDir, "index")); err != nil {
+		return err
+	}
+	return copyStateFiles(filepath.Dir(indexDir), b.memoDir)
+}
+
+// copyStateFiles makes the branch state files of dst match src; files
+// missing from src are removed from dst
+func copyStateFiles(src, dst string) error {
+	for _, name := range branchStateFiles {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if os.IsNotExist(err) {
+			if err := os.Remove(filepath.Join(dst, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := internal.WriteFileAtomic(filepath.Join(dst, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedBranch returns the branch with an index whose merge-base with branch
+// is the fewest commits behind it, preferring the current branch on ties
+func (b *Branches) seedBranch(branch string) string {
+	best, bestDistance := b.current, -1
+	for _, candidate := range append([]string{b.current}, internal.ParkedBranches(b.memoDir)...) {
+		if candidate == "" || candidate == branch {
+			continue
+		}
+		base, err := internal.GitMergeBase(b.workDir, candidate, branch)
+		if err != nil {
+			continue // deleted or unrelated
+		}
+		distance, err := internal.GitCountCommits(b.workDir, base, branch)
+		if err != nil {
+			continue
+		}
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// save records the current branch in .memo/branch.json
+func (b *Branches) save() error {
+	data, err := json.Marshal(internal.BranchState{Branch: b.current})
+	if err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(filepath.Join(b.memoDir, internal.BranchFileName), data, 0644)
+}
@@ -0,0 +1,229 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// Budget modes: what the watcher does when a change set exceeds an hourly or daily budget
+const (
+	BudgetDefer   = "defer"   // postpone the whole change set (default)
+	BudgetReduced = "reduced" // analyse the files that fit, postpone the rest
+)
+
+// BudgetLimits caps the spending of the watcher; 0 means unlimited.
+// Requests count prompts sent to the model.
+type BudgetLimits struct {
+	RunTokens    int
+	HourTokens   int
+	DayTokens    int
+	HourRequests int
+	DayRequests  int
+	Mode         string // BudgetDefer or BudgetReduced
+}
+
+// Throttle describes a change set that was deferred or reduced (status.json)
+type Throttle struct {
+	Reason   string     `json:"reason"`
+	Mode     string     `json:"mode"`            // BudgetDefer or BudgetReduced
+	Deferred int        `json:"deferred"`        // files postponed
+	Until    *time.Time `json:"until,omitempty"` // when the exhausted budget frees up
+}
+
+// Budget checks change sets against the spending limits, using the usage
+// recorded in .memo/usage.jsonl over the last hour and day and an estimate of
+// the change set's cost from file sizes
+type Budget struct {
+	limits BudgetLimits
+	ana    *Analyser
+}
+
+// NewBudget creates a budget for the analyser's work directory and cost model
+func NewBudget(limits BudgetLimits, ana *Analyser) (*Budget, error) {
+	switch limits.Mode {
+	case "":
+		limits.Mode = BudgetDefer
+	case BudgetDefer, BudgetReduced:
+	default:
+		return nil, fmt.Errorf("unknown budget mode: %q (available: %s, %s)", limits.Mode, BudgetDefer, BudgetReduced)
+	}
+	return &Budget{limits: limits, ana: ana}, nil
+}
+
+// budgetLimit is one limit and how much of it is left
+type budgetLimit struct {
+	name      string
+	remaining int       // tokens that may still be spent
+	capacity  int       // tokens the limit allows in all; a change set above it never fits
+	until     time.Time // when the window frees enough for the change set, or as much of it as can fit
+}
+
+// Check splits a change set (absolute paths) into the files to analyse now
+// and a throttle describing the postponed rest; throttle is nil when all
+// files fit. A change set above the run budget, or above a whole hourly or
+// daily budget, is always reduced, since waiting would not make it fit.
+func (b *Budget) Check(files []string) (now []string, throttle *Throttle) {
+	weights := make([]int, len(files))
+	need := 0
+	for i, f := range files {
+		weights[i] = b.ana.estimateTokens(toRelativePaths([]string{f}, b.ana.workDir)[0])
+		need += weights[i]
+	}
+
+	binding := b.binding(need)
+	if binding == nil || need <= binding.remaining {
+		return files, nil
+	}
+
+	throttle = &Throttle{Reason: binding.name + " exhausted", Mode: b.limits.Mode}
+	if !binding.until.IsZero() {
+		throttle.Until = &binding.until
+	}
+	if need > binding.capacity {
+		throttle.Mode = BudgetReduced
+	}
+
+	if throttle.Mode == BudgetReduced {
+		// Files in path order, as many as fit
+		order := make([]int, len(files))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return files[order[i]] < files[order[j]] })
+		spent := 0
+		for _, i := range order {
+			if spent+weights[i] <= binding.remaining {
+				now = append(now, files[i])
+				spent += weights[i]
+			}
+		}
+		// A single file above the whole budget still has to be analysed
+		// some time
+		if len(now) == 0 && binding.remaining >= binding.capacity {
+			now = []string{files[order[0]]}
+		}
+	}
+	throttle.Deferred = len(files) - len(now)
+	if len(now) == 0 {
+		throttle.Mode = BudgetDefer
+	}
+	internal.LogNotice("Throttled: %s, analysing %d of %d changed files", throttle.Reason, len(now), len(files))
+	return now, throttle
+}
+
+// Exhausted returns a throttle if an hourly or daily budget is already used up
+func (b *Budget) Exhausted() *Throttle {
+	binding := b.binding(1)
+	if binding == nil || binding.remaining > 0 {
+		return nil
+	}
+	t := &Throttle{Reason: binding.name + " exhausted", Mode: BudgetDefer}
+	if !binding.until.IsZero() {
+		t.Until = &binding.until
+	}
+	return t
+}
+
+// binding returns the limit leaving the fewest tokens for a change set of
+// need tokens, or nil if nothing is limited
+func (b *Budget) binding(need int) *budgetLimit {
+	records, err := ReadUsage(b.ana.workDir)
+	if err != nil {
+		internal.LogError("Failed to read usage, ignoring hourly and daily budgets: %v", err)
+	}
+	now := time.Now()
+	batch := b.ana.budget()
+	requests := (need + batch - 1) / batch // about one prompt per batch
+
+	var limits []budgetLimit
+	if b.limits.RunTokens > 0 {
+		limits = append(limits, budgetLimit{name: "run token budget", remaining: b.limits.RunTokens, capacity: b.limits.RunTokens})
+	}
+	for _, w := range []struct {
+		name           string
+		window         time.Duration
+		tokens, prompt int
+	}{
+		{"hour", time.Hour, b.limits.HourTokens, b.limits.HourRequests},
+		{"day", 24 * time.Hour, b.limits.DayTokens, b.limits.DayRequests},
+	} {
+		points := usageSince(records, now.Add(-w.window))
+		if w.tokens > 0 {
+			used := 0
+			for _, p := range points {
+				used += p.tokens
+			}
+			limits = append(limits, budgetLimit{
+				name:      w.name + " token budget",
+				remaining: w.tokens - used,
+				capacity:  w.tokens,
+				until:     freedAt(points, w.window, w.tokens, min(need, w.tokens), func(p usagePoint) int { return p.tokens }),
+			})
+		}
+		if w.prompt > 0 {
+			used := 0
+			for _, p := range points {
+				used += p.prompts
+			}
+			limits = append(limits, budgetLimit{
+				name:      w.name + " request budget",
+				remaining: (w.prompt - used) * batch,
+				capacity:  w.prompt * batch,
+				until:     freedAt(points, w.window, w.prompt, min(requests, w.prompt), func(p usagePoint) int { return p.prompts }),
+			})
+		}
+	}
+
+	var binding *budgetLimit
+	remaining := math.MaxInt
+	for i := range limits {
+		if limits[i].remaining < remaining {
+			binding, remaining = &limits[i], limits[i].remaining
+		}
+	}
+	return binding
+}
+
+// usagePoint is the spending of one run
+type usagePoint struct {
+	time    time.Time
+	tokens  int
+	prompts int
+}
+
+// usageSince returns the runs recorded after since, oldest first
+func usageSince(records []UsageRecord, since time.Time) []usagePoint {
+	var points []usagePoint
+	for _, rec := range records {
+		if rec.Kind != UsageRun || !rec.Time.After(since) {
+			continue
+		}
+		total := rec.Initial
+		total.Add(rec.Feedback)
+		points = append(points, usagePoint{time: rec.Time, tokens: total.Total(), prompts: total.Prompts})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+	return points
+}
+
+// freedAt returns when enough of the window's usage has expired for need to
+// fit under limit (the zero time if it fits now)
+func freedAt(points []usagePoint, window time.Duration, limit, need int, amount func(usagePoint) int) time.Time {
+	used := 0
+	for _, p := range points {
+		used += amount(p)
+	}
+	var at time.Time
+	for _, p := range points {
+		if used+need <= limit {
+			break
+		}
+		used -= amount(p)
+		at = p.time.Add(window)
+	}
+	return at
+}
@@ -0,0 +1,202 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Change kinds, as shown to the agent
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	ChangeRenamed  = "renamed"
+)
+
+// Change is a changed file of a batch, relative to the work directory
+type Change struct {
+	Path string
+	Kind string // ChangeAdded, ChangeModified, ChangeDeleted or ChangeRenamed
+	From string // previous path of a renamed file
+}
+
+// NoteRename records that the watcher saw oldPath renamed to newPath
+// (absolute paths), so the next analysis of both reports a rename instead of
+// a deletion and an addition
+func (a *Analyser) NoteRename(oldPath, newPath string) {
+	a.renamesMu.Lock()
+	defer a.renamesMu.Unlock()
+	if a.renames == nil {
+		a.renames = make(map[string]string)
+	}
+	a.renames[a.relPath(newPath)] = a.relPath(oldPath)
+}
+
+// findRenames pairs deleted and new files (relative paths) of a change set,
+// returning new -> old. Pairs come from the renames noted by the watcher, or
+// from a new file with the content the manifest recorded for a deleted one.
+func (a *Analyser) findRenames(files []string) map[string]string {
+	listed := make(map[string]bool, len(files))
+	var deleted, added []string
+	for _, f := range files {
+		listed[f] = true
+		if !a.exists(f) {
+			deleted = append(deleted, f)
+		} else if a.manifest != nil && !a.manifest.Has(f) {
+			added = append(added, f)
+		}
+	}
+
+	pairs := make(map[string]string)
+	paired := make(map[string]bool)
+	a.renamesMu.Lock()
+	for newPath, oldPath := range a.renames {
+		if listed[newPath] && listed[oldPath] && a.exists(newPath) && !a.exists(oldPath) {
+			pairs[newPath] = oldPath
+			paired[oldPath], paired[newPath] = true, true
+		}
+	}
+	a.renamesMu.Unlock()
+
+	if a.manifest == nil {
+		return pairs
+	}
+	for _, oldPath := range deleted {
+		sum, ok := a.manifest.Hash(oldPath)
+		if !ok || paired[oldPath] {
+			continue
+		}
+		for _, newPath := range added {
+			if paired[newPath] {
+				continue
+			}
+			if s, err := hashFile(filepath.Join(a.workDir, newPath)); err == nil && s == sum {
+				pairs[newPath] = oldPath
+				paired[oldPath], paired[newPath] = true, true
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// classify returns the changes of a batch, in file order. The old path of a
+// rename is reported with the new one.
+func (a *Analyser) classify(files []string) []Change {
+	renames := a.findRenames(files)
+	renamedFrom := make(map[string]bool, len(renames))
+	for _, oldPath := range renames {
+		renamedFrom[oldPath] = true
+	}
+
+	changes := make([]Change, 0, len(files))
+	for _, f := range files {
+		switch {
+		case renamedFrom[f]:
+		case renames[f] != "":
+			changes = append(changes, Change{Path: f, Kind: ChangeRenamed, From: renames[f]})
+		case !a.exists(f):
+			changes = append(changes, Change{Path: f, Kind: ChangeDeleted})
+		case a.manifest != nil && !a.manifest.Has(f):
+			changes = append(changes, Change{Path: f, Kind: ChangeAdded})
+		default:
+			changes = append(changes, Change{Path: f, Kind: ChangeModified})
+		}
+	}
+	return changes
+}
+
+// forgetRenames drops the noted renames of analysed files
+func (a *Analyser) forgetRenames(files []string) {
+	a.renamesMu.Lock()
+	defer a.renamesMu.Unlock()
+	for _, f := range files {
+		delete(a.renames, f)
+	}
+}
+
+// exists reports whether a file (relative to the work directory) exists
+func (a *Analyser) exists(relFile string) bool {
+	_, err := os.Stat(filepath.Join(a.workDir, relFile))
+	return err == nil
+}
+
+// keepRenamesTogether moves the old path of each rename into the batch of
+// the new path, so the pair is analysed as one change
+func keepRenamesTogether(batches [][]string, renames map[string]string) [][]string {
+	if len(renames) == 0 || len(batches) < 2 {
+		return batches
+	}
+	batchOf := make(map[string]int)
+	for i, batch := range batches {
+		for _, f := range batch {
+			batchOf[f] = i
+		}
+	}
+	moved := make(map[string]int) // old path -> batch of the new path
+	for newPath, oldPath := range renames {
+		to, okNew := batchOf[newPath]
+		from, okOld := batchOf[oldPath]
+		if okNew && okOld && to != from {
+			moved[oldPath] = to
+		}
+	}
+	if len(moved) == 0 {
+		return batches
+	}
+
+	result := make([][]string, len(batches))
+	for i, batch := range batches {
+		for _, f := range batch {
+			if _, ok := moved[f]; !ok {
+				result[i] = append(result[i], f)
+			}
+		}
+	}
+	oldPaths := make([]string, 0, len(moved))
+	for oldPath := range moved {
+		oldPaths = append(oldPaths, oldPath)
+	}
+	sort.Strings(oldPaths)
+	for _, oldPath := range oldPaths {
+		result[moved[oldPath]] = append(result[moved[oldPath]], oldPath)
+	}
+	// A batch may have held only moved paths
+	return slices.DeleteFunc(result, func(b []string) bool { return len(b) == 0 })
+}
+
+// changesInfo lists the changes of a batch by kind for the prompt
+func changesInfo(changes []Change) string {
+	sections := []struct {
+		kind, title string
+	}{
+		{ChangeAdded, "Added"},
+		{ChangeModified, "Modified"},
+		{ChangeDeleted, "Deleted (these paths no longer exist: do not try to read them; remove or update what the index says about them)"},
+		{ChangeRenamed, "Renamed (old → new; update paths and names in the index, the content may also have changed)"},
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n## Changed Files\n\nPaths are relative to the working directory.")
+	for _, s := range sections {
+		var lines []string
+		for _, c := range changes {
+			if c.Kind != s.kind {
+				continue
+			}
+			if c.Kind == ChangeRenamed {
+				lines = append(lines, fmt.Sprintf("- %s → %s", c.From, c.Path))
+			} else {
+				lines = append(lines, "- "+c.Path)
+			}
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(&sb, "\n\n%s:\n%s", s.title, strings.Join(lines, "\n"))
+		}
+	}
+	return sb.String()
+}
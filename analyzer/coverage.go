@@ -0,0 +1,310 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// coverageMaxFileBytes is the size above which a file is not searched for
+// interface names
+const coverageMaxFileBytes = 1 << 20
+
+// Coverage maps the files of the project to the index entries mentioning them
+type Coverage struct {
+	Files         []FileCoverage `json:"files"`
+	Dirs          []DirCoverage  `json:"dirs"`
+	UncoveredDirs []string       `json:"uncovered_dirs"` // topmost directories without a covered file
+	Covered       int            `json:"covered"`
+	Total         int            `json:"total"`
+	Percent       float64        `json:"percent"`
+}
+
+// FileCoverage lists the index entries mentioning a file, such as
+// "issues.json: issues[2] Race in flush"
+type FileCoverage struct {
+	Path    string   `json:"path"` // relative to the work directory, slash-separated
+	Entries []string `json:"entries"`
+}
+
+// DirCoverage counts the covered files of a directory and its subdirectories
+type DirCoverage struct {
+	Path    string   `json:"path"`
+	Entries []string `json:"entries"` // modules named after the directory
+	Files   int      `json:"files"`
+	Covered int      `json:"covered"`
+}
+
+// Uncovered returns the files no index entry mentions
+func (c *Coverage) Uncovered() []string {
+	var files []string
+	for _, f := range c.Files {
+		if len(f.Entries) == 0 {
+			files = append(files, f.Path)
+		}
+	}
+	return files
+}
+
+// coverageName is an index entry matched against the project by name
+type coverageName struct {
+	entry string
+	name  string
+	words []*regexp.Regexp // interfaces only
+}
+
+// ComputeCoverage maps files (absolute paths under workDir) to the entries of
+// the index at indexDir that mention them: issue locations by path, modules
+// and submodules named after the file or a directory containing it, and
+// interfaces whose name appears in the file's content.
+func ComputeCoverage(indexDir, workDir string, files []string) (*Coverage, error) {
+	ix, errs := loadRuleIndex(indexDir, workDir)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to read index: %s", strings.Join(errs, "; "))
+	}
+
+	locations := make(map[string][]string)
+	for i, issue := range ix.issues.Issues {
+		entry := fmt.Sprintf("issues.json: issues[%d] %s", i, issue.Title)
+		for _, loc := range issue.Locations {
+			key := locationPath(workDir, loc.File)
+			locations[key] = appendUnique(locations[key], entry)
+		}
+	}
+	var modules []coverageName
+	for i, m := range ix.arch.Modules {
+		modules = append(modules, coverageName{entry: fmt.Sprintf("arch.json: modules[%d] %s", i, m.Name), name: coveragePath(m.Name)})
+		if m.Internal == nil {
+			continue
+		}
+		for j, s := range m.Internal.Submodules {
+			modules = append(modules, coverageName{entry: fmt.Sprintf("arch.json: modules[%d].internal.submodules[%d] %s", i, j, s.Name), name: coveragePath(s.Name)})
+		}
+	}
+	var ifaces []coverageName
+	for list, entries := range map[string][]ruleInterface{"external": ix.iface.External, "internal": ix.iface.Internal} {
+		for i, e := range entries {
+			if words := nameWords(e.Name); len(words) > 0 {
+				ifaces = append(ifaces, coverageName{entry: fmt.Sprintf("interface.json: %s[%d] %s", list, i, e.Name), words: words})
+			}
+		}
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].entry < ifaces[j].entry })
+
+	c := &Coverage{}
+	dirs := make(map[string]*DirCoverage)
+	for _, file := range files {
+		rel, err := filepath.Rel(workDir, file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		entries := append([]string(nil), locations[strings.ToLower(rel)]...)
+
+		// Modules named after the file, or after a directory containing it
+		for _, m := range modules {
+			if m.name != "" && pathNamed(rel, m.name, true) {
+				entries = appendUnique(entries, m.entry)
+			}
+		}
+		for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+			d := dirs[dir]
+			if d == nil {
+				d = &DirCoverage{Path: dir}
+				for _, m := range modules {
+					if dir != "." && m.name != "" && pathNamed(dir, m.name, false) {
+						d.Entries = append(d.Entries, m.entry)
+					}
+				}
+				dirs[dir] = d
+			}
+			for _, e := range d.Entries {
+				entries = appendUnique(entries, e)
+			}
+			if dir == "." {
+				break
+			}
+		}
+
+		if len(ifaces) > 0 {
+			if content := readText(file); content != nil {
+				for _, e := range ifaces {
+					for _, re := range e.words {
+						if re.Match(content) {
+							entries = appendUnique(entries, e.entry)
+							break
+						}
+					}
+				}
+			}
+		}
+
+		c.Files = append(c.Files, FileCoverage{Path: rel, Entries: entries})
+		covered := len(entries) > 0
+		if covered {
+			c.Covered++
+		}
+		for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+			dirs[dir].Files++
+			if covered {
+				dirs[dir].Covered++
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+	c.Total = len(c.Files)
+	if c.Total > 0 {
+		c.Percent = float64(c.Covered) * 100 / float64(c.Total)
+	}
+	sort.Slice(c.Files, func(i, j int) bool { return c.Files[i].Path < c.Files[j].Path })
+
+	for _, d := range dirs {
+		c.Dirs = append(c.Dirs, *d)
+	}
+	sort.Slice(c.Dirs, func(i, j int) bool { return c.Dirs[i].Path < c.Dirs[j].Path })
+	for _, d := range c.Dirs {
+		if d.Path == "." || d.Covered > 0 {
+			continue
+		}
+		if parent := path.Dir(d.Path); parent == "." || dirs[parent].Covered > 0 {
+			c.UncoveredDirs = append(c.UncoveredDirs, d.Path)
+		}
+	}
+	return c, nil
+}
+
+// coveragePath normalises a path or name from the index for comparison
+func coveragePath(p string) string {
+	p = strings.ToLower(strings.TrimSpace(filepath.ToSlash(p)))
+	if p == "" {
+		return ""
+	}
+	return strings.Trim(path.Clean(p), "/")
+}
+
+// locationPath normalises the file of an issue location for comparison with
+// lowercased paths relative to workDir
+func locationPath(workDir, file string) string {
+	if rel, err := filepath.Rel(workDir, file); err == nil && filepath.IsAbs(file) {
+		file = rel
+	}
+	return coveragePath(file)
+}
+
+// moduleCovers reports whether a module or submodule name (see coveragePath)
+// covers rel: the file or a directory containing it is named after it
+func moduleCovers(rel, name string) bool {
+	if name == "" {
+		return false
+	}
+	if pathNamed(rel, name, true) {
+		return true
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if pathNamed(dir, name, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathNamed reports whether name is rel itself or its base name; for files
+// the base name without extension matches too
+func pathNamed(rel, name string, isFile bool) bool {
+	rel = strings.ToLower(rel)
+	base := path.Base(rel)
+	if rel == name || base == name {
+		return true
+	}
+	return isFile && strings.TrimSuffix(base, path.Ext(base)) == name
+}
+
+// nameWords returns patterns finding an interface name in source code: the
+// name itself and, for qualified names like Watcher.Flush, its last part.
+// Parts shorter than 3 characters are too ambiguous to look for.
+func nameWords(name string) []*regexp.Regexp {
+	name = strings.TrimSpace(name)
+	candidates := []string{name}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		candidates = append(candidates, name[i+1:])
+	}
+	var words []*regexp.Regexp
+	for _, c := range candidates {
+		if len(c) < 3 {
+			continue
+		}
+		expr := regexp.QuoteMeta(c)
+		if isWordByte(c[0]) {
+			expr = `\b` + expr
+		}
+		if isWordByte(c[len(c)-1]) {
+			expr += `\b`
+		}
+		words = append(words, regexp.MustCompile(expr))
+	}
+	return words
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// TextFiles returns the readable text files among files. Binaries such as
+// images and archives are not analysed, so coverage leaves them out.
+func TextFiles(files []string) []string {
+	var text []string
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		head := make([]byte, binarySniffBytes)
+		n, err := io.ReadFull(f, head)
+		f.Close()
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			continue
+		}
+		if !isBinary(head[:n]) {
+			text = append(text, file)
+		}
+	}
+	return text
+}
+
+// binarySniffBytes is how much of a file isBinary looks at
+const binarySniffBytes = 8000
+
+// isBinary reports whether data, the start of a file, holds a NUL byte
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffBytes)], 0) >= 0
+}
+
+// readText returns the content of a text file, or nil for binary, large or
+// unreadable files
+func readText(file string) []byte {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > coverageMaxFileBytes {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || isBinary(data) {
+		return nil
+	}
+	return data
+}
+
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
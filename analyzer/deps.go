@@ -0,0 +1,331 @@
+package analyzer
+
+import (
+	"bufio"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Batch strategies
+const (
+	BatchByDirectory = "directory" // group by directory tree (default)
+	BatchByDeps      = "deps"      // cluster files connected by imports
+)
+
+// depEdge links two files (relative paths) that should be analysed together
+type depEdge struct {
+	a, b string
+}
+
+// splitByDeps clusters files connected by edges into groups no heavier than
+// budget, then packs the groups into batches. Edges are applied in order, so
+// earlier (stronger) edges win when the budget forces a choice. Files left
+// unconnected fall back to directory grouping.
+func splitByDeps(files []string, budget int, weight func(string) int, edges []depEdge) [][]string {
+	if totalWeight(files, weight) <= budget {
+		return [][]string{files}
+	}
+
+	// Union-find with component weights
+	parent := make(map[string]string, len(files))
+	compWeight := make(map[string]int, len(files))
+	for _, f := range files {
+		parent[f] = f
+		compWeight[f] = weight(f)
+	}
+	var find func(string) string
+	find = func(f string) string {
+		if parent[f] != f {
+			parent[f] = find(parent[f])
+		}
+		return parent[f]
+	}
+	for _, e := range edges {
+		if _, ok := parent[e.a]; !ok {
+			continue
+		}
+		if _, ok := parent[e.b]; !ok {
+			continue
+		}
+		ra, rb := find(e.a), find(e.b)
+		if ra == rb || compWeight[ra]+compWeight[rb] > budget {
+			continue
+		}
+		parent[rb] = ra
+		compWeight[ra] += compWeight[rb]
+	}
+
+	components := make(map[string][]string)
+	var singles []string
+	for _, f := range files {
+		root := find(f)
+		components[root] = append(components[root], f)
+	}
+	var groups [][]string
+	for _, comp := range components {
+		if len(comp) == 1 {
+			singles = append(singles, comp[0])
+			continue
+		}
+		sort.Strings(comp)
+		groups = append(groups, comp)
+	}
+	groups = append(groups, groupFiles(singles, budget, weight)...)
+
+	// Pack in path order of each group's first file
+	sort.Slice(groups, func(i, j int) bool {
+		return minPath(groups[i]) < minPath(groups[j])
+	})
+	return packGroups(groups, budget, weight)
+}
+
+func minPath(files []string) string {
+	m := files[0]
+	for _, f := range files[1:] {
+		if f < m {
+			m = f
+		}
+	}
+	return m
+}
+
+// importGraph returns the dependency edges among files (relative to workDir):
+// Go files of the same package first, then resolved imports of Go, Python and
+// TypeScript/JavaScript files. Imports of files outside the set are ignored.
+func importGraph(workDir string, files []string) []depEdge {
+	inSet := make(map[string]bool, len(files))
+	byDir := make(map[string][]string)
+	for _, f := range files {
+		f = filepath.ToSlash(f)
+		inSet[f] = true
+		if strings.HasSuffix(f, ".go") {
+			byDir[path.Dir(f)] = append(byDir[path.Dir(f)], f)
+		}
+	}
+
+	var pkgEdges, importEdges []depEdge
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		pkg := byDir[dir]
+		sort.Strings(pkg)
+		for i := 1; i < len(pkg); i++ {
+			pkgEdges = append(pkgEdges, depEdge{pkg[0], pkg[i]})
+		}
+	}
+
+	modules := newGoModules(workDir)
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	for _, f := range sorted {
+		f = filepath.ToSlash(f)
+		var targets []string
+		switch ext := path.Ext(f); ext {
+		case ".go":
+			for _, dir := range modules.importDirs(f) {
+				targets = append(targets, byDir[dir]...)
+			}
+		case ".py":
+			targets = pythonImports(workDir, f, inSet)
+		case ".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs":
+			targets = scriptImports(workDir, f, inSet)
+		}
+		for _, t := range targets {
+			if t != f && inSet[t] {
+				importEdges = append(importEdges, depEdge{f, t})
+			}
+		}
+	}
+
+	edges := make([]depEdge, 0, len(pkgEdges)+len(importEdges))
+	for _, e := range append(pkgEdges, importEdges...) {
+		// Edges use the caller's path separators
+		edges = append(edges, depEdge{filepath.FromSlash(e.a), filepath.FromSlash(e.b)})
+	}
+	return edges
+}
+
+// ============== Go ==============
+
+// goModules resolves Go import paths to directories using the nearest go.mod
+type goModules struct {
+	workDir string
+	cache   map[string]goModule // directory -> enclosing module
+}
+
+type goModule struct {
+	path string // module path
+	dir  string // module root, relative to workDir ("." for the root)
+}
+
+func newGoModules(workDir string) *goModules {
+	return &goModules{workDir: workDir, cache: make(map[string]goModule)}
+}
+
+// importDirs returns the directories (relative to workDir) of the packages
+// imported by a Go file that belong to the file's module
+func (m *goModules) importDirs(file string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(m.workDir, filepath.FromSlash(file)), nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	mod := m.moduleFor(path.Dir(file))
+	if mod.path == "" {
+		return nil
+	}
+	var dirs []string
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if p == mod.path {
+			dirs = append(dirs, mod.dir)
+		} else if strings.HasPrefix(p, mod.path+"/") {
+			dirs = append(dirs, path.Join(mod.dir, strings.TrimPrefix(p, mod.path+"/")))
+		}
+	}
+	return dirs
+}
+
+// moduleFor finds the module enclosing dir by walking up to the nearest go.mod
+func (m *goModules) moduleFor(dir string) goModule {
+	if mod, ok := m.cache[dir]; ok {
+		return mod
+	}
+	var mod goModule
+	if p := readModulePath(filepath.Join(m.workDir, filepath.FromSlash(dir), "go.mod")); p != "" {
+		mod = goModule{path: p, dir: dir}
+	} else if dir != "." {
+		mod = m.moduleFor(path.Dir(dir))
+	}
+	m.cache[dir] = mod
+	return mod
+}
+
+// readModulePath returns the module path declared in a go.mod file
+func readModulePath(goMod string) string {
+	f, err := os.Open(goMod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+// ============== Python ==============
+
+var pythonImportRe = regexp.MustCompile(`(?m)^\s*(?:from\s+(\.*[\w.]*)\s+import\s+([\w., ]+)|import\s+([\w., ]+))`)
+
+// pythonImports resolves `import a.b` and `from .a import b` statements to files in the set
+func pythonImports(workDir, file string, inSet map[string]bool) []string {
+	data, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil
+	}
+	var targets []string
+	for _, m := range pythonImportRe.FindAllStringSubmatch(string(data), -1) {
+		var modules []string
+		if m[1] != "" {
+			modules = append(modules, m[1])
+			// from pkg import mod: mod may itself be a module
+			for _, name := range strings.Split(m[2], ",") {
+				if fields := strings.Fields(name); len(fields) > 0 {
+					modules = append(modules, strings.TrimSuffix(m[1], ".")+"."+fields[0])
+				}
+			}
+		} else {
+			for _, name := range strings.Split(m[3], ",") {
+				if fields := strings.Fields(name); len(fields) > 0 {
+					modules = append(modules, fields[0])
+				}
+			}
+		}
+		for _, mod := range modules {
+			if t := resolvePythonModule(file, mod, inSet); t != "" {
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets
+}
+
+// resolvePythonModule maps a dotted module name to a file in the set. Relative
+// names resolve against the importing file's package, absolute names against
+// the work directory and the importing file's directory.
+func resolvePythonModule(file, module string, inSet map[string]bool) string {
+	dots := len(module) - len(strings.TrimLeft(module, "."))
+	name := strings.ReplaceAll(strings.TrimLeft(module, "."), ".", "/")
+
+	var bases []string
+	if dots > 0 {
+		base := path.Dir(file)
+		for i := 1; i < dots; i++ {
+			base = path.Dir(base)
+		}
+		bases = []string{base}
+	} else {
+		bases = []string{".", path.Dir(file)}
+	}
+	for _, base := range bases {
+		p := path.Join(base, name)
+		for _, candidate := range []string{p + ".py", path.Join(p, "__init__.py")} {
+			if inSet[candidate] {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// ============== TypeScript / JavaScript ==============
+
+var scriptImportRe = regexp.MustCompile(`(?:\bfrom\s*|\bimport\s*\(?\s*|\brequire\s*\(\s*)['"](\.{1,2}/[^'"]*)['"]`)
+
+var scriptExtensions = []string{"", ".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", "/index.ts", "/index.tsx", "/index.js", "/index.jsx"}
+
+// scriptImports resolves relative import/require specifiers to files in the set
+func scriptImports(workDir, file string, inSet map[string]bool) []string {
+	data, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil
+	}
+	var targets []string
+	for _, m := range scriptImportRe.FindAllStringSubmatch(string(data), -1) {
+		p := path.Join(path.Dir(file), m[1])
+		// Compiled-extension imports (./a.js) may refer to TypeScript sources
+		stem := strings.TrimSuffix(p, path.Ext(p))
+		for _, candidate := range append(withExtensions(p), withExtensions(stem)...) {
+			if inSet[candidate] {
+				targets = append(targets, candidate)
+				break
+			}
+		}
+	}
+	return targets
+}
+
+func withExtensions(p string) []string {
+	out := make([]string, len(scriptExtensions))
+	for i, ext := range scriptExtensions {
+		out[i] = p + ext
+	}
+	return out
+}
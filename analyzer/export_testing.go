@@ -10,8 +10,18 @@ var (
 	// Analyser exports
 	GenerateSessionID = generateSessionID
 	ToRelativePaths   = toRelativePaths
-	SplitIntoBatches  = splitIntoBatches
-	LoadPrompt        = loadPrompt
+	SplitIntoBatches  = func(files []string, threshold int) [][]string {
+		return splitIntoBatches(files, threshold, func(string) int { return 1 })
+	}
+	SplitIntoBatchesByWeight = splitIntoBatches
+	SplitByDeps              = func(workDir string, files []string, budget int, weight func(string) int) [][]string {
+		return splitByDeps(files, budget, weight, importGraph(workDir, files))
+	}
+	LoadPrompt     = loadPrompt
+	MergeFragments = func(baseDir string, fragDirs []string, outDir string) error {
+		return mergeFragments(baseDir, fragDirs, outDir, mergeKeys)
+	}
+	KeepRenamesTogether = keepRenamesTogether
 
 	// Banner exports
 	GetGreeting  = getGreeting
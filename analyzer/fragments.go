@@ -0,0 +1,310 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// fragmentsDirName is the directory under .memo holding per-batch index fragments
+const fragmentsDirName = "fragments"
+
+// mergeKeys identifies entries of the top-level arrays in each built-in index
+// file. Entries with the same key in different fragments are the same entry.
+var mergeKeys = map[string]map[string][]string{
+	"arch.json":      {"modules": {"name"}},
+	"interface.json": {"external": {"type", "name"}, "internal": {"type", "name"}},
+	"stories.json":   {"stories": {"title"}},
+	"issues.json":    {"issues": {"title"}},
+}
+
+// runParallel analyses batches concurrently. Each batch works on a private copy
+// of the index (a fragment) in its own session; successful fragments are then
+// merged into the index and the result is validated once. Returns the batches
+// committed to the index, also when others failed.
+func (a *Analyser) runParallel(ctx context.Context, batches [][]string) ([][]string, error) {
+	runDir := a.scratchDir(fragmentsDirName)
+	if err := os.RemoveAll(runDir); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(runDir)
+
+	// Snapshot the index so every fragment starts from the same base
+	baseDir := filepath.Join(runDir, "base")
+	if err := internal.CopyDir(a.indexDir, baseDir); err != nil {
+		return nil, fmt.Errorf("failed to snapshot index: %w", err)
+	}
+	fragDirs := make([]string, len(batches))
+	reads := make([]map[string]string, len(batches))
+	for i := range batches {
+		reads[i] = HashFiles(a.workDir, batches[i])
+		fragDirs[i] = filepath.Join(runDir, fmt.Sprintf("batch-%03d", i+1))
+		if err := internal.CopyDir(baseDir, fragDirs[i]); err != nil {
+			return nil, fmt.Errorf("failed to create fragment: %w", err)
+		}
+	}
+
+	workers := min(a.workers, len(batches))
+	internal.LogInfo("Analysing %d batches with %d workers", len(batches), workers)
+
+	errs := make([]error, len(batches))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Parallel batches must not share a session
+				sessionID := fmt.Sprintf("%s-b%d", a.sessionID, i+1)
+				errs[i] = a.analyseBatch(ctx, batches[i], i+1, len(batches), fragDirs[i], sessionID)
+			}
+		}()
+	}
+	for i := range batches {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Merge what succeeded; a failed batch does not discard the others' work
+	var done []string
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("batch %d/%d failed: %w", i+1, len(batches), err)
+			}
+			continue
+		}
+		done = append(done, fragDirs[i])
+	}
+	if len(done) == 0 {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mergedDir := filepath.Join(runDir, "merged")
+	if err := mergeFragments(baseDir, done, mergedDir, a.indexFiles.mergeKeys); err != nil {
+		return nil, fmt.Errorf("failed to merge index fragments: %w", err)
+	}
+	result := a.indexFiles.Validate(mergedDir)
+	if !result.Valid {
+		return nil, fmt.Errorf("merged index failed validation: %s", FormatValidationErrors(result))
+	}
+	if err := commitIndex(mergedDir, a.indexDir); err != nil {
+		return nil, fmt.Errorf("failed to commit merged index: %w", err)
+	}
+	internal.LogInfo("Merged %d of %d fragments into index", len(done), len(batches))
+	base := readIndexFiles(baseDir)
+	var updates []provenanceUpdate
+	var committed [][]string
+	for i, err := range errs {
+		if err == nil {
+			committed = append(committed, batches[i])
+			updates = append(updates, provenanceUpdate{files: batches[i], read: reads[i], before: base, after: readIndexFiles(fragDirs[i])})
+			a.batchDone(batches[i], reads[i])
+		}
+	}
+	a.recordProvenance(updates...)
+
+	return committed, firstErr
+}
+
+// ============== Merge ==============
+
+// mergeFragments applies the changes each fragment made relative to baseDir,
+// in order, and writes the combined index files to outDir. Array entries are
+// merged by the keys of their file (see mergeKeys); other values take the
+// last changed version.
+func mergeFragments(baseDir string, fragDirs []string, outDir string, keys map[string]map[string][]string) error {
+	base := readIndexFiles(baseDir)
+	names := make(map[string]struct{})
+	for name := range base {
+		names[name] = struct{}{}
+	}
+	frags := make([]map[string]string, len(fragDirs))
+	for i, dir := range fragDirs {
+		frags[i] = readIndexFiles(dir)
+		for name := range frags[i] {
+			names[name] = struct{}{}
+		}
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	for name := range names {
+		merged := base[name]
+		for i, frag := range frags {
+			content, ok := frag[name]
+			if !ok || content == base[name] {
+				continue
+			}
+			var err error
+			merged, err = mergeFile(keys[name], base[name], merged, content)
+			if err != nil {
+				return fmt.Errorf("%s (%s): %w", name, filepath.Base(fragDirs[i]), err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(outDir, name), []byte(merged), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFile applies the change base → frag onto merged
+func mergeFile(keys map[string][]string, base, merged, frag string) (string, error) {
+	if merged == base {
+		return frag, nil // first change to this file, keep it verbatim
+	}
+	var baseObj, mergedObj, fragObj map[string]json.RawMessage
+	if err := decodeObject(base, &baseObj); err != nil {
+		return "", err
+	}
+	if err := decodeObject(merged, &mergedObj); err != nil {
+		return "", err
+	}
+	if err := decodeObject(frag, &fragObj); err != nil {
+		return "", err
+	}
+
+	for field, fragVal := range fragObj {
+		baseVal, inBase := baseObj[field]
+		if inBase && jsonEqual(baseVal, fragVal) {
+			continue
+		}
+		if keys, ok := keys[field]; ok {
+			val, err := mergeEntries(keys, baseVal, mergedObj[field], fragVal)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", field, err)
+			}
+			mergedObj[field] = val
+		} else {
+			mergedObj[field] = fragVal
+		}
+	}
+	for field := range baseObj {
+		if _, ok := fragObj[field]; !ok {
+			delete(mergedObj, field)
+		}
+	}
+
+	data, err := json.MarshalIndent(mergedObj, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// mergeEntries applies the entry additions, modifications and deletions
+// between base and frag onto merged
+func mergeEntries(keys []string, base, merged, frag json.RawMessage) (json.RawMessage, error) {
+	var baseList, mergedList, fragList []json.RawMessage
+	for _, v := range []struct {
+		raw  json.RawMessage
+		list *[]json.RawMessage
+	}{{base, &baseList}, {merged, &mergedList}, {frag, &fragList}} {
+		if len(v.raw) == 0 || string(v.raw) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(v.raw, v.list); err != nil {
+			return nil, err
+		}
+	}
+
+	baseByKey := make(map[string]json.RawMessage)
+	for _, e := range baseList {
+		baseByKey[entryKey(keys, e)] = e
+	}
+	fragByKey := make(map[string]json.RawMessage)
+	for _, e := range fragList {
+		fragByKey[entryKey(keys, e)] = e
+	}
+
+	// Deletions and modifications, keeping the merged order
+	result := make([]json.RawMessage, 0, len(mergedList)+len(fragList))
+	seen := make(map[string]bool)
+	for _, e := range mergedList {
+		key := entryKey(keys, e)
+		seen[key] = true
+		baseEntry, inBase := baseByKey[key]
+		fragEntry, inFrag := fragByKey[key]
+		switch {
+		case inBase && !inFrag:
+			continue // deleted by this fragment
+		case inFrag && (!inBase || !jsonEqual(baseEntry, fragEntry)):
+			result = append(result, fragEntry)
+		default:
+			result = append(result, e)
+		}
+	}
+	// Additions
+	for _, e := range fragList {
+		key := entryKey(keys, e)
+		if _, inBase := baseByKey[key]; inBase || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, e)
+	}
+
+	return json.Marshal(result)
+}
+
+// entryKey returns the identity of an array entry; entries lacking the key
+// fields are identified by their content
+func entryKey(keys []string, entry json.RawMessage) string {
+	var obj map[string]any
+	if err := json.Unmarshal(entry, &obj); err == nil {
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if s, ok := obj[k].(string); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) == len(keys) {
+			return strings.Join(parts, "\x00")
+		}
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, entry); err != nil {
+		return string(entry)
+	}
+	return "\x01" + buf.String()
+}
+
+// decodeObject decodes a JSON object; empty content decodes to an empty object
+func decodeObject(content string, obj *map[string]json.RawMessage) error {
+	*obj = make(map[string]json.RawMessage)
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(content), obj); err != nil {
+		return err
+	}
+	if *obj == nil {
+		*obj = make(map[string]json.RawMessage)
+	}
+	return nil
+}
+
+// jsonEqual reports whether two JSON values are semantically equal
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
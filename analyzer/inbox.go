@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// inboxFileName lists files queued for analysis by other processes (git
+// hooks), one path relative to the work directory per line
+const inboxFileName = "inbox"
+
+// PollInterval is how often a running watcher checks the inbox and git HEAD
+const PollInterval = 2 * time.Second
+
+// AppendInbox queues files (relative to the work directory) for the next
+// analysis of whichever process holds the lock
+func AppendInbox(memoDir string, relFiles []string) error {
+	if len(relFiles) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(memoDir, inboxFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open inbox: %w", err)
+	}
+	defer f.Close()
+	var sb strings.Builder
+	for _, rel := range relFiles {
+		sb.WriteString(filepath.ToSlash(rel) + "\n")
+	}
+	if _, err := f.WriteString(sb.String()); err != nil {
+		return fmt.Errorf("failed to write inbox: %w", err)
+	}
+	return nil
+}
+
+// DrainInbox returns and removes the queued files (relative paths, without
+// duplicates). Files queued while draining are kept for the next call.
+func DrainInbox(memoDir string) ([]string, error) {
+	path := filepath.Join(memoDir, inboxFileName)
+	draining := path + ".draining"
+	if err := os.Rename(path, draining); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(draining)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(draining)
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		files = append(files, filepath.FromSlash(line))
+	}
+	return files, nil
+}
+
+// ScanInbox drains the inbox and adds the queued files that changed since
+// they were last analysed to pending, returning how many were added
+func (w *Watcher) ScanInbox(m *Manifest) int {
+	files, err := DrainInbox(filepath.Join(w.rootPath, ".memo"))
+	if err != nil {
+		internal.LogError("Failed to read inbox: %v", err)
+		return 0
+	}
+	if len(files) == 0 {
+		return 0
+	}
+
+	// Deleted files are reported by Changed if they were analysed before
+	var existing []string
+	for _, rel := range files {
+		path := filepath.Join(w.rootPath, rel)
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+	added := 0
+	for _, p := range m.Changed(existing) {
+		if !w.ignored(p) {
+			w.add(p)
+			added++
+		}
+	}
+	internal.LogInfo("Inbox: %d of %d queued files changed since last analysis", added, len(files))
+	return added
+}
+
+// WatchInbox checks the inbox every interval until ctx is done
+func (w *Watcher) WatchInbox(ctx context.Context, m *Manifest, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.ScanInbox(m)
+		}
+	}
+}
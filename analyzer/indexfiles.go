@@ -0,0 +1,156 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// IndexFile is a project-defined index file, declared in config next to the
+// built-in arch, interface, stories and issues files
+type IndexFile struct {
+	Name      string              `json:"name"`                 // file name in .memo/index, e.g. decisions.json
+	Schema    string              `json:"schema"`               // JSON Schema the file must conform to
+	Prompt    string              `json:"prompt,omitempty"`     // what the agent should record in the file
+	Initial   string              `json:"initial,omitempty"`    // content of a new index; derived from the schema if ""
+	MergeKeys map[string][]string `json:"merge_keys,omitempty"` // entry keys of top-level arrays, for merging parallel batches
+}
+
+// indexFileName is a valid index file name; the base name is its MCP path segment
+var indexFileName = regexp.MustCompile(`^[a-z0-9_-]+\.json$`)
+
+// IndexFiles is the set of files an index consists of: the built-in arch,
+// interface, stories and issues files and any project-defined ones. It tells
+// the analyser what to create, validate, merge and describe to the agent.
+type IndexFiles struct {
+	custom    []IndexFile
+	schemas   map[string]string
+	initial   map[string]string
+	mergeKeys map[string]map[string][]string
+}
+
+// builtinIndexFiles is an index without project-defined files
+var builtinIndexFiles = &IndexFiles{schemas: schemas, initial: DefaultIndexFiles, mergeKeys: mergeKeys}
+
+// NewIndexFiles returns the built-in index files plus the project-defined
+// ones, in config order
+func NewIndexFiles(custom []IndexFile) (*IndexFiles, error) {
+	custom = append([]IndexFile(nil), custom...)
+	seen := make(map[string]bool)
+	for i := range custom {
+		f := &custom[i]
+		if !indexFileName.MatchString(f.Name) {
+			return nil, fmt.Errorf("invalid index file name: %q (want lowercase name.json)", f.Name)
+		}
+		if f.Name == indexMetaName {
+			return nil, fmt.Errorf("index file name %s is reserved", f.Name)
+		}
+		if _, builtin := schemas[f.Name]; seen[f.Name] || builtin {
+			return nil, fmt.Errorf("duplicate index file: %s", f.Name)
+		}
+		seen[f.Name] = true
+
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(f.Schema))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid schema: %w", f.Name, err)
+		}
+		if f.Initial == "" {
+			if f.Initial, err = initialFromSchema(f.Schema); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		result, err := schema.Validate(gojsonschema.NewStringLoader(f.Initial))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid initial content: %w", f.Name, err)
+		}
+		if !result.Valid() {
+			return nil, fmt.Errorf("%s: initial content does not match the schema: %s", f.Name, result.Errors()[0])
+		}
+	}
+
+	files := &IndexFiles{
+		custom:    custom,
+		schemas:   maps.Clone(schemas),
+		initial:   maps.Clone(DefaultIndexFiles),
+		mergeKeys: maps.Clone(mergeKeys),
+	}
+	for _, f := range custom {
+		files.schemas[f.Name] = f.Schema
+		files.initial[f.Name] = f.Initial
+		if len(f.MergeKeys) > 0 {
+			files.mergeKeys[f.Name] = f.MergeKeys
+		}
+	}
+	return files, nil
+}
+
+// Custom returns the project-defined index files
+func (f *IndexFiles) Custom() []IndexFile {
+	return f.custom
+}
+
+// Initial returns the content of a new, empty index (name -> content)
+func (f *IndexFiles) Initial() map[string]string {
+	return maps.Clone(f.initial)
+}
+
+// initialFromSchema builds the smallest document with the schema's required
+// top-level properties: empty arrays, objects and strings, zero numbers
+func initialFromSchema(schemaJSON string) (string, error) {
+	var schema struct {
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		return "", fmt.Errorf("invalid schema: %w", err)
+	}
+	doc := make(map[string]any)
+	for _, key := range schema.Required {
+		switch schema.Properties[key].Type {
+		case "array":
+			doc[key] = []any{}
+		case "object":
+			doc[key] = map[string]any{}
+		case "string":
+			doc[key] = ""
+		case "number", "integer":
+			doc[key] = 0
+		case "boolean":
+			doc[key] = false
+		default:
+			return "", fmt.Errorf("cannot derive initial content for property %q, set it explicitly", key)
+		}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// prompt describes the project-defined index files to the agent
+func (f *IndexFiles) prompt() string {
+	if len(f.custom) == 0 {
+		return ""
+	}
+	files := append([]IndexFile(nil), f.custom...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	var sb strings.Builder
+	sb.WriteString("\n\n## Project Index Files\n\nThis project keeps additional index files. Maintain them like the files above.\n")
+	for _, f := range files {
+		fmt.Fprintf(&sb, "\n### %s\n\n", f.Name)
+		if f.Prompt != "" {
+			sb.WriteString(strings.TrimSpace(f.Prompt) + "\n\n")
+		}
+		fmt.Fprintf(&sb, "Schema:\n```json\n%s\n```\n", strings.TrimSpace(f.Schema))
+	}
+	return sb.String()
+}
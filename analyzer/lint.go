@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Lint severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityOff     = "off"
+)
+
+// Lint rules besides the semantic rules, which lint under their own names
+const (
+	LintSchema          = "schema"
+	LintSize            = "size"
+	LintRequiredModules = "required-modules"
+)
+
+// LintOptions configures LintIndex
+type LintOptions struct {
+	Severity        map[string]string // per rule name; rules not listed are errors
+	MaxFileBytes    int64             // size limit of each index file; 0 means none
+	RequiredModules []string          // module names arch.json must contain
+	IndexFiles      *IndexFiles       // files the index consists of; built-in ones if nil
+}
+
+// LintFinding is a problem found by LintIndex
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"` // index file, e.g. arch.json
+	Message  string `json:"message"`
+}
+
+// LintRules returns the names of all lint rules
+func LintRules() []string {
+	names := []string{LintSchema}
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	return append(names, LintSize, LintRequiredModules)
+}
+
+// LintIndex checks the index with the schema, the semantic rules, the size
+// limit and the required modules, without involving the agent. Semantic
+// rules only run on a schema-valid index. Findings are sorted by file.
+func LintIndex(indexDir, workDir string, opts LintOptions) ([]LintFinding, error) {
+	known := LintRules()
+	for name, severity := range opts.Severity {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown lint rule: %q (available: %s)", name, strings.Join(known, ", "))
+		}
+		if severity != SeverityError && severity != SeverityWarning && severity != SeverityOff {
+			return nil, fmt.Errorf("lint rule %s: unknown severity %q (want %s, %s or %s)", name, severity, SeverityError, SeverityWarning, SeverityOff)
+		}
+	}
+	severity := func(rule string) string {
+		if s, ok := opts.Severity[rule]; ok {
+			return s
+		}
+		return SeverityError
+	}
+
+	var findings []LintFinding
+	add := func(rule string, problems []string) {
+		s := severity(rule)
+		if s == SeverityOff {
+			return
+		}
+		for _, p := range problems {
+			file, msg, ok := strings.Cut(p, ": ")
+			if !ok {
+				file, msg = "", p
+			}
+			findings = append(findings, LintFinding{Rule: rule, Severity: s, File: file, Message: msg})
+		}
+	}
+
+	index := opts.IndexFiles
+	if index == nil {
+		index = builtinIndexFiles
+	}
+	schema := index.Validate(indexDir)
+	add(LintSchema, schema.Errors)
+	if schema.Valid {
+		if ix, errs := loadRuleIndex(indexDir, workDir); len(errs) > 0 {
+			add(LintSchema, errs)
+		} else {
+			for _, r := range rules {
+				if severity(r.Name) != SeverityOff {
+					add(r.Name, r.Check(ix))
+				}
+			}
+			add(LintRequiredModules, missingModules(ix, opts.RequiredModules))
+		}
+	}
+	add(LintSize, oversizedFiles(indexDir, opts.MaxFileBytes))
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].File < findings[j].File })
+	return findings, nil
+}
+
+// missingModules reports required module names not in arch.json
+func missingModules(ix *ruleIndex, required []string) []string {
+	present := make(map[string]bool, len(ix.arch.Modules))
+	for _, m := range ix.arch.Modules {
+		present[m.Name] = true
+	}
+	var errs []string
+	for _, name := range required {
+		if !present[name] {
+			errs = append(errs, fmt.Sprintf("arch.json: required module %q is missing", name))
+		}
+	}
+	return errs
+}
+
+// oversizedFiles reports index files larger than limit bytes
+func oversizedFiles(indexDir string, limit int64) []string {
+	if limit <= 0 {
+		return nil
+	}
+	matches, _ := filepath.Glob(filepath.Join(indexDir, "*.json"))
+	var errs []string
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err == nil && info.Size() > limit {
+			errs = append(errs, fmt.Sprintf("%s: %d KB exceeds the limit of %d KB; split or condense its entries", filepath.Base(path), info.Size()/1024, limit/1024))
+		}
+	}
+	return errs
+}
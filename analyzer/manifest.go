@@ -0,0 +1,245 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+const manifestFileName = "manifest.json"
+
+// ManifestEntry records the state of a source file when it was last analysed
+type ManifestEntry struct {
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Manifest tracks the content of every analysed file (.memo/manifest.json),
+// so a restart only re-analyses files that changed in the meantime.
+// Paths are relative to the work directory, with forward slashes.
+type Manifest struct {
+	path       string
+	workDir    string
+	objectsDir string // last-analysed file contents (.memo/objects)
+
+	mu    sync.Mutex
+	files map[string]ManifestEntry
+}
+
+// LoadManifest reads .memo/manifest.json; a missing file yields an empty manifest
+func LoadManifest(workDir string) (*Manifest, error) {
+	m := &Manifest{
+		path:       filepath.Join(workDir, ".memo", manifestFileName),
+		workDir:    workDir,
+		objectsDir: filepath.Join(workDir, ".memo", objectsDirName),
+		files:      make(map[string]ManifestEntry),
+	}
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m.files); err != nil {
+		m.files = make(map[string]ManifestEntry)
+		return m, err
+	}
+	return m, nil
+}
+
+// Reload rereads .memo/manifest.json, after it was replaced on disk
+func (m *Manifest) Reload() error {
+	fresh, err := LoadManifest(m.workDir)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files = fresh.files
+	return err
+}
+
+// Len returns the number of files in the manifest
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.files)
+}
+
+// Has reports whether a file (relative to the work directory) was recorded
+func (m *Manifest) Has(relFile string) bool {
+	_, ok := m.Hash(relFile)
+	return ok
+}
+
+// Hash returns the recorded SHA-256 of a file (relative to the work directory)
+func (m *Manifest) Hash(relFile string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.files[filepath.ToSlash(relFile)]
+	return entry.SHA256, ok
+}
+
+// Changed returns the files that are new or modified since they were recorded,
+// followed by recorded files that no longer exist. files and the result are
+// absolute paths.
+func (m *Manifest) Changed(files []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changed []string
+	seen := make(map[string]bool, len(files))
+	for _, path := range files {
+		key := m.key(path)
+		seen[key] = true
+		entry, ok := m.files[key]
+		if !ok {
+			changed = append(changed, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			changed = append(changed, path)
+			continue
+		}
+		if info.Size() == entry.Size && info.ModTime().Equal(entry.ModTime) {
+			continue
+		}
+		if sum, err := hashFile(path); err != nil || sum != entry.SHA256 {
+			changed = append(changed, path)
+		}
+	}
+
+	// Deleted files (ignored files that still exist are not reported)
+	for key := range m.files {
+		if seen[key] {
+			continue
+		}
+		path := filepath.Join(m.workDir, filepath.FromSlash(key))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// Update records the current state of the given files (relative to the work
+// directory) and keeps their content for diffs; files that no longer exist
+// are removed from the manifest
+func (m *Manifest) Update(relFiles []string) {
+	m.UpdateRead(relFiles, nil)
+}
+
+// UpdateRead is Update for files analysed as they were when read (see
+// HashFiles): files that changed since are left as they were recorded, so
+// the next scan analyses them again
+func (m *Manifest) UpdateRead(relFiles []string, read map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var replaced []string
+	for _, rel := range relFiles {
+		key := filepath.ToSlash(rel)
+		path := filepath.Join(m.workDir, rel)
+		entry, ok := m.files[key]
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			if read != nil && read[rel] != "" {
+				internal.LogInfo("%s was deleted during analysis, it will be analysed again", rel)
+				continue
+			}
+			if ok {
+				replaced = append(replaced, entry.SHA256)
+			}
+			delete(m.files, key)
+			continue
+		}
+		if ok && info.Size() == entry.Size && info.ModTime().Equal(entry.ModTime) {
+			continue
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			internal.LogDebug("Manifest: failed to hash %s: %v", rel, err)
+			if ok {
+				replaced = append(replaced, entry.SHA256)
+			}
+			delete(m.files, key)
+			continue
+		}
+		if read != nil && read[rel] != sum {
+			internal.LogInfo("%s changed during analysis, it will be analysed again", rel)
+			continue
+		}
+		if ok {
+			replaced = append(replaced, entry.SHA256)
+		}
+		m.files[key] = ManifestEntry{SHA256: sum, Size: info.Size(), ModTime: info.ModTime()}
+		saveObject(m.objectsDir, sum, path, info.Size())
+	}
+	m.dropObjects(replaced)
+}
+
+// HashFiles returns the SHA-256 of files (relative to workDir) as they are
+// now, "" for missing ones. Taken when a batch is built, it is the version
+// the agent reads.
+func HashFiles(workDir string, relFiles []string) map[string]string {
+	sums := make(map[string]string, len(relFiles))
+	for _, rel := range relFiles {
+		sums[rel], _ = hashFile(filepath.Join(workDir, rel))
+	}
+	return sums
+}
+
+// Forget removes files (relative to the work directory) from the manifest,
+// so they are analysed again on the next scan
+func (m *Manifest) Forget(relFiles []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var forgotten []string
+	for _, rel := range relFiles {
+		if entry, ok := m.files[filepath.ToSlash(rel)]; ok {
+			forgotten = append(forgotten, entry.SHA256)
+			delete(m.files, filepath.ToSlash(rel))
+		}
+	}
+	m.dropObjects(forgotten)
+}
+
+// Save writes the manifest to disk
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.files, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(m.path, data, 0644)
+}
+
+// key converts an absolute path into a manifest key
+func (m *Manifest) key(path string) string {
+	rel, err := filepath.Rel(m.workDir, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,155 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// IndexVersion is the index format written by this version of memo
+const IndexVersion = 1
+
+// indexMetaName is the file in .memo/index recording the index format version
+const indexMetaName = "meta.json"
+
+// IndexMeta is the content of .memo/index/meta.json
+type IndexMeta struct {
+	Version int `json:"version"`
+}
+
+// Migration upgrades the index files (name -> content) from version To-1 to
+// To; index is the set of files the index should consist of
+type Migration struct {
+	To          int
+	Description string
+	Apply       func(files map[string]string, index *IndexFiles) error
+}
+
+// migrations upgrade older indexes, in version order. An index without
+// meta.json is version 0.
+var migrations = []Migration{
+	{To: 1, Description: "add missing files and top-level fields", Apply: addMissingFields},
+}
+
+// MigrationResult describes the migrations applied to an index
+type MigrationResult struct {
+	From    int
+	To      int
+	Applied []Migration
+	Diff    string // unified diff of the index files
+}
+
+// ReadIndexVersion returns the format version of an index; 0 if it has no meta.json
+func ReadIndexVersion(indexDir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(indexDir, indexMetaName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var meta IndexMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", indexMetaName, err)
+	}
+	return meta.Version, nil
+}
+
+// MigrateIndex upgrades an index to IndexVersion. With dryRun the index is
+// left unchanged and the result shows what would change. An index newer than
+// IndexVersion is an error: it was written by a newer memo. index is the set
+// of files the upgraded index consists of.
+func MigrateIndex(indexDir string, index *IndexFiles, dryRun bool) (*MigrationResult, error) {
+	from, err := ReadIndexVersion(indexDir)
+	if err != nil {
+		return nil, err
+	}
+	if from > IndexVersion {
+		return nil, fmt.Errorf("index version %d is newer than this memo supports (%d), please upgrade memo", from, IndexVersion)
+	}
+	result := &MigrationResult{From: from, To: IndexVersion}
+	if from == IndexVersion {
+		return result, nil
+	}
+
+	before := readIndexFiles(indexDir)
+	files := make(map[string]string, len(before))
+	for name, content := range before {
+		files[name] = content
+	}
+	for _, m := range migrations {
+		if m.To <= from {
+			continue
+		}
+		if err := m.Apply(files, index); err != nil {
+			return nil, fmt.Errorf("migration to version %d failed: %w", m.To, err)
+		}
+		result.Applied = append(result.Applied, m)
+	}
+	files[indexMetaName] = indexMetaContent()
+	result.Diff = DiffIndexFiles(before, files)
+
+	if dryRun {
+		return result, nil
+	}
+	if err := commitFiles(files, indexDir); err != nil {
+		return nil, fmt.Errorf("failed to write migrated index: %w", err)
+	}
+	return result, nil
+}
+
+// indexMetaContent is meta.json for the current format
+func indexMetaContent() string {
+	data, _ := json.Marshal(IndexMeta{Version: IndexVersion})
+	return string(data)
+}
+
+// WriteIndexMeta marks a new index as the current format
+func WriteIndexMeta(indexDir string) error {
+	return os.WriteFile(filepath.Join(indexDir, indexMetaName), []byte(indexMetaContent()), 0644)
+}
+
+// addMissingFields creates missing index files and adds the top-level fields
+// of a new index missing from existing ones (version 0 -> 1)
+func addMissingFields(files map[string]string, index *IndexFiles) error {
+	initial := index.Initial()
+	names := make([]string, 0, len(initial))
+	for name := range initial {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content, ok := files[name]
+		if !ok {
+			files[name] = initial[name]
+			continue
+		}
+		var doc map[string]any
+		if err := json.Unmarshal([]byte(content), &doc); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		var defaults map[string]any
+		if err := json.Unmarshal([]byte(initial[name]), &defaults); err != nil {
+			return err
+		}
+		changed := false
+		for key, value := range defaults {
+			if _, ok := doc[key]; !ok {
+				doc[key] = value
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		files[name] = string(data) + "\n"
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// objectsDirName is the directory under .memo keeping the last-analysed
+// content of each file, addressed by its SHA-256
+const objectsDirName = "objects"
+
+// maxObjectSize is the largest file whose content is kept for diffs
+const maxObjectSize = 256 * 1024
+
+// DefaultMaxDiffTokens is the largest diff sent instead of the full file
+const DefaultMaxDiffTokens = 2000
+
+// objectPath returns where the content with the given hash is kept
+func objectPath(objectsDir, sum string) string {
+	return filepath.Join(objectsDir, sum[:2], sum[2:])
+}
+
+// saveObject keeps the content of a file just recorded in the manifest
+func saveObject(objectsDir, sum, path string, size int64) {
+	if size > maxObjectSize {
+		return
+	}
+	dst := objectPath(objectsDir, sum)
+	if _, err := os.Stat(dst); err == nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		internal.LogDebug("Failed to save object: %v", err)
+		return
+	}
+	if err := internal.WriteFileAtomic(dst, data, 0644); err != nil {
+		internal.LogDebug("Failed to save object: %v", err)
+	}
+}
+
+// Previous returns the content of a file (relative to the work directory)
+// when it was last analysed, if it was kept
+func (m *Manifest) Previous(relFile string) (string, bool) {
+	sum, ok := m.Hash(relFile)
+	if !ok || m.objectsDir == "" {
+		return "", false
+	}
+	data, err := os.ReadFile(objectPath(m.objectsDir, sum))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// dropObjects removes the objects of replaced hashes that no entry refers to
+// any more, in this manifest or in those parked with other branches' indexes.
+// Called with m.mu held.
+func (m *Manifest) dropObjects(sums []string) {
+	if m.objectsDir == "" || len(sums) == 0 {
+		return
+	}
+	used := make(map[string]bool, len(m.files))
+	for _, entry := range m.files {
+		used[entry.SHA256] = true
+	}
+	memoDir := filepath.Dir(m.objectsDir)
+	for _, branch := range internal.ParkedBranches(memoDir) {
+		data, err := os.ReadFile(filepath.Join(internal.BranchDir(memoDir, branch), manifestFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		var files map[string]ManifestEntry
+		if err == nil {
+			err = json.Unmarshal(data, &files)
+		}
+		if err != nil {
+			internal.LogDebug("Keeping objects, cannot read manifest of branch %s: %v", branch, err)
+			return
+		}
+		for _, entry := range files {
+			used[entry.SHA256] = true
+		}
+	}
+	for _, sum := range sums {
+		if !used[sum] {
+			_ = os.Remove(objectPath(m.objectsDir, sum))
+		}
+	}
+}
+
+// diffsInfo shows the diffs of modified and renamed files since their last
+// analysis, so the agent need not reread them. Files without a kept version,
+// or whose diff exceeds the limit or the file itself, are left to be read.
+func (a *Analyser) diffsInfo(changes []Change) string {
+	if a.manifest == nil || a.maxDiffTokens < 0 {
+		return ""
+	}
+	limit := a.maxDiffTokens
+	if limit == 0 {
+		limit = DefaultMaxDiffTokens
+	}
+
+	var diffs []string
+	for _, c := range changes {
+		from := c.Path
+		switch c.Kind {
+		case ChangeModified:
+		case ChangeRenamed:
+			from = c.From
+		default:
+			continue
+		}
+		before, ok := a.manifest.Previous(from)
+		if !ok {
+			continue
+		}
+		after, err := os.ReadFile(filepath.Join(a.workDir, c.Path))
+		if err != nil || len(after) > maxObjectSize {
+			continue
+		}
+		diff := internal.UnifiedDiff("a/"+filepath.ToSlash(from), "b/"+filepath.ToSlash(c.Path), before, string(after))
+		if diff == "" || len(diff)/bytesPerToken > limit || len(diff) >= len(after) {
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("```diff\n%s```", diff))
+	}
+	if len(diffs) == 0 {
+		return ""
+	}
+	return "\n\n## Diffs Since Last Analysis\n\nThe index already reflects the previous version of these files. Update only the entries the diff affects and leave the rest as it is; read the files only for context the diff does not show.\n\n" + strings.Join(diffs, "\n\n")
+}
@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// PromptNames lists the prompts that can be overridden, in the order they are sent
+var PromptNames = []string{"context", "analyse", "feedback"}
+
+// PromptData is available to prompt templates
+type PromptData struct {
+	Files        []string // files of the batch to read (not deleted), relative to the work directory
+	Changes      []Change // every change of the batch, including deletions and renames
+	Batch        int      // 1-based batch number
+	TotalBatches int
+	Language     string // language of the index content; "" if not configured
+	IndexDir     string // index directory the agent edits, relative to the work directory
+	Errors       string // validation errors (feedback prompt only)
+}
+
+// Prompt is an effective prompt template
+type Prompt struct {
+	Name   string
+	Source string // override file, or "" for the built-in template
+	Text   string
+
+	tmpl *template.Template
+}
+
+// LoadPrompts returns the effective prompt templates: <dir>/<name>.md
+// overrides the built-in template of the same name
+func LoadPrompts(dir string) ([]*Prompt, error) {
+	prompts := make([]*Prompt, 0, len(PromptNames))
+	for _, name := range PromptNames {
+		p, err := loadPromptTemplate(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts, nil
+}
+
+// loadPromptTemplate parses <dir>/<name>.md, or the built-in template if
+// dir is "" or has no such file
+func loadPromptTemplate(dir, name string) (*Prompt, error) {
+	p := &Prompt{Name: name}
+	if dir != "" {
+		path := filepath.Join(dir, name+".md")
+		data, err := os.ReadFile(path)
+		if err == nil {
+			p.Source, p.Text = path, string(data)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if p.Source == "" {
+		p.Text = loadPrompt(name)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(p.Text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", name, err)
+	}
+	p.tmpl = tmpl
+	return p, nil
+}
+
+// Render executes the template with data
+func (p *Prompt) Render(data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// prompt renders a prompt with the analyser's templates (the built-in ones if
+// none are set), falling back to the unrendered text if the template fails
+func (a *Analyser) prompt(name string, data PromptData) string {
+	p, ok := a.prompts[name]
+	if !ok {
+		var err error
+		if p, err = loadPromptTemplate("", name); err != nil {
+			internal.LogError("Failed to load prompt: %v", err)
+			return loadPrompt(name)
+		}
+	}
+	data.Language = a.language
+	text, err := p.Render(data)
+	if err != nil {
+		internal.LogError("Failed to render prompt: %v", err)
+		return p.Text
+	}
+	return text
+}
+
+// SetPrompts loads the prompt templates, overriding the built-in ones with
+// the <name>.md files found in dir
+func (a *Analyser) SetPrompts(dir string) error {
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		return err
+	}
+	a.prompts = make(map[string]*Prompt, len(prompts))
+	for _, p := range prompts {
+		if p.Source != "" {
+			internal.LogInfo("Using prompt %s from %s", p.Name, p.Source)
+		}
+		a.prompts[p.Name] = p
+	}
+	return nil
+}
+
+// SetLanguage sets the language the index content is written in
+func (a *Analyser) SetLanguage(language string) {
+	a.language = language
+}
@@ -0,0 +1,283 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// provenanceFileName is the sidecar of .memo/index recording where each
+// entry came from
+const provenanceFileName = "provenance.json"
+
+// ProvenanceSource is a file an index entry was derived from, as analysed
+type ProvenanceSource struct {
+	Path   string `json:"path"`             // relative to the work directory, slash-separated
+	SHA256 string `json:"sha256,omitempty"` // empty if the file was deleted
+}
+
+// ProvenanceEntry records the batch that last wrote an index entry: the
+// files of it the entry was derived from and when. Entries are identified
+// like in fragment merges (see mergeKeys): by file, top-level array and key
+// fields.
+type ProvenanceEntry struct {
+	File     string             `json:"file"`  // index file, e.g. arch.json
+	Field    string             `json:"field"` // top-level array, e.g. modules
+	Key      map[string]string  `json:"key"`   // key fields of the entry, e.g. {"name": "analyzer"}
+	Sources  []ProvenanceSource `json:"sources"`
+	Analysed time.Time          `json:"analysed"`
+}
+
+// Provenance is the content of the provenance sidecar
+type Provenance struct {
+	Entries []ProvenanceEntry `json:"entries"`
+}
+
+// provenanceUpdate is what one successful batch changed in the index
+type provenanceUpdate struct {
+	files         []string          // batch files, relative to the work directory
+	read          map[string]string // hashes of the files when the batch was built
+	before, after map[string]string // index files (name -> content)
+}
+
+// LoadProvenance reads a provenance sidecar; a missing file yields no entries
+func LoadProvenance(path string) (*Provenance, error) {
+	p := &Provenance{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return &Provenance{}, err
+	}
+	return p, nil
+}
+
+// provenancePath returns the sidecar of the analyser's index:
+// .memo/provenance.json for .memo/index
+func (a *Analyser) provenancePath() string {
+	return a.scratchDir("provenance") + ".json"
+}
+
+// recordProvenance attributes the entries each update added or modified to
+// the files of its batch, in order, and drops the entries no longer in the
+// index. Entries a batch left as they were still had their sources among its
+// files checked, so those sources are brought up to date.
+func (a *Analyser) recordProvenance(updates ...provenanceUpdate) {
+	path := a.provenancePath()
+	p, err := LoadProvenance(path)
+	if err != nil {
+		internal.LogError("Failed to read provenance, starting over: %v", err)
+	}
+
+	byID := make(map[string]ProvenanceEntry, len(p.Entries))
+	for _, e := range p.Entries {
+		byID[provenanceID(e.File, e.Field, e.Key)] = e
+	}
+	now := time.Now()
+	for _, u := range updates {
+		sources := provenanceSources(u.files, u.read)
+		before := indexEntries(u.before, a.indexFiles.mergeKeys)
+		written := make(map[string]bool)
+		for id, e := range indexEntries(u.after, a.indexFiles.mergeKeys) {
+			if old, ok := before[id]; ok && jsonEqual(old.raw, e.raw) {
+				continue
+			}
+			byID[id] = ProvenanceEntry{File: e.file, Field: e.field, Key: e.key, Sources: a.entrySources(e, sources), Analysed: now}
+			written[id] = true
+		}
+
+		read := make(map[string]string, len(sources))
+		for _, src := range sources {
+			read[src.Path] = src.SHA256
+		}
+		for id := range before {
+			if e, ok := byID[id]; ok && !written[id] {
+				byID[id] = refreshSources(e, read, now)
+			}
+		}
+	}
+
+	current := indexEntries(readIndexFiles(a.indexDir), a.indexFiles.mergeKeys)
+	p.Entries = p.Entries[:0]
+	for id, e := range byID {
+		if _, ok := current[id]; ok {
+			p.Entries = append(p.Entries, e)
+		}
+	}
+	sort.Slice(p.Entries, func(i, j int) bool {
+		return provenanceID(p.Entries[i].File, p.Entries[i].Field, p.Entries[i].Key) < provenanceID(p.Entries[j].File, p.Entries[j].Field, p.Entries[j].Key)
+	})
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err == nil {
+		err = internal.WriteFileAtomic(path, data, 0644)
+	}
+	if err != nil {
+		internal.LogError("Failed to save provenance: %v", err)
+	}
+}
+
+// provenanceSources lists the files of a batch with their hashes from read
+func provenanceSources(files []string, read map[string]string) []ProvenanceSource {
+	sources := make([]ProvenanceSource, 0, len(files))
+	for _, rel := range files {
+		sources = append(sources, ProvenanceSource{Path: filepath.ToSlash(rel), SHA256: read[rel]})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Path < sources[j].Path })
+	return sources
+}
+
+// entrySources returns the sources of an entry a batch wrote: the files of
+// the batch it mentions, matched like in coverage (issue locations, modules
+// named after a file or directory, interface names in a file's content), or
+// all of them if it mentions none
+func (a *Analyser) entrySources(e keyedEntry, sources []ProvenanceSource) []ProvenanceSource {
+	mentions := a.entryMentions(e)
+	if mentions == nil {
+		return sources
+	}
+	var mentioned []ProvenanceSource
+	for _, src := range sources {
+		if mentions(src.Path) {
+			mentioned = append(mentioned, src)
+		}
+	}
+	if len(mentioned) == 0 {
+		return sources
+	}
+	return mentioned
+}
+
+// entryMentions returns whether an entry mentions a file (relative,
+// slash-separated), or nil for entries that name no files
+func (a *Analyser) entryMentions(e keyedEntry) func(rel string) bool {
+	switch {
+	case e.file == "arch.json" && e.field == "modules":
+		var m struct {
+			Name     string `json:"name"`
+			Internal *struct {
+				Submodules []struct {
+					Name string `json:"name"`
+				} `json:"submodules"`
+			} `json:"internal"`
+		}
+		if err := json.Unmarshal(e.raw, &m); err != nil {
+			return nil
+		}
+		names := []string{coveragePath(m.Name)}
+		if m.Internal != nil {
+			for _, s := range m.Internal.Submodules {
+				names = append(names, coveragePath(s.Name))
+			}
+		}
+		return func(rel string) bool {
+			return slices.ContainsFunc(names, func(name string) bool { return moduleCovers(rel, name) })
+		}
+	case e.file == "interface.json":
+		words := nameWords(e.key["name"])
+		return func(rel string) bool {
+			content := readText(filepath.Join(a.workDir, rel))
+			return content != nil && slices.ContainsFunc(words, func(re *regexp.Regexp) bool { return re.Match(content) })
+		}
+	case e.file == "issues.json" && e.field == "issues":
+		var issue struct {
+			Locations []struct {
+				File string `json:"file"`
+			} `json:"locations"`
+		}
+		if err := json.Unmarshal(e.raw, &issue); err != nil {
+			return nil
+		}
+		files := make(map[string]bool)
+		for _, loc := range issue.Locations {
+			files[locationPath(a.workDir, loc.File)] = true
+		}
+		return func(rel string) bool { return files[strings.ToLower(rel)] }
+	}
+	return nil
+}
+
+// refreshSources updates the sources of an entry that a batch analysed again
+// (path -> hash) and left unchanged
+func refreshSources(e ProvenanceEntry, read map[string]string, now time.Time) ProvenanceEntry {
+	sources := make([]ProvenanceSource, len(e.Sources))
+	refreshed := false
+	for i, src := range e.Sources {
+		if sum, ok := read[src.Path]; ok {
+			src.SHA256 = sum
+			refreshed = true
+		}
+		sources[i] = src
+	}
+	if refreshed {
+		e.Sources = sources
+		e.Analysed = now
+	}
+	return e
+}
+
+// keyedEntry is an index entry with an identity
+type keyedEntry struct {
+	file, field string
+	key         map[string]string
+	raw         json.RawMessage
+}
+
+// indexEntries returns the entries of the index files that have all their
+// key fields (see mergeKeys), by provenance ID
+func indexEntries(files map[string]string, keys map[string]map[string][]string) map[string]keyedEntry {
+	entries := make(map[string]keyedEntry)
+	for file, fields := range keys {
+		var obj map[string]json.RawMessage
+		if err := decodeObject(files[file], &obj); err != nil {
+			continue
+		}
+		for field, keys := range fields {
+			var list []json.RawMessage
+			if err := json.Unmarshal(obj[field], &list); err != nil {
+				continue
+			}
+			for _, raw := range list {
+				var values map[string]any
+				if err := json.Unmarshal(raw, &values); err != nil {
+					continue
+				}
+				key := make(map[string]string, len(keys))
+				for _, k := range keys {
+					if s, ok := values[k].(string); ok && s != "" {
+						key[k] = s
+					}
+				}
+				if len(key) == len(keys) {
+					entries[provenanceID(file, field, key)] = keyedEntry{file: file, field: field, key: key, raw: raw}
+				}
+			}
+		}
+	}
+	return entries
+}
+
+// provenanceID identifies an entry across versions of the index
+func provenanceID(file, field string, key map[string]string) string {
+	parts := []string{file, field}
+	names := make([]string, 0, len(key))
+	for k := range key {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		parts = append(parts, k, key[k])
+	}
+	return strings.Join(parts, "\x00")
+}
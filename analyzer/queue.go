@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+const queueFileName = "queue.json"
+
+// QueueBatch is one planned batch and whether it has been analysed
+type QueueBatch struct {
+	Files []string `json:"files"` // relative to the work directory
+	Done  bool     `json:"done"`
+}
+
+// Queue persists the batch plan of the analysis in progress (.memo/queue.json),
+// so a run that is killed or fails can resume from its first incomplete batch.
+// The file is removed once every batch is done.
+type Queue struct {
+	path string
+
+	mu        sync.Mutex
+	CreatedAt time.Time    `json:"created_at"`
+	Batches   []QueueBatch `json:"batches"`
+}
+
+// LoadQueue reads .memo/queue.json; a missing file yields an empty queue
+func LoadQueue(workDir string) (*Queue, error) {
+	q := &Queue{path: filepath.Join(workDir, ".memo", queueFileName)}
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(data, q); err != nil {
+		q.Batches = nil
+		return q, err
+	}
+	return q, nil
+}
+
+// Reload rereads .memo/queue.json, after it was replaced on disk
+func (q *Queue) Reload() error {
+	fresh, err := LoadQueue(filepath.Dir(filepath.Dir(q.path)))
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.CreatedAt, q.Batches = fresh.CreatedAt, fresh.Batches
+	return err
+}
+
+// Len returns the number of planned batches
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.Batches)
+}
+
+// Pending returns the batches not yet done, in plan order
+func (q *Queue) Pending() [][]string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var pending [][]string
+	for _, b := range q.Batches {
+		if !b.Done {
+			pending = append(pending, b.Files)
+		}
+	}
+	return pending
+}
+
+// Plan replaces the queue with a new batch plan
+func (q *Queue) Plan(batches [][]string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.CreatedAt = time.Now()
+	q.Batches = make([]QueueBatch, len(batches))
+	for i, b := range batches {
+		q.Batches[i] = QueueBatch{Files: b}
+	}
+	return q.save()
+}
+
+// Complete marks the batch with exactly these files as done
+func (q *Queue) Complete(files []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.Batches {
+		if !q.Batches[i].Done && slices.Equal(q.Batches[i].Files, files) {
+			q.Batches[i].Done = true
+			return q.save()
+		}
+	}
+	return nil
+}
+
+// Discard drops the plan and removes the queue file
+func (q *Queue) Discard() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Batches = nil
+	return q.save()
+}
+
+// save writes the queue, or removes the file when nothing is pending
+func (q *Queue) save() error {
+	pending := false
+	for _, b := range q.Batches {
+		if !b.Done {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(q.path, data, 0644)
+}
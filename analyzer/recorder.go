@@ -0,0 +1,296 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+
+	"github.com/MoonshotAI/kimi-agent-sdk/go/wire"
+)
+
+// recordingsDirName is the directory under .memo holding session recordings
+const recordingsDirName = "recordings"
+
+// RecordingMeta describes a recorded analysis run (meta.json)
+type RecordingMeta struct {
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Backend    string      `json:"backend"`
+	Model      string      `json:"model,omitempty"`
+	SessionID  string      `json:"session_id"`
+	WorkDir    string      `json:"work_dir,omitempty"`    // project the run analysed
+	IndexFiles []IndexFile `json:"index_files,omitempty"` // project-defined index files of the run
+	Batches    [][]string  `json:"batches"`
+	Workers    int         `json:"workers,omitempty"`
+	Turns      int         `json:"turns"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// RecordedMessage is a wire message as stored in a recording
+type RecordedMessage struct {
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Response string          `json:"response,omitempty"` // answer given to an approval request
+}
+
+// recorder writes a recording of one analysis run to .memo/recordings/<timestamp>/:
+//
+//	meta.json      run metadata and batch plan
+//	index-before/  index at the start of the run
+//	turns/NNN.json one file per prompt (a scripted-backend turn, so it can be replayed)
+//	index-after/   index at the end of the run
+//	diff.txt       unified diff of index-before → index-after
+type recorder struct {
+	dir      string
+	indexDir string
+
+	mu   sync.Mutex
+	meta RecordingMeta
+}
+
+// newRecorder creates the recording directory and captures the starting index
+func newRecorder(memoDir, indexDir, workDir string, agentCfg AgentConfig, sessionID string, batches [][]string, workers int, indexFiles []IndexFile) (*recorder, error) {
+	now := time.Now()
+	dir := filepath.Join(memoDir, recordingsDirName, now.Format("20060102-150405.000"))
+	if err := os.MkdirAll(filepath.Join(dir, "turns"), 0755); err != nil {
+		return nil, err
+	}
+	if err := internal.CopyDir(indexDir, filepath.Join(dir, "index-before")); err != nil {
+		return nil, err
+	}
+
+	backend := agentCfg.Backend
+	if backend == "" {
+		backend = BackendKimi
+	}
+	r := &recorder{
+		dir:      dir,
+		indexDir: indexDir,
+		meta: RecordingMeta{
+			StartedAt:  now,
+			Backend:    backend,
+			Model:      agentCfg.Model,
+			SessionID:  sessionID,
+			WorkDir:    workDir,
+			IndexFiles: indexFiles,
+			Batches:    batches,
+			Workers:    workers,
+		},
+	}
+	if err := r.writeMeta(); err != nil {
+		return nil, err
+	}
+	internal.LogInfo("Recording session to %s", dir)
+	return r, nil
+}
+
+func (r *recorder) writeMeta() error {
+	data, err := json.MarshalIndent(r.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, "meta.json"), data, 0644)
+}
+
+// recordedTurn accumulates one prompt and its streamed response
+type recordedTurn struct {
+	rec      *recorder
+	indexDir string
+	before   map[string]string
+
+	Batch    int               `json:"batch"`
+	Kind     string            `json:"kind"` // "initial" or "feedback"
+	Prompt   string            `json:"prompt"`
+	Messages []RecordedMessage `json:"messages"`
+	Files    map[string]any    `json:"files,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// beginTurn starts recording a prompt sent for the given batch; nil-safe
+func (r *recorder) beginTurn(batchNum int, kind, prompt, indexDir string) *recordedTurn {
+	if r == nil {
+		return nil
+	}
+	return &recordedTurn{
+		rec:      r,
+		indexDir: indexDir,
+		before:   readIndexFiles(indexDir),
+		Batch:    batchNum,
+		Kind:     kind,
+		Prompt:   prompt,
+	}
+}
+
+// record stores a streamed message; response is the answer to an approval request
+func (t *recordedTurn) record(msg wire.Message, response string) {
+	if t == nil {
+		return
+	}
+	rm, err := encodeMessage(msg)
+	if err != nil {
+		internal.LogDebug("Recorder: skipping message %T: %v", msg, err)
+		return
+	}
+	rm.Response = response
+	t.Messages = append(t.Messages, rm)
+}
+
+// end captures the index files changed by the turn and writes turns/NNN.json
+func (t *recordedTurn) end(turnErr error) {
+	if t == nil {
+		return
+	}
+	if turnErr != nil {
+		t.Error = turnErr.Error()
+	}
+
+	after := readIndexFiles(t.indexDir)
+	t.Files = make(map[string]any)
+	for name, content := range after {
+		if old, ok := t.before[name]; !ok || old != content {
+			t.Files[name] = content // stored verbatim as a string
+		}
+	}
+	for name := range t.before {
+		if _, ok := after[name]; !ok {
+			t.Files[name] = nil
+		}
+	}
+
+	r := t.rec
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.meta.Turns++
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.dir, "turns", fmt.Sprintf("%03d.json", r.meta.Turns)), data, 0644)
+	}
+	if err != nil {
+		internal.LogError("Recorder: failed to write turn: %v", err)
+	}
+}
+
+// finish captures the final index, the run diff and the outcome
+func (r *recorder) finish(runErr error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.meta.FinishedAt = &now
+	if runErr != nil {
+		r.meta.Error = runErr.Error()
+	}
+	if err := r.writeMeta(); err != nil {
+		internal.LogError("Recorder: failed to write meta: %v", err)
+	}
+
+	afterDir := filepath.Join(r.dir, "index-after")
+	if err := internal.CopyDir(r.indexDir, afterDir); err != nil {
+		internal.LogError("Recorder: failed to copy index: %v", err)
+		return
+	}
+	diff := diffIndexDirs(filepath.Join(r.dir, "index-before"), afterDir)
+	if err := os.WriteFile(filepath.Join(r.dir, "diff.txt"), []byte(diff), 0644); err != nil {
+		internal.LogError("Recorder: failed to write diff: %v", err)
+	}
+	internal.LogInfo("Recording saved: %s", r.dir)
+}
+
+// readIndexFiles reads all JSON files in an index directory
+func readIndexFiles(dir string) map[string]string {
+	files := make(map[string]string)
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, path := range matches {
+		if data, err := os.ReadFile(path); err == nil {
+			files[filepath.Base(path)] = string(data)
+		}
+	}
+	return files
+}
+
+// diffIndexDirs returns a unified diff of the JSON files in two index directories
+func diffIndexDirs(fromDir, toDir string) string {
+	return DiffIndexFiles(readIndexFiles(fromDir), readIndexFiles(toDir))
+}
+
+// DiffIndexFiles returns a unified diff between two sets of index files (name -> content)
+func DiffIndexFiles(from, to map[string]string) string {
+	names := make(map[string]struct{})
+	for name := range from {
+		names[name] = struct{}{}
+	}
+	for name := range to {
+		names[name] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var sb strings.Builder
+	for _, name := range sorted {
+		sb.WriteString(internal.UnifiedDiff("a/"+name, "b/"+name, from[name], to[name]))
+	}
+	return sb.String()
+}
+
+// ============== Message Encoding ==============
+
+// encodeMessage converts a wire message into its recorded form
+func encodeMessage(msg wire.Message) (RecordedMessage, error) {
+	var typ string
+	switch m := msg.(type) {
+	case wire.Event:
+		typ = string(m.EventType())
+	case wire.Request:
+		typ = string(m.RequestType())
+	default:
+		return RecordedMessage{}, fmt.Errorf("unsupported message type")
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return RecordedMessage{}, err
+	}
+	return RecordedMessage{Type: typ, Payload: payload}, nil
+}
+
+// decodeMessage converts a recorded message back into a wire message
+func decodeMessage(rm RecordedMessage) (wire.Message, error) {
+	switch rm.Type {
+	case string(wire.EventTypeStepBegin):
+		return decodeAs[wire.StepBegin](rm.Payload)
+	case string(wire.EventTypeContentPart):
+		return decodeAs[wire.ContentPart](rm.Payload)
+	case string(wire.EventTypeToolCall):
+		return decodeAs[wire.ToolCall](rm.Payload)
+	case string(wire.EventTypeToolCallPart):
+		return decodeAs[wire.ToolCallPart](rm.Payload)
+	case string(wire.EventTypeToolResult):
+		return decodeAs[wire.ToolResult](rm.Payload)
+	case string(wire.EventTypeStatusUpdate):
+		return decodeAs[wire.StatusUpdate](rm.Payload)
+	case string(wire.RequestTypeApprovalRequest):
+		return decodeAs[wire.ApprovalRequest](rm.Payload)
+	default:
+		return nil, fmt.Errorf("unsupported recorded message type: %s", rm.Type)
+	}
+}
+
+func decodeAs[T wire.Message](payload json.RawMessage) (wire.Message, error) {
+	var msg T
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
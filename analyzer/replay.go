@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// ReplayResult is the outcome of replaying a recording
+type ReplayResult struct {
+	Meta     RecordingMeta
+	IndexDir string // index produced by the replay
+	Err      error  // analysis error from the replayed run, if any
+	Diff     string // replayed index vs. the recorded index-after ("" if identical)
+}
+
+// Replay re-drives the validation loop of a recorded run without calling the model.
+// The recorded turns are fed through the scripted backend into a scratch copy of
+// the starting index (<recording>/replay/), so the live index is never touched.
+// Issue locations are checked against the recorded project as it is now.
+func Replay(ctx context.Context, recordingDir string) (*ReplayResult, error) {
+	data, err := os.ReadFile(filepath.Join(recordingDir, "meta.json"))
+	if err != nil {
+		return nil, fmt.Errorf("not a recording: %w", err)
+	}
+	var meta RecordingMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid meta.json: %w", err)
+	}
+
+	backend, err := newScriptedBackend(AgentConfig{ScriptDir: filepath.Join(recordingDir, "turns")})
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := NewIndexFiles(meta.IndexFiles)
+	if err != nil {
+		return nil, fmt.Errorf("recorded index files: %w", err)
+	}
+	sourceDir := meta.WorkDir
+	if sourceDir == "" {
+		// Recorded before the project was: recordings are kept in
+		// <project>/.memo/recordings/
+		abs, err := filepath.Abs(recordingDir)
+		if err != nil {
+			return nil, err
+		}
+		sourceDir = filepath.Dir(filepath.Dir(filepath.Dir(abs)))
+	}
+
+	// Seed a scratch work directory with the recorded starting index
+	workDir := filepath.Join(recordingDir, "replay")
+	if err := os.RemoveAll(workDir); err != nil {
+		return nil, err
+	}
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	if err := internal.CopyDir(filepath.Join(recordingDir, "index-before"), indexDir); err != nil {
+		return nil, err
+	}
+
+	a := &Analyser{
+		agentCfg:   AgentConfig{Backend: BackendScripted},
+		backend:    backend,
+		indexDir:   indexDir,
+		workDir:    workDir,
+		sourceDir:  sourceDir,
+		sessionID:  meta.SessionID,
+		workers:    meta.Workers,
+		indexFiles: files,
+	}
+	internal.LogInfo("Replaying %d turn(s) in %d batch(es) from %s", meta.Turns, len(meta.Batches), recordingDir)
+
+	result := &ReplayResult{Meta: meta, IndexDir: indexDir}
+	result.Err = a.runBatches(ctx, meta.Batches)
+	result.Diff = diffIndexDirs(filepath.Join(recordingDir, "index-after"), indexDir)
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+const revisionFileName = "revision.json"
+
+// Revision is the last git commit whose files were fully analysed
+// (.memo/revision.json), the starting point of `memo scan --incremental`
+type Revision struct {
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+}
+
+// LoadRevision reads .memo/revision.json; nil if no commit was recorded
+func LoadRevision(workDir string) (*Revision, error) {
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", revisionFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// SaveRevision records commit as analysed
+func SaveRevision(workDir, commit string) error {
+	data, err := json.MarshalIndent(Revision{Commit: commit, Time: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(filepath.Join(workDir, ".memo", revisionFileName), data, 0644)
+}
@@ -0,0 +1,284 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// rebuildDirName is the directory under .memo where the next index is rebuilt
+const rebuildDirName = "index-rebuild"
+
+// Rotator periodically rebuilds the index from scratch in a fresh agent
+// session, blue/green style. The rebuild analyser writes to .memo/index-rebuild
+// while the active one keeps updating .memo/index, and changes arriving in the
+// meantime are sent to both. Once the rebuild has caught up and stayed healthy
+// for the stabilize period, its files are committed over the index and it
+// becomes the active analyser. See spec/feature-future-belongs-to-future.md.
+type Rotator struct {
+	newAnalyser func() (*Analyser, error) // creates the analyser of each rebuild
+	listFiles   func() []string           // all files to analyse in a rebuild
+	interval    time.Duration
+	stabilize   time.Duration
+
+	runMu sync.Mutex // held while analysing changes or swapping the index
+
+	mu       sync.Mutex
+	active   *Analyser
+	rebuild  *Analyser // nil when no rebuild is in progress
+	ready    bool      // the rebuild has caught up: changes go to it directly
+	deferred []string  // changes received while the rebuild's full scan runs
+	failed   bool      // a change failed on the rebuild, or it was abandoned
+}
+
+// NewRotator creates a rotator around the active analyser. A leftover
+// .memo/index-rebuild from an interrupted run is removed.
+func NewRotator(active *Analyser, newAnalyser func() (*Analyser, error), listFiles func() []string, interval, stabilize time.Duration) *Rotator {
+	if err := os.RemoveAll(filepath.Join(filepath.Dir(active.indexDir), rebuildDirName)); err != nil {
+		internal.LogError("Failed to remove stale rebuild: %v", err)
+	}
+	return &Rotator{
+		newAnalyser: newAnalyser,
+		listFiles:   listFiles,
+		interval:    interval,
+		stabilize:   stabilize,
+		active:      active,
+	}
+}
+
+// Active returns the analyser currently updating .memo/index
+func (r *Rotator) Active() *Analyser {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// Run starts a rebuild every interval until ctx is cancelled. A failed
+// rebuild leaves the index as it is and is retried at the next interval.
+func (r *Rotator) Run(ctx context.Context) {
+	timer := time.NewTimer(r.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if err := r.Rebuild(ctx); err != nil {
+			internal.LogError("Rebuild failed, keeping the current index: %v", err)
+		}
+		timer.Reset(r.interval)
+	}
+}
+
+// Analyse analyses changed files with the active analyser and mirrors them to
+// the rebuild in progress, if any. It returns the active analyser's error.
+func (r *Rotator) Analyse(ctx context.Context, files []string) error {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	r.mu.Lock()
+	active, rebuild := r.active, r.rebuild
+	mirror := rebuild != nil && r.ready && !r.failed
+	if rebuild != nil && !r.ready {
+		// Picked up by the rebuild once its full scan is done
+		r.deferred = appendMissing(r.deferred, files)
+	}
+	r.mu.Unlock()
+
+	if !mirror {
+		return active.Analyse(ctx, files)
+	}
+
+	var rebuildErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rebuildErr = rebuild.Analyse(ctx, files)
+	}()
+	err := active.Analyse(ctx, files)
+	wg.Wait()
+
+	if rebuildErr != nil {
+		internal.LogError("Rebuild: analysis of changed files failed, abandoning rebuild: %v", rebuildErr)
+		r.mu.Lock()
+		r.failed = true
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// NoteRename passes a rename seen by the watcher to the active analyser and
+// the rebuild in progress
+func (r *Rotator) NoteRename(oldPath, newPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active.NoteRename(oldPath, newPath)
+	if r.rebuild != nil {
+		r.rebuild.NoteRename(oldPath, newPath)
+	}
+}
+
+// Abandon marks the rebuild in progress, if any, as no longer matching
+// .memo/index (after a branch switch), so it is discarded instead of swapped in
+func (r *Rotator) Abandon() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rebuild != nil && !r.failed {
+		r.failed = true
+		internal.LogInfo("Rebuild: abandoned, the index was switched")
+	}
+}
+
+// Rebuild analyses all files into .memo/index-rebuild in a fresh session,
+// waits for the stabilize period and swaps the result in. It does nothing if
+// a rebuild is already in progress.
+func (r *Rotator) Rebuild(ctx context.Context) (err error) {
+	r.mu.Lock()
+	if r.rebuild != nil {
+		r.mu.Unlock()
+		internal.LogInfo("Rebuild already in progress, skipping")
+		return nil
+	}
+	b, err := r.startRebuild()
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.rebuild = b
+	r.mu.Unlock()
+	defer func() {
+		if err != nil {
+			r.discard(b)
+		}
+	}()
+
+	files := r.listFiles()
+	internal.LogInfo("Rebuild: analysing %d files into %s (session %s)", len(files), rebuildDirName, b.sessionID)
+	if err := b.Analyse(ctx, files); err != nil {
+		return err
+	}
+
+	// Catch up with the changes made during the full scan
+	for {
+		r.mu.Lock()
+		pending := r.deferred
+		r.deferred = nil
+		if len(pending) == 0 {
+			r.ready = true
+			r.mu.Unlock()
+			break
+		}
+		r.mu.Unlock()
+		internal.LogInfo("Rebuild: catching up with %d changed files", len(pending))
+		if err := b.Analyse(ctx, pending); err != nil {
+			return err
+		}
+	}
+
+	internal.LogInfo("Rebuild complete, swapping in after %s", r.stabilize)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(r.stabilize):
+	}
+	return r.rotate(b)
+}
+
+// startRebuild creates the rebuild analyser on an empty .memo/index-rebuild
+func (r *Rotator) startRebuild() (*Analyser, error) {
+	b, err := r.newAnalyser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rebuild analyser: %w", err)
+	}
+
+	indexDir := filepath.Join(filepath.Dir(r.active.indexDir), rebuildDirName)
+	if err := os.RemoveAll(indexDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		return nil, err
+	}
+	for name, content := range b.indexFiles.Initial() {
+		if err := os.WriteFile(filepath.Join(indexDir, name), []byte(content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	if err := WriteIndexMeta(indexDir); err != nil {
+		return nil, err
+	}
+
+	// A new session every generation, so no context carries over
+	b.sessionID = generateSessionID(b.workDir) + "-" + time.Now().Format("20060102150405")
+	b.indexDir = indexDir
+	if err := os.Remove(b.provenancePath()); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	b.background = true
+	b.manifest, b.queue, b.snapshots = nil, nil, nil
+	return b, nil
+}
+
+// rotate commits the rebuilt index over .memo/index and makes the rebuild
+// analyser the active one
+func (r *Rotator) rotate(b *Analyser) error {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed {
+		return fmt.Errorf("rebuild abandoned after a failed update or branch switch")
+	}
+	if result := b.indexFiles.Validate(b.indexDir); !result.Valid {
+		return fmt.Errorf("rebuilt index failed validation: %s", FormatValidationErrors(result))
+	}
+
+	old := r.active
+	if err := commitIndex(b.indexDir, old.indexDir); err != nil {
+		return fmt.Errorf("failed to swap in rebuilt index: %w", err)
+	}
+	rebuildDir, rebuildProvenance := b.indexDir, b.provenancePath()
+	b.indexDir = old.indexDir
+	if err := os.Rename(rebuildProvenance, b.provenancePath()); err != nil && !os.IsNotExist(err) {
+		internal.LogError("Failed to swap in provenance: %v", err)
+	}
+	b.background = false
+	b.manifest, b.queue, b.snapshots, b.record = old.manifest, old.queue, old.snapshots, old.record
+
+	r.active, r.rebuild, r.ready = b, nil, false
+	if err := os.RemoveAll(rebuildDir); err != nil {
+		internal.LogError("Failed to remove %s: %v", rebuildDir, err)
+	}
+	internal.LogInfo("Rebuild: swapped in index from session %s", b.sessionID)
+
+	if b.snapshots != nil {
+		if _, err := b.snapshots.Save(b.indexDir, nil, "rebuild "+b.sessionID); err != nil {
+			internal.LogError("Failed to save snapshot: %v", err)
+		}
+	}
+	return nil
+}
+
+// discard drops a failed rebuild and its directory
+func (r *Rotator) discard(b *Analyser) {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rebuild != b {
+		return
+	}
+	r.rebuild, r.ready, r.failed, r.deferred = nil, false, false, nil
+	if err := os.RemoveAll(b.indexDir); err != nil {
+		internal.LogError("Failed to remove %s: %v", b.indexDir, err)
+	}
+	_ = os.Remove(b.provenancePath())
+}
@@ -0,0 +1,341 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// keywordWindow is how many lines around a location's line its keyword may
+// appear on, as line numbers drift when files are edited
+const keywordWindow = 5
+
+// Rule is a semantic check of index content that already passed schema
+// validation. Check returns one message per problem, prefixed with the file
+// and the path of the offending entry.
+type Rule struct {
+	Name  string
+	Check func(ix *ruleIndex) []string
+}
+
+// rules are run by ValidateSemantics in order
+var rules = []Rule{
+	{"unique-module-names", checkModuleNames},
+	{"unique-interface-names", checkInterfaceNames},
+	{"issue-locations", checkIssueLocations},
+	{"non-empty-descriptions", checkDescriptions},
+	{"story-tags", checkStoryTags},
+}
+
+// ruleInterface is an entry of interface.json
+type ruleInterface struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ruleIndex is the parsed index handed to each rule
+type ruleIndex struct {
+	workDir string
+	arch    struct {
+		Modules []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Internal    *struct {
+				Submodules []struct {
+					Name        string `json:"name"`
+					Description string `json:"description"`
+				} `json:"submodules"`
+			} `json:"internal"`
+		} `json:"modules"`
+	}
+	iface struct {
+		External []ruleInterface `json:"external"`
+		Internal []ruleInterface `json:"internal"`
+	}
+	stories struct {
+		Stories []struct {
+			Title   string   `json:"title"`
+			Tags    []string `json:"tags"`
+			Content string   `json:"content"`
+		} `json:"stories"`
+	}
+	issues struct {
+		Issues []struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Locations   []struct {
+				File    string `json:"file"`
+				Keyword string `json:"keyword"`
+				Line    int    `json:"line"`
+			} `json:"locations"`
+		} `json:"issues"`
+	}
+
+	scope *ruleScope          // nil checks the whole index
+	lines map[string][]string // source file lines by location path; nil if unreadable
+}
+
+// ruleScope limits the rules on entries to what one batch touched: the
+// entries it added or changed, and issue locations in its files. The
+// feedback loop must not hold a batch to problems elsewhere in the index;
+// memo lint checks all of it.
+type ruleScope struct {
+	files   map[string]bool // batch files, relative and slash-separated
+	entries map[string]bool // provenance IDs of the entries added or changed
+}
+
+// batchScope returns what a batch touched, given the index files before it ran
+func (a *Analyser) batchScope(files []string, before map[string]string, indexDir string) *ruleScope {
+	scope := &ruleScope{files: make(map[string]bool), entries: make(map[string]bool)}
+	for _, f := range files {
+		scope.files[filepath.ToSlash(filepath.Clean(f))] = true
+	}
+	old := indexEntries(before, a.indexFiles.mergeKeys)
+	for id, e := range indexEntries(readIndexFiles(indexDir), a.indexFiles.mergeKeys) {
+		if o, ok := old[id]; !ok || !jsonEqual(o.raw, e.raw) {
+			scope.entries[id] = true
+		}
+	}
+	return scope
+}
+
+// inScope reports whether the rules look at an entry, identified like in
+// fragment merges (see mergeKeys)
+func (ix *ruleIndex) inScope(file, field string, key map[string]string) bool {
+	return ix.scope == nil || ix.scope.entries[provenanceID(file, field, key)]
+}
+
+// fileInScope reports whether the rules look at issue locations in a file
+func (ix *ruleIndex) fileInScope(file string) bool {
+	if ix.scope == nil {
+		return true
+	}
+	if rel, err := filepath.Rel(ix.workDir, file); err == nil && filepath.IsAbs(file) {
+		file = rel
+	}
+	return ix.scope.files[filepath.ToSlash(filepath.Clean(file))]
+}
+
+// ValidateSemantics checks what JSON Schema cannot: unique names, issue
+// locations that point at real code under workDir, descriptions and story
+// tags that are not empty. The index must already be schema-valid.
+func ValidateSemantics(indexDir, workDir string) ValidationResult {
+	return validateSemantics(indexDir, workDir, nil)
+}
+
+// validateSemantics runs the rules, limited to scope if not nil
+func validateSemantics(indexDir, workDir string, scope *ruleScope) ValidationResult {
+	ix, errs := loadRuleIndex(indexDir, workDir)
+	if len(errs) > 0 {
+		return ValidationResult{Errors: errs}
+	}
+	ix.scope = scope
+
+	for _, r := range rules {
+		problems := r.Check(ix)
+		if len(problems) > 0 {
+			internal.LogDebug("Semantic rule %s: %d problem(s)", r.Name, len(problems))
+		}
+		errs = append(errs, problems...)
+	}
+	return ValidationResult{Valid: len(errs) == 0, Errors: errs}
+}
+
+// loadRuleIndex parses the index files the rules look at
+func loadRuleIndex(indexDir, workDir string) (*ruleIndex, []string) {
+	ix := &ruleIndex{workDir: workDir, lines: make(map[string][]string)}
+	var errs []string
+	for _, f := range []struct {
+		name string
+		v    any
+	}{
+		{"arch.json", &ix.arch},
+		{"interface.json", &ix.iface},
+		{"stories.json", &ix.stories},
+		{"issues.json", &ix.issues},
+	} {
+		data, err := os.ReadFile(filepath.Join(indexDir, f.name))
+		if err == nil {
+			err = json.Unmarshal(data, f.v)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.name, err))
+		}
+	}
+	return ix, errs
+}
+
+// duplicates reports names used by more than one entry of a list
+func duplicates(file, list, what string, names []string) []string {
+	var errs []string
+	first := make(map[string]int)
+	for i, name := range names {
+		key := strings.TrimSpace(name)
+		if j, ok := first[key]; ok {
+			errs = append(errs, fmt.Sprintf("%s: %s[%d]: duplicate %s %q (also %s[%d]); merge the entries or rename one", file, list, i, what, name, list, j))
+			continue
+		}
+		first[key] = i
+	}
+	return errs
+}
+
+func checkModuleNames(ix *ruleIndex) []string {
+	var names []string
+	for _, m := range ix.arch.Modules {
+		names = append(names, m.Name)
+	}
+	errs := duplicates("arch.json", "modules", "module name", names)
+	for i, m := range ix.arch.Modules {
+		if m.Internal == nil {
+			continue
+		}
+		var subs []string
+		for _, s := range m.Internal.Submodules {
+			subs = append(subs, s.Name)
+		}
+		errs = append(errs, duplicates("arch.json", fmt.Sprintf("modules[%d].internal.submodules", i), "submodule name", subs)...)
+	}
+	return errs
+}
+
+func checkInterfaceNames(ix *ruleIndex) []string {
+	var external, internal []string
+	for _, e := range ix.iface.External {
+		external = append(external, e.Name)
+	}
+	for _, e := range ix.iface.Internal {
+		internal = append(internal, e.Name)
+	}
+	return append(duplicates("interface.json", "external", "interface name", external),
+		duplicates("interface.json", "internal", "interface name", internal)...)
+}
+
+func checkIssueLocations(ix *ruleIndex) []string {
+	var errs []string
+	for i, issue := range ix.issues.Issues {
+		changed := ix.inScope("issues.json", "issues", map[string]string{"title": issue.Title})
+		for j, loc := range issue.Locations {
+			if !changed && !ix.fileInScope(loc.File) {
+				continue
+			}
+			at := fmt.Sprintf("issues.json: issues[%d].locations[%d]", i, j)
+			lines, ok := ix.sourceLines(loc.File)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: file %q does not exist; paths are relative to the working directory. Fix the path, or remove the location (or the issue) if the code is gone", at, loc.File))
+				continue
+			}
+			if loc.Line < 1 || loc.Line > len(lines) {
+				errs = append(errs, fmt.Sprintf("%s: line %d is outside %s, which has %d lines", at, loc.Line, loc.File, len(lines)))
+				continue
+			}
+			if strings.TrimSpace(loc.Keyword) == "" {
+				errs = append(errs, fmt.Sprintf("%s: empty keyword", at))
+				continue
+			}
+			if !keywordNear(lines, loc.Line, loc.Keyword) {
+				errs = append(errs, fmt.Sprintf("%s: keyword %q does not appear within %d lines of %s:%d; update the line or the keyword", at, loc.Keyword, keywordWindow, loc.File, loc.Line))
+			}
+		}
+	}
+	return errs
+}
+
+func checkDescriptions(ix *ruleIndex) []string {
+	var errs []string
+	empty := func(at, what, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Sprintf("%s: empty %s; say what it is for in a sentence or two", at, what))
+		}
+	}
+	for i, m := range ix.arch.Modules {
+		if !ix.inScope("arch.json", "modules", map[string]string{"name": m.Name}) {
+			continue
+		}
+		empty(fmt.Sprintf("arch.json: modules[%d]", i), "description", m.Description)
+		if m.Internal == nil {
+			continue
+		}
+		for j, s := range m.Internal.Submodules {
+			empty(fmt.Sprintf("arch.json: modules[%d].internal.submodules[%d]", i, j), "description", s.Description)
+		}
+	}
+	for _, list := range []struct {
+		name    string
+		entries []ruleInterface
+	}{{"external", ix.iface.External}, {"internal", ix.iface.Internal}} {
+		for i, e := range list.entries {
+			if ix.inScope("interface.json", list.name, map[string]string{"type": e.Type, "name": e.Name}) {
+				empty(fmt.Sprintf("interface.json: %s[%d]", list.name, i), "description", e.Description)
+			}
+		}
+	}
+	for i, s := range ix.stories.Stories {
+		if ix.inScope("stories.json", "stories", map[string]string{"title": s.Title}) {
+			empty(fmt.Sprintf("stories.json: stories[%d]", i), "content", s.Content)
+		}
+	}
+	for i, issue := range ix.issues.Issues {
+		if ix.inScope("issues.json", "issues", map[string]string{"title": issue.Title}) {
+			empty(fmt.Sprintf("issues.json: issues[%d]", i), "description", issue.Description)
+		}
+	}
+	return errs
+}
+
+func checkStoryTags(ix *ruleIndex) []string {
+	var errs []string
+	for i, s := range ix.stories.Stories {
+		if !ix.inScope("stories.json", "stories", map[string]string{"title": s.Title}) {
+			continue
+		}
+		tagged := false
+		for _, tag := range s.Tags {
+			if strings.TrimSpace(tag) != "" {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			errs = append(errs, fmt.Sprintf("stories.json: stories[%d]: no tags; tag the story with the modules or features it involves", i))
+		}
+	}
+	return errs
+}
+
+// sourceLines returns the lines of a file named by an issue location
+func (ix *ruleIndex) sourceLines(file string) ([]string, bool) {
+	if lines, ok := ix.lines[file]; ok {
+		return lines, lines != nil
+	}
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ix.workDir, filepath.FromSlash(file))
+	}
+	var lines []string
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		if data, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		}
+	}
+	ix.lines[file] = lines
+	return lines, lines != nil
+}
+
+// keywordNear reports whether keyword appears within keywordWindow lines of
+// line (1-based)
+func keywordNear(lines []string, line int, keyword string) bool {
+	from := max(line-1-keywordWindow, 0)
+	to := min(line-1+keywordWindow, len(lines)-1)
+	for i := from; i <= to; i++ {
+		if strings.Contains(lines[i], keyword) {
+			return true
+		}
+	}
+	return false
+}
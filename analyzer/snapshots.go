@@ -0,0 +1,300 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+const (
+	snapshotsDirName = "snapshots"
+	snapshotLogName  = "log.jsonl"
+
+	// DefaultSnapshotRetention is the number of snapshots kept when not configured
+	DefaultSnapshotRetention = 50
+)
+
+// Snapshot describes a stored version of the index (one line of log.jsonl)
+type Snapshot struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Files  []string  `json:"files,omitempty"` // files whose analysis produced this version
+	Note   string    `json:"note,omitempty"`
+	Branch string    `json:"branch,omitempty"` // branch of the index, with per-branch indexes
+}
+
+// SnapshotStore keeps compressed snapshots of the index in .memo/snapshots:
+// one <id>.tar.gz per version plus log.jsonl, oldest first. The provenance
+// sidecar of each version is kept next to it as <id>.provenance.json.
+type SnapshotStore struct {
+	dir       string
+	retention int
+}
+
+// OpenSnapshots returns the snapshot store of a work directory. retention is
+// the number of snapshots kept; <= 0 uses DefaultSnapshotRetention.
+func OpenSnapshots(workDir string, retention int) *SnapshotStore {
+	if retention <= 0 {
+		retention = DefaultSnapshotRetention
+	}
+	return &SnapshotStore{dir: filepath.Join(workDir, ".memo", snapshotsDirName), retention: retention}
+}
+
+// Save stores the current index if it differs from the latest snapshot of
+// its branch. It returns nil when nothing changed; the provenance of that
+// snapshot is brought up to date then.
+func (s *SnapshotStore) Save(indexDir string, files []string, note string) (*Snapshot, error) {
+	contents := readIndexFiles(indexDir)
+	prov, err := os.ReadFile(filepath.Join(filepath.Dir(indexDir), provenanceFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	log, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	branch := internal.ActiveBranch(filepath.Dir(s.dir))
+	for i := len(log) - 1; i >= 0; i-- {
+		if log[i].Branch != branch {
+			continue
+		}
+		if latest, err := s.Files(log[i].ID); err == nil && sameFiles(latest, contents) {
+			internal.LogDebug("Index unchanged since snapshot %s", log[i].ID)
+			return nil, s.saveProvenance(log[i].ID, prov)
+		}
+		break
+	}
+
+	now := time.Now()
+	archive, err := tarFiles(contents, now)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append(archive, []byte(now.String())...))
+	snap := Snapshot{ID: hex.EncodeToString(sum[:4]), Time: now, Files: files, Note: note, Branch: branch}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := internal.WriteFileAtomic(s.archivePath(snap.ID), archive, 0644); err != nil {
+		return nil, err
+	}
+	if err := s.saveProvenance(snap.ID, prov); err != nil {
+		return nil, err
+	}
+	if err := s.writeLog(append(log, snap)); err != nil {
+		return nil, err
+	}
+	internal.LogInfo("Saved index snapshot %s", snap.ID)
+	return &snap, nil
+}
+
+// List returns all snapshots, oldest first
+func (s *SnapshotStore) List() ([]Snapshot, error) {
+	f, err := os.Open(filepath.Join(s.dir, snapshotLogName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var log []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var snap Snapshot
+		if err := json.Unmarshal([]byte(line), &snap); err != nil {
+			return nil, fmt.Errorf("invalid snapshot log: %w", err)
+		}
+		log = append(log, snap)
+	}
+	return log, scanner.Err()
+}
+
+// Find returns the snapshot whose ID starts with prefix, and the one before it
+// on the same branch (nil for the oldest)
+func (s *SnapshotStore) Find(prefix string) (snap, prev *Snapshot, err error) {
+	log, err := s.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	match := -1
+	for i := range log {
+		if strings.HasPrefix(log[i].ID, prefix) && prefix != "" {
+			if match >= 0 {
+				return nil, nil, fmt.Errorf("ambiguous snapshot id: %s", prefix)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, nil, fmt.Errorf("snapshot not found: %s", prefix)
+	}
+	for i := match - 1; i >= 0; i-- {
+		if log[i].Branch == log[match].Branch {
+			prev = &log[i]
+			break
+		}
+	}
+	return &log[match], prev, nil
+}
+
+// Files returns the index files stored in a snapshot
+func (s *SnapshotStore) Files(id string) (map[string]string, error) {
+	f, err := os.Open(s.archivePath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(hdr.Name)] = string(data)
+	}
+	return files, nil
+}
+
+// Restore replaces the index with the files of a snapshot, and its provenance
+// sidecar with the one of the snapshot. Snapshots without one leave no
+// sidecar, so their entries are not reported stale.
+func (s *SnapshotStore) Restore(id, indexDir string) error {
+	files, err := s.Files(id)
+	if err != nil {
+		return err
+	}
+	if err := commitFiles(files, indexDir); err != nil {
+		return err
+	}
+	sidecar := filepath.Join(filepath.Dir(indexDir), provenanceFileName)
+	prov, err := os.ReadFile(s.provenancePath(id))
+	if os.IsNotExist(err) {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(sidecar, prov, 0644)
+}
+
+// saveProvenance stores the provenance sidecar of a snapshot; nil stores none
+func (s *SnapshotStore) saveProvenance(id string, prov []byte) error {
+	if prov == nil {
+		return nil
+	}
+	return internal.WriteFileAtomic(s.provenancePath(id), prov, 0644)
+}
+
+// writeLog rewrites log.jsonl, dropping the oldest snapshots beyond retention
+func (s *SnapshotStore) writeLog(log []Snapshot) error {
+	if len(log) > s.retention {
+		for _, old := range log[:len(log)-s.retention] {
+			for _, path := range []string{s.archivePath(old.ID), s.provenancePath(old.ID)} {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					internal.LogError("Failed to remove snapshot %s: %v", old.ID, err)
+				}
+			}
+		}
+		log = log[len(log)-s.retention:]
+	}
+
+	var buf bytes.Buffer
+	for _, snap := range log {
+		line, err := json.Marshal(snap)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return internal.WriteFileAtomic(filepath.Join(s.dir, snapshotLogName), buf.Bytes(), 0644)
+}
+
+func (s *SnapshotStore) archivePath(id string) string {
+	return filepath.Join(s.dir, id+".tar.gz")
+}
+
+func (s *SnapshotStore) provenancePath(id string) string {
+	return filepath.Join(s.dir, id+"."+provenanceFileName)
+}
+
+// tarFiles builds a gzipped tar archive of the given files, in name order
+func tarFiles(files map[string]string, modTime time.Time) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), ModTime: modTime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sameFiles(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, content := range a {
+		if other, ok := b[name]; !ok || other != content {
+			return false
+		}
+	}
+	return true
+}
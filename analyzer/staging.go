@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// stagingDirName is the directory under .memo where a batch edits its copy of the index
+const stagingDirName = "staging"
+
+// runStagedBatch analyses a batch against a snapshot of the index and commits
+// the result only when the batch succeeds. A failed or cancelled batch leaves
+// the index exactly as it was. read holds the hashes of the files when the
+// batch was built.
+func (a *Analyser) runStagedBatch(ctx context.Context, files []string, read map[string]string, batchNum, totalBatches int) error {
+	stagingDir := a.scratchDir(stagingDirName)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	if err := internal.CopyDir(a.indexDir, stagingDir); err != nil {
+		return fmt.Errorf("failed to snapshot index: %w", err)
+	}
+	err := a.analyseBatch(ctx, files, batchNum, totalBatches, stagingDir, a.sessionID)
+	if err == nil {
+		err = ctx.Err() // cancelled after the last prompt: do not commit
+	}
+	if err != nil {
+		internal.LogInfo("Batch %d/%d failed, discarding its changes", batchNum, totalBatches)
+		return err
+	}
+	update := provenanceUpdate{files: files, read: read, before: readIndexFiles(a.indexDir), after: readIndexFiles(stagingDir)}
+	if err := commitIndex(stagingDir, a.indexDir); err != nil {
+		return fmt.Errorf("failed to commit index: %w", err)
+	}
+	a.recordProvenance(update)
+	return nil
+}
+
+// commitIndex makes the JSON files of indexDir match srcDir
+func commitIndex(srcDir, indexDir string) error {
+	return commitFiles(readIndexFiles(srcDir), indexDir)
+}
+
+// commitFiles makes the JSON files of indexDir match src (name -> content).
+// Each changed file is replaced by rename, so readers see either the old or
+// the new version.
+func commitFiles(src map[string]string, indexDir string) error {
+	dst := readIndexFiles(indexDir)
+
+	for name, content := range src {
+		if old, ok := dst[name]; ok && old == content {
+			continue
+		}
+		if err := internal.WriteFileAtomic(filepath.Join(indexDir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+		internal.LogDebug("Committed %s", name)
+	}
+	for name := range dst {
+		if _, ok := src[name]; !ok {
+			if err := os.Remove(filepath.Join(indexDir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			internal.LogDebug("Removed %s", name)
+		}
+	}
+	return nil
+}
@@ -11,8 +11,9 @@ const statusFileName = "status.json"
 
 // Status represents the current analysis status
 type Status struct {
-	Status string     `json:"status"`          // "idle" | "analyzing"
-	Since  *time.Time `json:"since,omitempty"` // when analysis started
+	Status   string     `json:"status"`             // "idle" | "analyzing" | "throttled"
+	Since    *time.Time `json:"since,omitempty"`    // when analysis started or was throttled
+	Throttle *Throttle  `json:"throttle,omitempty"` // why changes are waiting, when throttled
 }
 
 // SetStatus writes status to .memo/status.json
@@ -33,6 +34,16 @@ func SetStatus(memoDir string, status string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// SetThrottled records in .memo/status.json that changes wait for the budget
+func SetThrottled(memoDir string, t *Throttle) error {
+	now := time.Now()
+	data, err := json.Marshal(Status{Status: "throttled", Since: &now, Throttle: t})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(memoDir, statusFileName), data, 0644)
+}
+
 // GetStatus reads status from .memo/status.json
 // Returns "idle" if file doesn't exist or is invalid
 func GetStatus(memoDir string) Status {
@@ -0,0 +1,239 @@
+package analyzer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YoungY620/memo/internal"
+)
+
+// usageFileName is the file under .memo where token usage is appended
+const usageFileName = "usage.jsonl"
+
+// Usage record kinds
+const (
+	UsageBatch = "batch" // one batch of a run
+	UsageRun   = "run"   // total of a run
+)
+
+// TokenUsage counts the tokens spent on one or more prompts
+type TokenUsage struct {
+	Prompts       int `json:"prompts"`
+	Input         int `json:"input"`
+	Output        int `json:"output"`
+	CacheRead     int `json:"cache_read,omitempty"`
+	CacheCreation int `json:"cache_creation,omitempty"`
+}
+
+// Add adds the counts of o
+func (u *TokenUsage) Add(o TokenUsage) {
+	u.Prompts += o.Prompts
+	u.Input += o.Input
+	u.Output += o.Output
+	u.CacheRead += o.CacheRead
+	u.CacheCreation += o.CacheCreation
+}
+
+// Total returns all input and output tokens
+func (u TokenUsage) Total() int {
+	return u.Input + u.CacheRead + u.CacheCreation + u.Output
+}
+
+// UsageRecord is one line of .memo/usage.jsonl. Initial counts the first prompt
+// of each batch, Feedback the prompts sent after failed validations.
+type UsageRecord struct {
+	Time     time.Time  `json:"time"`
+	Kind     string     `json:"kind"` // UsageBatch or UsageRun
+	Run      string     `json:"run"`  // shared by a run and its batches
+	Session  string     `json:"session"`
+	Backend  string     `json:"backend"`
+	Model    string     `json:"model,omitempty"`
+	Batch    int        `json:"batch,omitempty"`
+	Files    int        `json:"files"`
+	Initial  TokenUsage `json:"initial"`
+	Feedback TokenUsage `json:"feedback"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// usageMeter accumulates the token usage of one analysis run and appends a
+// record per batch and one for the run. Methods are nil-safe.
+type usageMeter struct {
+	path string
+
+	mu  sync.Mutex
+	run UsageRecord
+}
+
+func newUsageMeter(memoDir string, agentCfg AgentConfig, sessionID string) *usageMeter {
+	backend := agentCfg.Backend
+	if backend == "" {
+		backend = BackendKimi
+	}
+	now := time.Now()
+	return &usageMeter{
+		path: filepath.Join(memoDir, usageFileName),
+		run: UsageRecord{
+			Time:    now,
+			Kind:    UsageRun,
+			Run:     now.Format("20060102-150405.000"),
+			Session: sessionID,
+			Backend: backend,
+			Model:   agentCfg.Model,
+		},
+	}
+}
+
+// addBatch records the usage of a finished batch
+func (m *usageMeter) addBatch(batchNum, files int, initial, feedback TokenUsage, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec := m.run
+	rec.Time = time.Now()
+	rec.Kind = UsageBatch
+	rec.Batch = batchNum
+	rec.Files = files
+	rec.Initial, rec.Feedback = initial, feedback
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	m.append(rec)
+
+	m.run.Files += files
+	m.run.Initial.Add(initial)
+	m.run.Feedback.Add(feedback)
+}
+
+// finish records the run total
+func (m *usageMeter) finish(err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec := m.run
+	rec.Time = time.Now()
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	m.append(rec)
+
+	total := rec.Initial
+	total.Add(rec.Feedback)
+	internal.LogInfo("Token usage: %d input, %d output in %d prompt(s), %d for validation feedback",
+		total.Input+total.CacheRead+total.CacheCreation, total.Output, total.Prompts, rec.Feedback.Prompts)
+}
+
+func (m *usageMeter) append(rec UsageRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		internal.LogError("Failed to encode usage: %v", err)
+		return
+	}
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		internal.LogError("Failed to record usage: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		internal.LogError("Failed to record usage: %v", err)
+	}
+}
+
+// ReadUsage reads .memo/usage.jsonl; a missing file yields no records and
+// invalid lines are skipped
+func ReadUsage(workDir string) ([]UsageRecord, error) {
+	f, err := os.Open(filepath.Join(workDir, ".memo", usageFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec UsageRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			// An interrupted append leaves a partial line; the rest still counts
+			internal.LogError("Skipping invalid usage record on line %d of %s: %v", n, usageFileName, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// UsageSummary is the usage of one day, model and trigger (the prompt kind:
+// "initial" or "feedback")
+type UsageSummary struct {
+	Day     string
+	Model   string
+	Trigger string
+	Runs    int
+	TokenUsage
+}
+
+// SummarizeUsage aggregates run records by day (local time), model and
+// trigger, in that order. Batch records are skipped, as runs include them.
+func SummarizeUsage(records []UsageRecord) []UsageSummary {
+	type key struct{ day, model, trigger string }
+	sums := make(map[key]*UsageSummary)
+	for _, rec := range records {
+		if rec.Kind != UsageRun {
+			continue
+		}
+		model := rec.Model
+		if model == "" {
+			model = rec.Backend + " default"
+		}
+		day := rec.Time.Local().Format("2006-01-02")
+		for _, t := range []struct {
+			trigger string
+			usage   TokenUsage
+		}{{"initial", rec.Initial}, {"feedback", rec.Feedback}} {
+			if t.usage.Prompts == 0 {
+				continue
+			}
+			k := key{day, model, t.trigger}
+			if sums[k] == nil {
+				sums[k] = &UsageSummary{Day: day, Model: model, Trigger: t.trigger}
+			}
+			sums[k].Runs++
+			sums[k].Add(t.usage)
+		}
+	}
+
+	result := make([]UsageSummary, 0, len(sums))
+	for _, s := range sums {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Trigger > b.Trigger // initial before feedback
+	})
+	return result
+}
@@ -137,17 +137,31 @@ var schemas = map[string]string{
 	}`,
 }
 
+// DefaultIndexFiles is the content of a new, empty index without
+// project-defined files (see IndexFiles.Initial)
+var DefaultIndexFiles = map[string]string{
+	"arch.json":      `{"modules": [], "relationships": ""}`,
+	"interface.json": `{"external": [], "internal": []}`,
+	"stories.json":   `{"stories": []}`,
+	"issues.json":    `{"issues": []}`,
+}
+
 // ValidationResult holds the result of index validation
 type ValidationResult struct {
 	Valid  bool
 	Errors []string
 }
 
-// ValidateIndex validates all JSON files in the index directory
+// ValidateIndex validates the built-in files in the index directory
 func ValidateIndex(indexDir string) ValidationResult {
+	return builtinIndexFiles.Validate(indexDir)
+}
+
+// Validate validates every index file in the index directory
+func (f *IndexFiles) Validate(indexDir string) ValidationResult {
 	var allErrors []string
 
-	for filename, schemaJSON := range schemas {
+	for filename, schemaJSON := range f.schemas {
 		filePath := filepath.Join(indexDir, filename)
 		data, err := os.ReadFile(filePath)
 		if err != nil {
@@ -15,13 +15,17 @@ type Watcher struct {
 	debounceMs, maxWaitMs int
 	ignorePatterns        []string
 	onChange              func([]string)
+	onRename              func(oldPath, newPath string) // paired rename events, if set
+	beforeFlush           func([]string) []string       // may replace each change set, if set
 	watcher               *fsnotify.Watcher
 	rootPath              string
 
 	mu                sync.Mutex
 	pending           map[string]struct{}
 	debounce, maxWait *time.Timer
+	retry             *time.Timer   // flush of files postponed by the budget
 	sem               chan struct{} // capacity 1 semaphore for analysis guard
+	budget            *Budget       // spending limits checked before each analysis, if set
 }
 
 func NewWatcher(root string, ignore []string, debounceMs, maxWaitMs int, onChange func([]string)) (*Watcher, error) {
@@ -46,6 +50,26 @@ func NewWatcher(root string, ignore []string, debounceMs, maxWaitMs int, onChang
 	return w, nil
 }
 
+// SetBudget makes Flush check each change set against spending limits and
+// postpone what does not fit
+func (w *Watcher) SetBudget(b *Budget) {
+	w.budget = b
+}
+
+// SetOnRename reports a file renamed within the tree, detected as a Rename
+// event immediately followed by a Create event. Both paths are still added
+// to the pending changes.
+func (w *Watcher) SetOnRename(fn func(oldPath, newPath string)) {
+	w.onRename = fn
+}
+
+// SetBeforeFlush sets a function run on each change set before it is
+// analysed, with the analysis guard held; it returns the files to analyse.
+// It also runs when Flush finds nothing pending.
+func (w *Watcher) SetBeforeFlush(fn func(files []string) []string) {
+	w.beforeFlush = fn
+}
+
 func (w *Watcher) watchAll(dir string) error {
 	return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
 		if err != nil || !d.IsDir() {
@@ -58,27 +82,71 @@ func (w *Watcher) watchAll(dir string) error {
 	})
 }
 
-// ScanAll traverses all files and adds them to pending, triggering initial analysis
-func (w *Watcher) ScanAll() {
-	count := 0
-	_ = filepath.WalkDir(w.rootPath, func(p string, d os.DirEntry, err error) error {
+// Files returns every file under the root that is not ignored
+func (w *Watcher) Files() []string {
+	return ListFiles(w.rootPath, w.ignorePatterns)
+}
+
+// ListFiles returns every file under root not matched by the ignore patterns
+func ListFiles(root string, ignore []string) []string {
+	var files []string
+	_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
 		}
-		if w.ignored(p) {
+		if isIgnored(root, p, ignore) {
 			return nil
 		}
-		w.add(p)
-		count++
+		files = append(files, p)
 		return nil
 	})
-	internal.LogDebug("ScanAll: added %d files to pending", count)
+	return files
+}
+
+// ScanAll traverses all files and adds them to pending, triggering initial analysis
+func (w *Watcher) ScanAll() {
+	files := w.Files()
+	for _, p := range files {
+		w.add(p)
+	}
+	internal.LogDebug("ScanAll: added %d files to pending", len(files))
+}
+
+// ScanChanged adds only the files that are new, modified or deleted relative
+// to the manifest, so a restart does not re-analyse an up-to-date codebase
+func (w *Watcher) ScanChanged(m *Manifest) {
+	files := w.Files()
+	changed := m.Changed(files)
+	for _, p := range changed {
+		if !w.ignored(p) {
+			w.add(p)
+		}
+	}
+	internal.LogInfo("Scan: %d of %d files changed since last run", len(changed), len(files))
+}
+
+// ScanFiles adds the given files (absolute paths, existing or deleted) to
+// pending, skipping ignored ones
+func (w *Watcher) ScanFiles(files []string) {
+	added := 0
+	for _, p := range files {
+		if !w.ignored(p) {
+			w.add(p)
+			added++
+		}
+	}
+	internal.LogInfo("Scan: %d of %d listed files to analyse", added, len(files))
 }
 
 func (w *Watcher) ignored(path string) bool {
-	rel, _ := filepath.Rel(w.rootPath, path)
+	return isIgnored(w.rootPath, path, w.ignorePatterns)
+}
+
+// isIgnored reports whether path under root matches an ignore pattern
+func isIgnored(root, path string, patterns []string) bool {
+	rel, _ := filepath.Rel(root, path)
 	base := filepath.Base(path)
-	for _, p := range w.ignorePatterns {
+	for _, p := range patterns {
 		if strings.HasPrefix(p, "*.") && strings.HasSuffix(path, p[1:]) {
 			return true
 		}
@@ -90,6 +158,7 @@ func (w *Watcher) ignored(path string) bool {
 }
 
 func (w *Watcher) Run() error {
+	var renamed string // path of the last event if it was a Rename
 	for {
 		select {
 		case e, ok := <-w.watcher.Events:
@@ -97,6 +166,7 @@ func (w *Watcher) Run() error {
 				return nil
 			}
 			if w.ignored(e.Name) {
+				renamed = ""
 				continue
 			}
 			internal.LogDebug("Event: %s %s", e.Op, e.Name)
@@ -104,8 +174,15 @@ func (w *Watcher) Run() error {
 				if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
 					internal.LogDebug("Watching new directory: %s", e.Name)
 					_ = w.watcher.Add(e.Name)
+				} else if renamed != "" && w.onRename != nil {
+					internal.LogDebug("Rename: %s -> %s", renamed, e.Name)
+					w.onRename(renamed, e.Name)
 				}
 			}
+			renamed = ""
+			if e.Op == fsnotify.Rename {
+				renamed = e.Name
+			}
 			if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
 				w.add(e.Name)
 			}
@@ -166,9 +243,59 @@ func (w *Watcher) Flush() {
 	w.pending = make(map[string]struct{})
 	w.mu.Unlock()
 
+	if w.beforeFlush != nil {
+		files = w.beforeFlush(files)
+	}
+
+	var throttle *Throttle
+	if len(files) > 0 && w.budget != nil {
+		var now []string
+		now, throttle = w.budget.Check(files)
+		if throttle != nil {
+			w.postpone(files, now, throttle)
+		}
+		files = now
+	}
+
 	if len(files) > 0 && w.onChange != nil {
 		w.onChange(files)
 	}
+	if throttle != nil {
+		if err := SetThrottled(filepath.Join(w.rootPath, ".memo"), throttle); err != nil {
+			internal.LogError("Failed to set status: %v", err)
+		}
+	}
+}
+
+// postpone returns the files of a throttled change set not in now to pending
+// and schedules a flush for when the budget frees up
+func (w *Watcher) postpone(files, now []string, t *Throttle) {
+	analysed := make(map[string]bool, len(now))
+	for _, f := range now {
+		analysed[f] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range files {
+		if !analysed[f] {
+			w.pending[f] = struct{}{}
+		}
+	}
+
+	// The rest of a reduced change set follows after the usual quiet period
+	delay := time.Duration(w.debounceMs) * time.Millisecond
+	if len(now) == 0 {
+		delay = time.Duration(w.maxWaitMs) * time.Millisecond
+	}
+	if t.Until != nil {
+		delay = max(time.Until(*t.Until), delay)
+	}
+	if w.retry != nil {
+		w.retry.Stop()
+	}
+	w.retry = time.AfterFunc(delay, w.Flush)
+	internal.LogInfo("Postponed %d files, retrying in %s", len(files)-len(now), delay.Round(time.Second))
 }
 
 func (w *Watcher) Close() error {
@@ -179,6 +306,9 @@ func (w *Watcher) Close() error {
 	if w.maxWait != nil {
 		w.maxWait.Stop()
 	}
+	if w.retry != nil {
+		w.retry.Stop()
+	}
 	w.mu.Unlock()
 	return w.watcher.Close()
 }
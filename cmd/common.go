@@ -3,24 +3,28 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/YoungY620/memo/analyzer"
 	"github.com/YoungY620/memo/internal"
+	"github.com/YoungY620/memo/mcp"
 )
 
-// initIndex initializes the .memo/index directory with default files
-func initIndex(indexDir string) error {
+// initIndex initializes the .memo/index directory with the initial content
+// of files
+func initIndex(indexDir string, files *analyzer.IndexFiles) error {
+	_, err := os.Stat(indexDir)
+	isNew := os.IsNotExist(err)
 	if err := os.MkdirAll(indexDir, 0755); err != nil {
 		return err
 	}
-
-	files := map[string]string{
-		"arch.json":      `{"modules": [], "relationships": ""}`,
-		"interface.json": `{"external": [], "internal": []}`,
-		"stories.json":   `{"stories": []}`,
-		"issues.json":    `{"issues": []}`,
+	if isNew {
+		if err := analyzer.WriteIndexMeta(indexDir); err != nil {
+			return err
+		}
 	}
 
-	for name, content := range files {
+	for name, content := range files.Initial() {
 		path := filepath.Join(indexDir, name)
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			internal.LogDebug("Creating %s", path)
@@ -42,20 +46,86 @@ func initIndex(indexDir string) error {
 		}
 	}
 
-	// Create .gitignore to exclude runtime files from version control
-	gitignoreFile := filepath.Join(memoDir, ".gitignore")
-	if _, err := os.Stat(gitignoreFile); os.IsNotExist(err) {
-		gitignoreContent := `# Runtime files - do not commit
-watcher.lock
-status.json
-.history
-`
-		internal.LogDebug("Creating %s", gitignoreFile)
-		if err := os.WriteFile(gitignoreFile, []byte(gitignoreContent), 0644); err != nil {
-			return err
+	// Create .gitignore to exclude runtime files from version control; one
+	// written by an older memo gets the files added since
+	if err := updateGitignore(filepath.Join(memoDir, ".gitignore")); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// gitignoreEntries are the runtime files under .memo that must not be committed
+var gitignoreEntries = []string{
+	"watcher.lock",
+	"status.json",
+	".history",
+	"recordings/",
+	"fragments/",
+	"manifest.json",
+	"queue.json",
+	"staging/",
+	"snapshots/",
+	"index-rebuild/",
+	"staging-rebuild/",
+	"fragments-rebuild/",
+	"usage.jsonl",
+	"objects/",
+	"revision.json",
+	"branch.json",
+	"branches/",
+	"inbox",
+	"inbox.draining",
+	"hooks.log",
+	"provenance.json",
+	"provenance-rebuild.json",
+}
+
+// updateGitignore creates .memo/.gitignore, or appends the runtime files an
+// existing one does not list yet. Other lines are left alone.
+func updateGitignore(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	listed := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		listed[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, entry := range gitignoreEntries {
+		if !listed[entry] {
+			missing = append(missing, entry)
 		}
 	}
+	if len(missing) == 0 {
+		return nil
+	}
 
+	var b strings.Builder
+	b.Write(data)
+	if len(data) == 0 {
+		b.WriteString("# Runtime files - do not commit\n")
+		internal.LogDebug("Creating %s", path)
+	} else {
+		if !strings.HasSuffix(string(data), "\n") {
+			b.WriteString("\n")
+		}
+		internal.LogDebug("Adding %d runtime file(s) to %s", len(missing), path)
+	}
+	b.WriteString(strings.Join(missing, "\n") + "\n")
+	return internal.WriteFileAtomic(path, []byte(b.String()), 0644)
+}
+
+// migrateIndex upgrades an index written by an older memo to the current format
+func migrateIndex(indexDir string, files *analyzer.IndexFiles) error {
+	result, err := analyzer.MigrateIndex(indexDir, files, false)
+	if err != nil {
+		return err
+	}
+	for _, m := range result.Applied {
+		internal.LogInfo("Migrated index to version %d: %s", m.To, m.Description)
+	}
 	return nil
 }
 
@@ -80,6 +150,98 @@ func loadConfigAndSetup(workDir string) (*Config, error) {
 		internal.LogError("Failed to load .gitignore: %v", err)
 	}
 	internal.LogDebug("Total ignore patterns: %d", len(cfg.Watch.IgnorePatterns))
-
 	return cfg, nil
 }
+
+// loadIndexFiles returns the files of the index: the built-in ones and the
+// project-defined ones declared in config
+func loadIndexFiles(cfg *Config, workDir string) (*analyzer.IndexFiles, error) {
+	custom, err := cfg.CustomIndexFiles(workDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range custom {
+		internal.LogDebug("Custom index file: %s", f.Name)
+	}
+	return analyzer.NewIndexFiles(custom)
+}
+
+// mcpFiles returns the index files queryable over MCP
+func mcpFiles(files *analyzer.IndexFiles) *mcp.Files {
+	var custom []mcp.File
+	for _, f := range files.Custom() {
+		custom = append(custom, mcp.File{Name: strings.TrimSuffix(f.Name, ".json"), Schema: f.Schema})
+	}
+	return mcp.NewFiles(custom)
+}
+
+// openBranches switches .memo/index to the checked-out branch when per-branch
+// indexes are enabled, reloading manifest and queue. Returns nil if they are
+// not, or the project is not a git repository.
+func openBranches(cfg *Config, workDir string, manifest *analyzer.Manifest, queue *analyzer.Queue) *analyzer.Branches {
+	if !cfg.Branches.Enabled {
+		return nil
+	}
+	branches, err := analyzer.OpenBranches(workDir)
+	if err != nil {
+		internal.LogNotice("Per-branch indexes disabled: %v", err)
+		return nil
+	}
+	if _, err := branches.Sync(manifest, queue); err != nil {
+		internal.LogError("Failed to switch branch: %v", err)
+	}
+	return branches
+}
+
+// loadManifest loads .memo/manifest.json; on error all files are treated as changed
+func loadManifest(workDir string) *analyzer.Manifest {
+	manifest, err := analyzer.LoadManifest(workDir)
+	if err != nil {
+		internal.LogError("Failed to load manifest, rescanning all files: %v", err)
+	}
+	return manifest
+}
+
+// loadQueue loads .memo/queue.json, discarding it when restart is set
+func loadQueue(workDir string, restart bool) *analyzer.Queue {
+	queue, err := analyzer.LoadQueue(workDir)
+	if err != nil {
+		internal.LogError("Failed to load queue, starting a new plan: %v", err)
+	}
+	if restart && queue.Len() > 0 {
+		internal.LogInfo("Discarding previous batch plan (--restart)")
+		if err := queue.Discard(); err != nil {
+			internal.LogError("Failed to discard queue: %v", err)
+		}
+	}
+	return queue
+}
+
+// newAnalyser creates an analyser of an index with files, using the agent
+// settings from config
+func newAnalyser(cfg *Config, workDir string, files *analyzer.IndexFiles) (*analyzer.Analyser, error) {
+	agentCfg := analyzer.AgentConfig{
+		Backend:   cfg.Agent.Backend,
+		APIKey:    cfg.Agent.APIKey,
+		Model:     cfg.Agent.Model,
+		BaseURL:   cfg.Agent.BaseURL,
+		ScriptDir: cfg.Agent.ScriptDir,
+	}
+	ana, err := analyzer.NewAnalyser(agentCfg, workDir)
+	if err != nil {
+		return nil, err
+	}
+	ana.SetIndexFiles(files)
+	ana.SetWorkers(cfg.Analysis.Workers)
+	ana.SetTokenBudget(cfg.TokenBudget())
+	ana.SetMaxDiffTokens(cfg.Analysis.MaxDiffTokens)
+	ana.SetSnapshots(analyzer.OpenSnapshots(workDir, cfg.Snapshots.Retention))
+	if err := ana.SetBatchStrategy(cfg.Analysis.BatchStrategy); err != nil {
+		return nil, err
+	}
+	if err := ana.SetPrompts(cfg.PromptsDir(workDir)); err != nil {
+		return nil, err
+	}
+	ana.SetLanguage(cfg.Prompts.Language)
+	return ana, nil
+}
@@ -2,23 +2,152 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/YoungY620/memo/analyzer"
 	"github.com/YoungY620/memo/internal"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Agent    AgentConfig `yaml:"agent"`
-	Watch    WatchConfig `yaml:"watch"`
-	LogLevel string      `yaml:"log_level"` // error, notice, info, debug
+	Agent      AgentConfig       `yaml:"agent"`
+	Analysis   AnalysisConfig    `yaml:"analysis"`
+	Snapshots  SnapshotsConfig   `yaml:"snapshots"`
+	Rebuild    RebuildConfig     `yaml:"rebuild"`
+	Branches   BranchesConfig    `yaml:"branches"`
+	Budget     BudgetConfig      `yaml:"budget"`
+	Prompts    PromptsConfig     `yaml:"prompts"`
+	IndexFiles []IndexFileConfig `yaml:"index_files"`
+	Lint       LintConfig        `yaml:"lint"`
+	Watch      WatchConfig       `yaml:"watch"`
+	LogLevel   string            `yaml:"log_level"` // error, notice, info, debug
 }
 
 type AgentConfig struct {
-	APIKey string `yaml:"api_key"`
-	Model  string `yaml:"model"`
+	Backend   string `yaml:"backend"` // kimi (default), openai or scripted
+	APIKey    string `yaml:"api_key"`
+	Model     string `yaml:"model"`
+	BaseURL   string `yaml:"base_url"`   // OpenAI-compatible endpoint, e.g. http://localhost:8000/v1
+	ScriptDir string `yaml:"script_dir"` // canned turns for the scripted backend
+}
+
+type AnalysisConfig struct {
+	Workers       int            `yaml:"workers"`         // batches analysed concurrently, each in its own session
+	TokenBudget   int            `yaml:"token_budget"`    // estimated source tokens per batch
+	ModelBudgets  map[string]int `yaml:"model_budgets"`   // per-model overrides of token_budget, keyed by agent.model
+	BatchStrategy string         `yaml:"batch_strategy"`  // directory (default) or deps
+	MaxDiffTokens int            `yaml:"max_diff_tokens"` // largest diff sent instead of the full file; -1 disables diffs
+}
+
+type SnapshotsConfig struct {
+	Retention int `yaml:"retention"` // snapshots kept in .memo/snapshots
+}
+
+type RebuildConfig struct {
+	Enabled          bool `yaml:"enabled"`           // periodically rebuild the index in a fresh session
+	IntervalMinutes  int  `yaml:"interval_minutes"`  // time between rebuilds
+	StabilizeMinutes int  `yaml:"stabilize_minutes"` // wait after a rebuild completes before swapping it in
+}
+
+type BranchesConfig struct {
+	Enabled bool `yaml:"enabled"` // keep one index per git branch in .memo/branches
+}
+
+// BudgetConfig limits the spending of memo watch; 0 means unlimited
+type BudgetConfig struct {
+	RunTokens    int    `yaml:"run_tokens"`    // estimated tokens per analysis run
+	HourTokens   int    `yaml:"hour_tokens"`   // tokens used in the last hour
+	DayTokens    int    `yaml:"day_tokens"`    // tokens used in the last 24 hours
+	HourRequests int    `yaml:"hour_requests"` // prompts sent in the last hour
+	DayRequests  int    `yaml:"day_requests"`  // prompts sent in the last 24 hours
+	Mode         string `yaml:"mode"`          // defer (default) or reduced
+}
+
+// Limits returns the budget as analyser limits, or nil if nothing is limited
+func (b BudgetConfig) Limits() *analyzer.BudgetLimits {
+	if b.RunTokens <= 0 && b.HourTokens <= 0 && b.DayTokens <= 0 && b.HourRequests <= 0 && b.DayRequests <= 0 {
+		return nil
+	}
+	return &analyzer.BudgetLimits{
+		RunTokens:    b.RunTokens,
+		HourTokens:   b.HourTokens,
+		DayTokens:    b.DayTokens,
+		HourRequests: b.HourRequests,
+		DayRequests:  b.DayRequests,
+		Mode:         b.Mode,
+	}
+}
+
+type PromptsConfig struct {
+	Dir      string `yaml:"dir"`      // <name>.md overrides of the built-in prompts (default .memo/prompts)
+	Language string `yaml:"language"` // language of the index content, available to templates as .Language
+}
+
+// PromptsDir returns the prompt override directory, relative paths resolved
+// against workDir
+func (c *Config) PromptsDir(workDir string) string {
+	dir := c.Prompts.Dir
+	if dir == "" {
+		return filepath.Join(workDir, ".memo", "prompts")
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workDir, dir)
+	}
+	return dir
+}
+
+// LintConfig configures memo lint
+type LintConfig struct {
+	Rules           map[string]string `yaml:"rules"`            // severity per rule: error (default), warning or off
+	MaxFileKB       int               `yaml:"max_file_kb"`      // size limit of each index file; 0 for none
+	RequiredModules []string          `yaml:"required_modules"` // modules arch.json must describe
+}
+
+// Options returns the lint configuration as analyser options
+func (l LintConfig) Options() analyzer.LintOptions {
+	return analyzer.LintOptions{
+		Severity:        l.Rules,
+		MaxFileBytes:    int64(l.MaxFileKB) * 1024,
+		RequiredModules: l.RequiredModules,
+	}
+}
+
+// IndexFileConfig declares a project-defined index file
+type IndexFileConfig struct {
+	Name      string              `yaml:"name"`       // file name in .memo/index, e.g. decisions.json
+	Schema    string              `yaml:"schema"`     // JSON Schema file, relative to the work directory
+	Prompt    string              `yaml:"prompt"`     // what the agent should record in the file
+	Initial   string              `yaml:"initial"`    // content of a new index (default: derived from the schema)
+	MergeKeys map[string][]string `yaml:"merge_keys"` // entry keys of top-level arrays, e.g. {decisions: [title]}
+}
+
+// CustomIndexFiles reads the schemas of the declared index files
+func (c *Config) CustomIndexFiles(workDir string) ([]analyzer.IndexFile, error) {
+	var files []analyzer.IndexFile
+	for _, f := range c.IndexFiles {
+		if f.Schema == "" {
+			return nil, fmt.Errorf("index file %s: schema is required", f.Name)
+		}
+		path := f.Schema
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, path)
+		}
+		schema, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("index file %s: %w", f.Name, err)
+		}
+		files = append(files, analyzer.IndexFile{
+			Name:      f.Name,
+			Schema:    string(schema),
+			Prompt:    f.Prompt,
+			Initial:   f.Initial,
+			MergeKeys: f.MergeKeys,
+		})
+	}
+	return files, nil
 }
 
 type WatchConfig struct {
@@ -28,7 +157,8 @@ type WatchConfig struct {
 }
 
 func LoadConfig(path string) (*Config, error) {
-	cfg := &Config{}
+	// Defaults whose zero value is a valid setting, kept if the file omits them
+	cfg := &Config{Lint: LintConfig{MaxFileKB: 1024}}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -42,6 +172,14 @@ func LoadConfig(path string) (*Config, error) {
 		}
 	}
 
+	// Environment overrides (used by CI to run without a real agent)
+	if v := os.Getenv("MEMO_AGENT_BACKEND"); v != "" {
+		cfg.Agent.Backend = v
+	}
+	if v := os.Getenv("MEMO_SCRIPT_DIR"); v != "" {
+		cfg.Agent.ScriptDir = v
+	}
+
 	// Apply defaults
 	if cfg.Watch.DebounceMs == 0 {
 		cfg.Watch.DebounceMs = 30000 // 30 seconds quiet period
@@ -49,12 +187,33 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Watch.MaxWaitMs == 0 {
 		cfg.Watch.MaxWaitMs = 300000 // 5 minutes max wait
 	}
+	if cfg.Analysis.Workers <= 0 {
+		cfg.Analysis.Workers = 1
+	}
+	if cfg.Snapshots.Retention <= 0 {
+		cfg.Snapshots.Retention = analyzer.DefaultSnapshotRetention
+	}
+	if cfg.Rebuild.IntervalMinutes <= 0 {
+		cfg.Rebuild.IntervalMinutes = 30
+	}
+	if cfg.Rebuild.StabilizeMinutes <= 0 {
+		cfg.Rebuild.StabilizeMinutes = 5
+	}
 	if len(cfg.Watch.IgnorePatterns) == 0 {
 		cfg.Watch.IgnorePatterns = []string{".git", "node_modules", ".memo", "*.log"}
 	}
 	return cfg, nil
 }
 
+// TokenBudget returns the batch token budget for the configured model;
+// 0 means the analyser default
+func (c *Config) TokenBudget() int {
+	if budget, ok := c.Analysis.ModelBudgets[c.Agent.Model]; ok && budget > 0 {
+		return budget
+	}
+	return c.Analysis.TokenBudget
+}
+
 // LoadGitignore parses a .gitignore file and returns the patterns.
 // It handles comments, empty lines, and basic gitignore syntax.
 func LoadGitignore(workDir string) ([]string, error) {
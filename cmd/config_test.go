@@ -243,3 +243,129 @@ func TestMergeGitignore_NoGitignore(t *testing.T) {
 	// Should not change config
 	assert.Equal(t, originalLen, len(cfg.Watch.IgnorePatterns))
 }
+
+func TestLoadConfig_AgentBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	content := `
+agent:
+  backend: openai
+  model: gpt-test
+  base_url: http://localhost:8000/v1
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	cfg, err := LoadConfig(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, "openai", cfg.Agent.Backend)
+	assert.Equal(t, "gpt-test", cfg.Agent.Model)
+	assert.Equal(t, "http://localhost:8000/v1", cfg.Agent.BaseURL)
+}
+
+func TestLoadConfig_AnalysisWorkers(t *testing.T) {
+	cfg, err := LoadConfig("nonexistent.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.Analysis.Workers, "default should analyse batches sequentially")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("analysis:\n  workers: 4\n"), 0644))
+
+	cfg, err = LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.Analysis.Workers)
+}
+
+func TestConfig_TokenBudget(t *testing.T) {
+	cfg := &Config{}
+	assert.Equal(t, 0, cfg.TokenBudget(), "unset budget uses the analyser default")
+
+	cfg.Analysis.TokenBudget = 50000
+	cfg.Analysis.ModelBudgets = map[string]int{"small-model": 8000}
+	assert.Equal(t, 50000, cfg.TokenBudget())
+
+	cfg.Agent.Model = "small-model"
+	assert.Equal(t, 8000, cfg.TokenBudget())
+}
+
+func TestLoadConfig_Rebuild(t *testing.T) {
+	cfg, err := LoadConfig("nonexistent.yaml")
+	require.NoError(t, err)
+	assert.False(t, cfg.Rebuild.Enabled)
+	assert.Equal(t, 30, cfg.Rebuild.IntervalMinutes)
+	assert.Equal(t, 5, cfg.Rebuild.StabilizeMinutes)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("rebuild:\n  enabled: true\n  interval_minutes: 60\n"), 0644))
+
+	cfg, err = LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.True(t, cfg.Rebuild.Enabled)
+	assert.Equal(t, 60, cfg.Rebuild.IntervalMinutes)
+	assert.Equal(t, 5, cfg.Rebuild.StabilizeMinutes)
+}
+
+func TestBudgetConfig_Limits(t *testing.T) {
+	assert.Nil(t, BudgetConfig{Mode: "reduced"}.Limits(), "no limit set means no budget")
+
+	limits := BudgetConfig{DayTokens: 1000, Mode: "reduced"}.Limits()
+	require.NotNil(t, limits)
+	assert.Equal(t, 1000, limits.DayTokens)
+	assert.Equal(t, "reduced", limits.Mode)
+}
+
+func TestConfig_PromptsDir(t *testing.T) {
+	workDir := t.TempDir()
+	cfg := &Config{}
+	assert.Equal(t, filepath.Join(workDir, ".memo", "prompts"), cfg.PromptsDir(workDir))
+
+	cfg.Prompts.Dir = "docs/prompts"
+	assert.Equal(t, filepath.Join(workDir, "docs", "prompts"), cfg.PromptsDir(workDir))
+
+	cfg.Prompts.Dir = "/etc/memo/prompts"
+	assert.Equal(t, "/etc/memo/prompts", cfg.PromptsDir(workDir))
+}
+
+func TestConfig_CustomIndexFiles(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "decisions.schema.json"), []byte(`{"type": "object"}`), 0644))
+
+	cfg := &Config{IndexFiles: []IndexFileConfig{{
+		Name:      "decisions.json",
+		Schema:    "decisions.schema.json",
+		Prompt:    "Architecture decisions",
+		MergeKeys: map[string][]string{"decisions": {"title"}},
+	}}}
+	files, err := cfg.CustomIndexFiles(workDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, `{"type": "object"}`, files[0].Schema)
+	assert.Equal(t, []string{"title"}, files[0].MergeKeys["decisions"])
+
+	cfg.IndexFiles[0].Schema = "missing.json"
+	_, err = cfg.CustomIndexFiles(workDir)
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_Lint(t *testing.T) {
+	cfg, err := LoadConfig("nonexistent.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), cfg.Lint.Options().MaxFileBytes)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("lint:\n  rules:\n    issue-locations: warning\n  max_file_kb: 64\n  required_modules: [core]\n"), 0644))
+
+	cfg, err = LoadConfig(configPath)
+	require.NoError(t, err)
+	opts := cfg.Lint.Options()
+	assert.Equal(t, map[string]string{"issue-locations": "warning"}, opts.Severity)
+	assert.Equal(t, int64(64*1024), opts.MaxFileBytes)
+	assert.Equal(t, []string{"core"}, opts.RequiredModules)
+
+	// 0 turns the size limit off
+	require.NoError(t, os.WriteFile(configPath, []byte("lint:\n  max_file_kb: 0\n"), 0644))
+	cfg, err = LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Lint.Options().MaxFileBytes)
+}
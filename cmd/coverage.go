@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	coverageFormat string
+	coverageQueue  bool
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Report which source files the index describes",
+	Long: `Maps every non-ignored file and directory to the index entries that mention
+it: issue locations by path, modules and submodules named after the file or a
+directory containing it, and interfaces whose name appears in the file.
+Reports the directories without a covered file and the overall percentage.
+Binary files are left out.
+
+Formats: text (default), json and html. With --queue, the uncovered files are
+forgotten by the manifest and queued, so the next scan or watch analyses them.`,
+	Args: cobra.NoArgs,
+	RunE: runCoverage,
+}
+
+func init() {
+	coverageCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	coverageCmd.Flags().StringVar(&coverageFormat, "format", "text", "output format: text, json or html")
+	coverageCmd.Flags().BoolVar(&coverageQueue, "queue", false, "queue the uncovered files for analysis")
+	rootCmd.AddCommand(coverageCmd)
+}
+
+func runCoverage(cmd *cobra.Command, args []string) error {
+	switch coverageFormat {
+	case "text", "json", "html":
+	default:
+		return fmt.Errorf("unknown format: %q (available: text, json, html)", coverageFormat)
+	}
+
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfigAndSetup(workDir)
+	if err != nil {
+		return err
+	}
+
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	if _, err := os.Stat(indexDir); os.IsNotExist(err) {
+		return fmt.Errorf("index directory not found: %s\nRun 'memo' or 'memo scan' first to initialize the index", indexDir)
+	}
+	// Binaries are not analysed, so they are neither counted nor queued
+	files := analyzer.TextFiles(analyzer.ListFiles(workDir, cfg.Watch.IgnorePatterns))
+	cov, err := analyzer.ComputeCoverage(indexDir, workDir, files)
+	if err != nil {
+		return err
+	}
+
+	// Notes go to stderr when stdout carries a report for other tools
+	notes := io.Writer(os.Stdout)
+	switch coverageFormat {
+	case "json":
+		notes = os.Stderr
+		data, err := json.MarshalIndent(cov, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "html":
+		notes = os.Stderr
+		if err := coverageHTML.Execute(os.Stdout, cov); err != nil {
+			return err
+		}
+	default:
+		fmt.Printf("Coverage: %d of %d files (%.1f%%)\n", cov.Covered, cov.Total, cov.Percent)
+		if len(cov.UncoveredDirs) > 0 {
+			fmt.Println("\nUncovered directories:")
+			for _, dir := range cov.UncoveredDirs {
+				for _, d := range cov.Dirs {
+					if d.Path == dir {
+						fmt.Printf("  %s/ (%d files)\n", dir, d.Files)
+					}
+				}
+			}
+		}
+	}
+
+	uncovered := cov.Uncovered()
+	switch {
+	case len(uncovered) == 0:
+	case coverageQueue:
+		if err := queueFiles(workDir, uncovered); err != nil {
+			return err
+		}
+		fmt.Fprintf(notes, "\nQueued %d uncovered files; the next 'memo scan' or 'memo watch' analyses them\n", len(uncovered))
+	default:
+		fmt.Fprintf(notes, "\n%d files are not covered; run 'memo coverage --queue' to queue them for analysis\n", len(uncovered))
+	}
+	return nil
+}
+
+// queueFiles makes the next analysis include files (relative to workDir)
+// even if they did not change: they are forgotten by the manifest and added
+// to the inbox. A running watcher would overwrite the manifest, so this
+// needs the lock.
+func queueFiles(workDir string, relFiles []string) error {
+	memoDir := filepath.Join(workDir, ".memo")
+	lockFile, err := analyzer.TryLock(memoDir)
+	if err != nil {
+		return fmt.Errorf("cannot queue files while memo is running: %w", err)
+	}
+	defer analyzer.Unlock(lockFile)
+
+	manifest := loadManifest(workDir)
+	manifest.Forget(relFiles)
+	if err := manifest.Save(); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	return analyzer.AppendInbox(memoDir, relFiles)
+}
+
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>memo coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Index coverage: {{printf "%.1f" .Percent}}%</h1>
+<p>{{.Covered}} of {{.Total}} files are mentioned by the index.</p>
+{{if .UncoveredDirs}}<h2>Uncovered directories</h2>
+<ul>{{range .UncoveredDirs}}<li>{{.}}/</li>{{end}}</ul>
+{{end}}<h2>Directories</h2>
+<table>
+<tr><th>Directory</th><th>Covered files</th><th>Modules</th></tr>
+{{range .Dirs}}<tr{{if eq .Covered 0}} class="uncovered"{{end}}><td>{{.Path}}</td><td>{{.Covered}} / {{.Files}}</td><td>{{range .Entries}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+<h2>Files</h2>
+<table>
+<tr><th>File</th><th>Index entries</th></tr>
+{{range .Files}}<tr{{if not .Entries}} class="uncovered"{{end}}><td>{{.Path}}</td><td>{{range .Entries}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
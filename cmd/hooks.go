@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/YoungY620/memo/internal"
+	"github.com/spf13/cobra"
+)
+
+// gitHooks are the hooks memo installs: each runs after git changed the
+// checked-out files
+var gitHooks = []string{"post-commit", "post-merge", "post-checkout"}
+
+// Markers around the lines memo adds to a hook, so they can be updated or
+// removed without touching the rest of the script
+const (
+	hookBegin = "# >>> memo >>>"
+	hookEnd   = "# <<< memo <<<"
+)
+
+const hooksLogName = "hooks.log"
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Update the index from git hooks instead of a watcher",
+	Long: `Installs post-commit, post-merge and post-checkout hooks that queue the files
+changed by git for analysis. If a watcher is running it picks them up;
+otherwise the hook starts 'memo scan --incremental' in the background. Hooks
+never block git; the output of background scans goes to .memo/hooks.log.`,
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the git hooks",
+	Args:  cobra.NoArgs,
+	RunE:  runHooksInstall,
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the git hooks",
+	Args:  cobra.NoArgs,
+	RunE:  runHooksUninstall,
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:    "run <hook> [git arguments...]",
+	Short:  "Queue the files changed by git (called by the hooks)",
+	Args:   cobra.MinimumNArgs(1),
+	Hidden: true,
+	RunE:   runHooksRun,
+}
+
+func init() {
+	hooksInstallCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	hooksRunCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	hooksCmd.AddCommand(hooksInstallCmd, hooksUninstallCmd, hooksRunCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
+
+func runHooksInstall(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+	hooksDir, err := internal.GitHooksDir(workDir)
+	if err != nil {
+		return fmt.Errorf("not a git repository: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the memo binary: %w", err)
+	}
+	config, err := filepath.Abs(configFlag)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	for _, hook := range gitHooks {
+		line := fmt.Sprintf("%s hooks run %s -p %s -c %s \"$@\" </dev/null >/dev/null 2>&1 &",
+			shellQuote(exe), hook, shellQuote(workDir), shellQuote(config))
+		block := hookBegin + "\n# Queue the changed files for analysis without blocking git\n" + line + "\n" + hookEnd + "\n"
+		if err := installHook(filepath.Join(hooksDir, hook), block); err != nil {
+			return err
+		}
+		fmt.Printf("Installed %s\n", filepath.Join(hooksDir, hook))
+	}
+	return nil
+}
+
+func runHooksUninstall(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+	hooksDir, err := internal.GitHooksDir(workDir)
+	if err != nil {
+		return fmt.Errorf("not a git repository: %w", err)
+	}
+
+	for _, hook := range gitHooks {
+		path := filepath.Join(hooksDir, hook)
+		removed, err := uninstallHook(path)
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Printf("Removed memo from %s\n", path)
+		}
+	}
+	return nil
+}
+
+// installHook adds block to the hook at path, creating the hook or replacing
+// a block installed before. Existing hooks must be shell scripts.
+func installHook(path, block string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return os.WriteFile(path, []byte("#!/bin/sh\n"+block), 0755)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read hook: %w", err)
+	}
+
+	content := string(data)
+	if rest, ok := removeHookBlock(content); ok {
+		content = rest
+	} else if firstLine, _, _ := strings.Cut(content, "\n"); !strings.HasPrefix(firstLine, "#!") || !strings.HasSuffix(strings.TrimSpace(firstLine), "sh") {
+		return fmt.Errorf("%s exists and is not a shell script; add this to it instead:\n%s", path, block)
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content+block), 0755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+	return os.Chmod(path, 0755)
+}
+
+// uninstallHook removes memo's block from the hook at path, and the hook
+// itself if nothing else is left. Reports whether there was a block.
+func uninstallHook(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read hook: %w", err)
+	}
+	rest, ok := removeHookBlock(string(data))
+	if !ok {
+		return false, nil
+	}
+	if strings.TrimSpace(rest) == "#!/bin/sh" {
+		return true, os.Remove(path)
+	}
+	return true, os.WriteFile(path, []byte(rest), 0755)
+}
+
+// removeHookBlock returns content without memo's block, if it has one
+func removeHookBlock(content string) (string, bool) {
+	start := strings.Index(content, hookBegin)
+	if start < 0 {
+		return content, false
+	}
+	end := strings.Index(content[start:], hookEnd)
+	if end < 0 {
+		return content, false
+	}
+	end += start + len(hookEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:start] + content[end:], true
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func runHooksRun(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+	memoDir := filepath.Join(workDir, ".memo")
+	if err := os.MkdirAll(memoDir, 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(memoDir, hooksLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	log.SetOutput(logFile)
+
+	files, ok := hookChanges(workDir, args[0], args[1:])
+	if !ok {
+		return nil
+	}
+	if err := analyzer.AppendInbox(memoDir, files); err != nil {
+		internal.LogError("%v", err)
+	}
+
+	// A running watcher (or scan) takes the files from the inbox
+	lockFile, err := analyzer.TryLock(memoDir)
+	if err != nil {
+		internal.LogInfo("%s: queued %d files for the running watcher", args[0], len(files))
+		return nil
+	}
+	analyzer.Unlock(lockFile)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	internal.LogInfo("%s: scanning %d changed files", args[0], len(files))
+	scan := exec.Command(exe, "scan", "--incremental", "-p", workDir, "-c", configFlag)
+	scan.Stdout, scan.Stderr = logFile, logFile
+	return scan.Run()
+}
+
+// hookChanges returns the files (relative to the work directory) a git hook
+// was run for, and false if the hook needs no analysis
+func hookChanges(workDir, hook string, args []string) ([]string, bool) {
+	var from, to string
+	switch hook {
+	case "post-commit":
+		from, to = "HEAD^", "HEAD"
+	case "post-merge":
+		from, to = "ORIG_HEAD", "HEAD"
+	case "post-checkout":
+		// <previous HEAD> <new HEAD> <1 for a branch checkout, 0 for files>
+		if len(args) < 3 || args[2] != "1" || args[0] == args[1] || strings.Trim(args[0], "0") == "" {
+			return nil, false
+		}
+		from, to = args[0], args[1]
+	default:
+		internal.LogError("Unknown hook: %s", hook)
+		return nil, false
+	}
+
+	// The first commit has no parent: leave it to the scan
+	fromCommit, err := internal.GitResolve(workDir, from)
+	if err != nil {
+		internal.LogDebug("%s: %v", hook, err)
+		return nil, true
+	}
+	changes, err := internal.GitChanges(workDir, fromCommit, to)
+	if err != nil {
+		internal.LogError("%s: %v", hook, err)
+		return nil, true
+	}
+	var files []string
+	for _, c := range changes {
+		files = append(files, c.Path)
+		if c.Status == 'R' {
+			files = append(files, c.From)
+		}
+	}
+	return files, true
+}
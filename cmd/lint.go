@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/spf13/cobra"
+)
+
+var lintFormat string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check .memo/index against the lint rules, for CI",
+	Long: `Checks .memo/index without calling the agent and exits non-zero if any rule
+reports an error. Rules: ` + strings.Join(analyzer.LintRules(), ", ") + `.
+Their severity (error, warning or off), the file size limit and the required
+modules are set in the lint section of the config file.
+
+Formats: text (default), json, and github for GitHub Actions annotations.`,
+	Args: cobra.NoArgs,
+	// The report is the output: a failing lint exits 1 without cobra's usage
+	// text or error line, and other errors are printed without usage
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := runLint(cmd, args); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "output format: text, json or github")
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	switch lintFormat {
+	case "text", "json", "github":
+	default:
+		return fmt.Errorf("unknown format: %q (available: text, json, github)", lintFormat)
+	}
+
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfigAndSetup(workDir)
+	if err != nil {
+		return err
+	}
+	files, err := loadIndexFiles(cfg, workDir)
+	if err != nil {
+		return err
+	}
+
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	if _, err := os.Stat(indexDir); os.IsNotExist(err) {
+		return fmt.Errorf("index directory not found: %s\nRun 'memo' or 'memo scan' first to initialize the index", indexDir)
+	}
+	opts := cfg.Lint.Options()
+	opts.IndexFiles = files
+	findings, err := analyzer.LintIndex(indexDir, workDir, opts)
+	if err != nil {
+		return err
+	}
+
+	numErrors, numWarnings := 0, 0
+	for _, f := range findings {
+		if f.Severity == analyzer.SeverityError {
+			numErrors++
+		} else {
+			numWarnings++
+		}
+	}
+
+	switch lintFormat {
+	case "json":
+		if findings == nil {
+			findings = []analyzer.LintFinding{}
+		}
+		data, err := json.MarshalIndent(map[string]any{"findings": findings, "errors": numErrors, "warnings": numWarnings}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "github":
+		relIndex, _ := filepath.Rel(workDir, indexDir)
+		for _, f := range findings {
+			file := filepath.ToSlash(filepath.Join(relIndex, f.File))
+			fmt.Printf("::%s file=%s,title=%s::%s\n", f.Severity, githubProperty(file), githubProperty("memo lint: "+f.Rule), githubData(f.Message))
+		}
+	default:
+		for _, f := range findings {
+			fmt.Printf("%s: %s: %s [%s]\n", f.File, f.Severity, f.Message, f.Rule)
+		}
+		if len(findings) == 0 {
+			fmt.Println("No problems found")
+		} else {
+			fmt.Printf("\n%d error(s), %d warning(s)\n", numErrors, numWarnings)
+		}
+	}
+
+	if numErrors > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// githubData escapes the message of a GitHub Actions workflow command
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes a property value of a GitHub Actions workflow command
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/spf13/cobra"
+)
+
+var logLimit int
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "List index snapshots, newest first",
+	Long:  `Lists the snapshots of .memo/index saved after each successful analysis run, newest first.`,
+	Args:  cobra.NoArgs,
+	RunE:  runLog,
+}
+
+func init() {
+	logCmd.Flags().IntVarP(&logLimit, "max-count", "n", 0, "show at most this many snapshots")
+	rootCmd.AddCommand(logCmd)
+}
+
+func runLog(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+
+	log, err := analyzer.OpenSnapshots(workDir, 0).List()
+	if err != nil {
+		return err
+	}
+	if len(log) == 0 {
+		fmt.Println("No snapshots yet")
+		return nil
+	}
+
+	shown := 0
+	for i := len(log) - 1; i >= 0; i-- {
+		if logLimit > 0 && shown == logLimit {
+			break
+		}
+		snap := log[i]
+		line := fmt.Sprintf("%s  %s  %d file(s)", snap.ID, snap.Time.Format("2006-01-02 15:04:05"), len(snap.Files))
+		if snap.Branch != "" {
+			line += "  [" + snap.Branch + "]"
+		}
+		if snap.Note != "" {
+			line += "  " + snap.Note
+		}
+		fmt.Println(line)
+		shown++
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ var mcpCmd = &cobra.Command{
 }
 
 func init() {
+	mcpCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
 	rootCmd.AddCommand(mcpCmd)
 }
 
@@ -32,5 +33,15 @@ func runMcp(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("index directory not found: %s\nRun 'memo' or 'memo scan' first to initialize the index", indexDir)
 	}
 
-	return mcp.Serve(workDir)
+	// Project-defined index files are queryable too
+	cfg, err := loadConfigAndSetup(workDir)
+	if err != nil {
+		return err
+	}
+	files, err := loadIndexFiles(cfg, workDir)
+	if err != nil {
+		return err
+	}
+
+	return mcp.Serve(workDir, mcpFiles(files))
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/YoungY620/memo/internal"
+	"github.com/spf13/cobra"
+)
+
+var migrateDryRun bool
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade .memo/index to the current index format",
+	Long: `Applies the migrations needed to bring .memo/index from the version recorded
+in .memo/index/meta.json to the format of this memo, and records the result
+as a snapshot. Watch and scan do this automatically on startup; with
+--dry-run the migrations and the resulting diff are only printed.`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "show what would change without modifying the index")
+	migrateCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfigAndSetup(workDir)
+	if err != nil {
+		return err
+	}
+	files, err := loadIndexFiles(cfg, workDir)
+	if err != nil {
+		return err
+	}
+
+	memoDir := filepath.Join(workDir, ".memo")
+	indexDir := filepath.Join(memoDir, "index")
+	if _, err := os.Stat(indexDir); os.IsNotExist(err) {
+		return fmt.Errorf("index directory not found: %s\nRun 'memo' or 'memo scan' first to initialize the index", indexDir)
+	}
+	if !migrateDryRun {
+		lockFile, err := analyzer.TryLock(memoDir)
+		if err != nil {
+			return err
+		}
+		defer analyzer.Unlock(lockFile)
+	}
+
+	result, err := analyzer.MigrateIndex(indexDir, files, migrateDryRun)
+	if err != nil {
+		return err
+	}
+	if result.From == result.To {
+		fmt.Printf("Index is up to date (version %d)\n", result.To)
+		return nil
+	}
+
+	verb := "Migrated"
+	if migrateDryRun {
+		verb = "Would migrate"
+	}
+	fmt.Printf("%s index from version %d to %d:\n", verb, result.From, result.To)
+	for _, m := range result.Applied {
+		fmt.Printf("  %d: %s\n", m.To, m.Description)
+	}
+	if result.Diff != "" {
+		fmt.Printf("\n%s", result.Diff)
+	}
+	if migrateDryRun {
+		return nil
+	}
+
+	store := analyzer.OpenSnapshots(workDir, cfg.Snapshots.Retention)
+	if _, err := store.Save(indexDir, nil, fmt.Sprintf("migrate to version %d", result.To)); err != nil {
+		internal.LogError("Failed to save snapshot: %v", err)
+	}
+	return nil
+}
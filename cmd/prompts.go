@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/spf13/cobra"
+)
+
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "Inspect the prompt templates",
+}
+
+var promptsDumpCmd = &cobra.Command{
+	Use:   "dump [name...]",
+	Short: "Print the effective prompt templates",
+	Long: `Prints the prompt templates the analyser uses, unrendered, each with its
+source: built-in, or the override file from .memo/prompts (or prompts.dir).
+Names: ` + strings.Join(analyzer.PromptNames, ", ") + `. Prints all prompts by default.`,
+	RunE: runPromptsDump,
+}
+
+func init() {
+	promptsDumpCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	promptsCmd.AddCommand(promptsDumpCmd)
+	rootCmd.AddCommand(promptsCmd)
+}
+
+func runPromptsDump(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfigAndSetup(workDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		if !slices.Contains(analyzer.PromptNames, name) {
+			return fmt.Errorf("unknown prompt: %q (available: %s)", name, strings.Join(analyzer.PromptNames, ", "))
+		}
+	}
+
+	prompts, err := analyzer.LoadPrompts(cfg.PromptsDir(workDir))
+	if err != nil {
+		return err
+	}
+	for _, p := range prompts {
+		if len(args) > 0 && !slices.Contains(args, p.Name) {
+			continue
+		}
+		source := p.Source
+		if source == "" {
+			source = "built-in"
+		}
+		fmt.Printf("==> %s (%s) <==\n%s\n\n", p.Name, source, strings.TrimRight(p.Text, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/YoungY620/memo/internal"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <dir>",
+	Short: "Replay a recorded analysis session without calling the model",
+	Long: `Re-drives the validation loop from a recording made with --record.
+The recorded agent turns are applied to a scratch copy of the starting index
+(<dir>/replay), and the result is compared with the recorded final index.
+<dir> may be a path or the name of a directory in .memo/recordings.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	if logLevel != "" {
+		internal.SetLogLevel(logLevel)
+	}
+
+	dir, err := resolveRecordingDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := analyzer.Replay(context.Background(), dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Recording:  %s\n", dir)
+	fmt.Printf("Recorded:   %s, %d batch(es), %d turn(s)\n", result.Meta.StartedAt.Format("2006-01-02 15:04:05"), len(result.Meta.Batches), result.Meta.Turns)
+	if result.Meta.Error != "" {
+		fmt.Printf("Original:   failed: %s\n", result.Meta.Error)
+	} else {
+		fmt.Printf("Original:   succeeded\n")
+	}
+	if result.Err != nil {
+		fmt.Printf("Replay:     failed: %v\n", result.Err)
+	} else {
+		fmt.Printf("Replay:     succeeded\n")
+	}
+	fmt.Printf("Index:      %s\n", result.IndexDir)
+
+	if result.Diff != "" {
+		fmt.Printf("\nReplayed index differs from the recording:\n%s", result.Diff)
+		return fmt.Errorf("replay diverged from recording")
+	}
+	fmt.Println("Replayed index matches the recording")
+	return nil
+}
+
+// resolveRecordingDir accepts a path or a recording name under .memo/recordings
+func resolveRecordingDir(arg string) (string, error) {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		return filepath.Abs(arg)
+	}
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(workDir, ".memo", "recordings", arg)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, nil
+	}
+	return "", fmt.Errorf("recording not found: %s", arg)
+}
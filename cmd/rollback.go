@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/YoungY620/memo/internal"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <id>",
+	Short: "Restore .memo/index from a snapshot",
+	Long: `Restores .memo/index to the given snapshot and records the rollback as a new
+snapshot. Files analysed after the snapshot are forgotten by the manifest, so
+the next scan analyses them again. With per-branch indexes, only snapshots of
+the branch .memo/index belongs to can be restored. Cannot run while a watcher
+is active.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRollback,
+}
+
+func init() {
+	rollbackCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfigAndSetup(workDir)
+	if err != nil {
+		return err
+	}
+
+	memoDir := filepath.Join(workDir, ".memo")
+	lockFile, err := analyzer.TryLock(memoDir)
+	if err != nil {
+		return err
+	}
+	defer analyzer.Unlock(lockFile)
+
+	store := analyzer.OpenSnapshots(workDir, cfg.Snapshots.Retention)
+	snap, _, err := store.Find(args[0])
+	if err != nil {
+		return err
+	}
+	branch := analyzer.ActiveBranch(memoDir)
+	if snap.Branch != "" && snap.Branch != branch {
+		return fmt.Errorf("snapshot %s is of branch %s, but the index is of branch %s", snap.ID, snap.Branch, branch)
+	}
+	log, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	indexDir := filepath.Join(memoDir, "index")
+	if err := store.Restore(snap.ID, indexDir); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", snap.ID, err)
+	}
+
+	// Files analysed after the snapshot, on this branch, are no longer
+	// reflected in the index
+	var later []string
+	for i := slices.IndexFunc(log, func(s analyzer.Snapshot) bool { return s.ID == snap.ID }) + 1; i < len(log); i++ {
+		if log[i].Branch == "" || log[i].Branch == branch {
+			later = append(later, log[i].Files...)
+		}
+	}
+	manifest := loadManifest(workDir)
+	manifest.Forget(later)
+	if err := manifest.Save(); err != nil {
+		internal.LogError("Failed to save manifest: %v", err)
+	}
+
+	if _, err := store.Save(indexDir, nil, "rollback to "+snap.ID); err != nil {
+		internal.LogError("Failed to record rollback snapshot: %v", err)
+	}
+	fmt.Printf("Index restored to snapshot %s (%s)\n", snap.ID, snap.Time.Format("2006-01-02 15:04:05"))
+	return nil
+}
@@ -16,6 +16,9 @@ var (
 	pathFlag   string
 	logLevel   string
 	configFlag string
+	recordFlag bool
+	fullScan   bool
+	restart    bool
 )
 
 var rootCmd = &cobra.Command{
@@ -24,9 +27,17 @@ var rootCmd = &cobra.Command{
 	Long: `Memo maintains AI-readable documentation (.memo/index) for your codebase.
 
 Commands:
-  watch   Watch mode - monitors file changes and updates index continuously (default)
-  scan    Scan mode  - analyzes all files once, updates index, then exits
-  mcp     Query mode - starts MCP server for AI agents to query the index`,
+  watch    Watch mode - monitors file changes and updates index continuously (default)
+  scan     Scan mode  - analyzes all files once, updates index, then exits
+  mcp      Query mode - starts MCP server for AI agents to query the index
+  replay   Replay a recorded analysis session without calling the model
+  log      List index snapshots; show and rollback inspect or restore one
+  usage    Show token usage by day, model and trigger
+  prompts  Print the effective prompt templates (prompts dump)
+  migrate  Upgrade .memo/index to the current index format
+  hooks    Install git hooks that update the index on commit
+  lint     Check .memo/index against the lint rules, for CI
+  coverage Report which source files the index describes`,
 }
 
 func init() {
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/YoungY620/memo/analyzer"
 	"github.com/YoungY620/memo/internal"
@@ -12,12 +14,30 @@ import (
 var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Scan mode - analyzes all files once, updates index, then exits",
-	Long:  `Analyzes all files in the codebase once, updates .memo/index, then exits. Useful for CI or initial setup.`,
-	RunE:  runScan,
+	Long: `Analyzes all files in the codebase once, updates .memo/index, then exits. Useful for CI or initial setup.
+
+By default only files changed since the last run are analysed. In a git
+repository, --since, --rev-range and --incremental take the changed files from
+git instead, including deletions and renames. The commit analysed up to is
+recorded in .memo/revision.json after every successful scan.`,
+	RunE: runScan,
 }
 
+var (
+	scanSince       string
+	scanRevRange    string
+	scanIncremental bool
+)
+
 func init() {
 	scanCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
+	scanCmd.Flags().BoolVar(&recordFlag, "record", false, "record prompts, agent messages and index diffs to .memo/recordings")
+	scanCmd.Flags().BoolVar(&fullScan, "full", false, "analyse all files, not just those changed since the last run")
+	scanCmd.Flags().BoolVar(&restart, "restart", false, "discard the batch plan of an interrupted run instead of resuming it")
+	scanCmd.Flags().StringVar(&scanSince, "since", "", "analyse the files git reports changed since this revision")
+	scanCmd.Flags().StringVar(&scanRevRange, "rev-range", "", "analyse the files git reports changed in a range, e.g. main..HEAD")
+	scanCmd.Flags().BoolVar(&scanIncremental, "incremental", false, "analyse the files git reports changed since the last analysed commit")
+	scanCmd.MarkFlagsMutuallyExclusive("full", "since", "rev-range", "incremental")
 	rootCmd.AddCommand(scanCmd)
 }
 
@@ -31,10 +51,14 @@ func runScan(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	files, err := loadIndexFiles(cfg, workDir)
+	if err != nil {
+		return err
+	}
 
 	// Initialize .memo/index directory
 	indexDir := filepath.Join(workDir, ".memo", "index")
-	if err := initIndex(indexDir); err != nil {
+	if err := initIndex(indexDir, files); err != nil {
 		return err
 	}
 	internal.LogDebug("Initialized .memo/index directory: %s", indexDir)
@@ -47,6 +71,10 @@ func runScan(cmd *cobra.Command, args []string) error {
 	}
 	defer analyzer.Unlock(lockFile)
 
+	if err := migrateIndex(indexDir, files); err != nil {
+		return err
+	}
+
 	// Initialize history logger
 	internal.InitHistoryLogger(memoDir, "watcher")
 	defer internal.CloseHistoryLogger()
@@ -62,19 +90,26 @@ func runScan(cmd *cobra.Command, args []string) error {
 	}()
 
 	// Create analyser
-	agentCfg := analyzer.AgentConfig{
-		APIKey: cfg.Agent.APIKey,
-		Model:  cfg.Agent.Model,
+	ana, err := newAnalyser(cfg, workDir, files)
+	if err != nil {
+		return err
 	}
-	ana := analyzer.NewAnalyser(agentCfg, workDir)
+	ana.SetRecording(recordFlag)
+	manifest := loadManifest(workDir)
+	ana.SetManifest(manifest)
+	queue := loadQueue(workDir, restart)
+	ana.SetQueue(queue)
+	openBranches(cfg, workDir, manifest, queue)
 
 	// Create watcher (reuse for scanning logic)
+	failed := false
 	watcher, err := analyzer.NewWatcher(workDir, cfg.Watch.IgnorePatterns, cfg.Watch.DebounceMs, cfg.Watch.MaxWaitMs, func(files []string) {
 		internal.LogInfo("Triggered with %d changed files", len(files))
 		internal.LogDebug("Changed files: %v", files)
 		ctx := context.Background()
 		if err := ana.Analyse(ctx, files); err != nil {
 			internal.LogError("Analysis failed: %v", err)
+			failed = true
 		}
 	})
 	if err != nil {
@@ -105,13 +140,109 @@ func runScan(cmd *cobra.Command, args []string) error {
 		UpdateInfo: updateInfo,
 	})
 
-	// Scan all files
-	internal.LogInfo("Scanning all files, workDir=%s", workDir)
-	watcher.ScanAll()
+	// Finish an interrupted run before looking for new changes
+	if err := ana.Resume(context.Background()); err != nil {
+		internal.LogError("Analysis failed: %v", err)
+		failed = true
+	}
+
+	// Scan files changed since the last run, all with --full, or those git reports
+	head := "HEAD" // revision the index is up to date with after a successful scan
+	changes, err := gitScanChanges(workDir, &head)
+	if err != nil {
+		return err
+	}
+	switch {
+	case changes != nil:
+		internal.LogInfo("Scanning %d files changed in git, workDir=%s", len(changes), workDir)
+		var files []string
+		for _, c := range changes {
+			path := filepath.Join(workDir, c.Path)
+			files = append(files, path)
+			if c.Status == 'R' {
+				from := filepath.Join(workDir, c.From)
+				files = append(files, from)
+				ana.NoteRename(from, path)
+			}
+		}
+		watcher.ScanFiles(files)
+	case fullScan:
+		internal.LogInfo("Scanning all files, workDir=%s", workDir)
+		watcher.ScanAll()
+	default:
+		internal.LogInfo("Scanning changed files, workDir=%s", workDir)
+		watcher.ScanChanged(manifest)
+	}
+	watcher.ScanInbox(manifest)
 	internal.LogDebug("Scan completed")
 
 	// Flush and exit
 	watcher.Flush()
+	if !failed {
+		recordRevision(workDir, head)
+	}
 	internal.LogInfo("Scan mode completed")
 	return nil
 }
+
+// gitScanChanges returns the changes selected by --since, --rev-range or
+// --incremental, or nil to scan without git. head is set to the revision the
+// changes lead up to.
+func gitScanChanges(workDir string, head *string) ([]internal.GitChange, error) {
+	var from, to string
+	switch {
+	case scanSince != "":
+		from = scanSince
+	case scanRevRange != "":
+		parts := strings.Split(scanRevRange, "..")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.HasPrefix(parts[1], ".") {
+			return nil, fmt.Errorf("invalid revision range: %q (want <from>..<to>)", scanRevRange)
+		}
+		from, to = parts[0], parts[1]
+		*head = to
+	case scanIncremental:
+		rev, err := analyzer.LoadRevision(workDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read last analysed commit: %w", err)
+		}
+		if rev == nil {
+			internal.LogNotice("No analysed commit recorded yet, scanning files changed since the last run")
+			return nil, nil
+		}
+		from = rev.Commit
+	default:
+		return nil, nil
+	}
+
+	fromCommit, err := internal.GitResolve(workDir, from)
+	if err != nil {
+		return nil, err
+	}
+	if to != "" {
+		if to, err = internal.GitResolve(workDir, to); err != nil {
+			return nil, err
+		}
+		*head = to
+	}
+	changes, err := internal.GitChanges(workDir, fromCommit, to)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []internal.GitChange{}
+	}
+	return changes, nil
+}
+
+// recordRevision records the commit a successful scan brought the index up
+// to, if the project is a git repository
+func recordRevision(workDir, rev string) {
+	commit, err := internal.GitResolve(workDir, rev)
+	if err != nil {
+		internal.LogDebug("Not recording analysed commit: %v", err)
+		return
+	}
+	if err := analyzer.SaveRevision(workDir, commit); err != nil {
+		internal.LogError("Failed to record analysed commit: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show an index snapshot and its diff against the previous one",
+	Long: `Shows when a snapshot was taken, the files whose analysis produced it, and
+the unified diff of the index against the previous snapshot of the same
+branch. <id> may be any unique prefix of a snapshot ID listed by 'memo log'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShow,
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+
+	store := analyzer.OpenSnapshots(workDir, 0)
+	snap, prev, err := store.Find(args[0])
+	if err != nil {
+		return err
+	}
+	files, err := store.Files(snap.ID)
+	if err != nil {
+		return err
+	}
+	prevFiles := map[string]string{}
+	if prev != nil {
+		if prevFiles, err = store.Files(prev.ID); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("snapshot %s\n", snap.ID)
+	fmt.Printf("Date:  %s\n", snap.Time.Format("2006-01-02 15:04:05"))
+	if snap.Branch != "" {
+		fmt.Printf("Branch: %s\n", snap.Branch)
+	}
+	if snap.Note != "" {
+		fmt.Printf("Note:  %s\n", snap.Note)
+	}
+	if len(snap.Files) > 0 {
+		fmt.Printf("\nAnalysed files (%d):\n", len(snap.Files))
+		for _, f := range snap.Files {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+	if diff := analyzer.DiffIndexFiles(prevFiles, files); diff != "" {
+		fmt.Printf("\n%s", diff)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/spf13/cobra"
+)
+
+var usageDays int
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage by day, model and trigger",
+	Long: `Aggregates the token usage recorded in .memo/usage.jsonl by day, model and
+trigger: "initial" for the first prompt of each batch, "feedback" for the
+prompts sent after a failed validation.`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().IntVar(&usageDays, "days", 0, "only include the last N days")
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	workDir, err := resolveWorkDir()
+	if err != nil {
+		return err
+	}
+
+	records, err := analyzer.ReadUsage(workDir)
+	if err != nil {
+		return err
+	}
+	if usageDays > 0 {
+		y, m, d := time.Now().AddDate(0, 0, -(usageDays - 1)).Date()
+		since := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+		var recent []analyzer.UsageRecord
+		for _, rec := range records {
+			if !rec.Time.Before(since) {
+				recent = append(recent, rec)
+			}
+		}
+		records = recent
+	}
+
+	summary := analyzer.SummarizeUsage(records)
+	if len(summary) == 0 {
+		fmt.Println("No usage recorded yet")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DAY\tMODEL\tTRIGGER\tRUNS\tPROMPTS\tINPUT\tCACHED\tOUTPUT\tTOTAL\t")
+	var total analyzer.TokenUsage
+	for _, s := range summary {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+			s.Day, s.Model, s.Trigger, s.Runs, s.Prompts, s.Input, s.CacheRead+s.CacheCreation, s.Output, s.Total())
+		total.Add(s.TokenUsage)
+	}
+	fmt.Fprintf(tw, "total\t\t\t\t%d\t%d\t%d\t%d\t%d\t\n",
+		total.Prompts, total.Input, total.CacheRead+total.CacheCreation, total.Output, total.Total())
+	return tw.Flush()
+}
@@ -6,6 +6,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/YoungY620/memo/analyzer"
 	"github.com/YoungY620/memo/internal"
@@ -25,7 +26,10 @@ var watchCmd = &cobra.Command{
 
 func init() {
 	watchCmd.Flags().StringVarP(&configFlag, "config", "c", "config.yaml", "config file path")
-	watchCmd.Flags().BoolVar(&skipScan, "skip-scan", false, "skip initial full scan")
+	watchCmd.Flags().BoolVar(&recordFlag, "record", false, "record prompts, agent messages and index diffs to .memo/recordings")
+	watchCmd.Flags().BoolVar(&fullScan, "full", false, "analyse all files on startup, not just those changed since the last run")
+	watchCmd.Flags().BoolVar(&restart, "restart", false, "discard the batch plan of an interrupted run instead of resuming it")
+	watchCmd.Flags().BoolVar(&skipScan, "skip-scan", false, "skip initial scan")
 	rootCmd.AddCommand(watchCmd)
 
 	// Set watch as the default command when no subcommand is provided
@@ -44,10 +48,14 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	files, err := loadIndexFiles(cfg, workDir)
+	if err != nil {
+		return err
+	}
 
 	// Initialize .memo/index directory
 	indexDir := filepath.Join(workDir, ".memo", "index")
-	if err := initIndex(indexDir); err != nil {
+	if err := initIndex(indexDir, files); err != nil {
 		return err
 	}
 	internal.LogDebug("Initialized .memo/index directory: %s", indexDir)
@@ -60,6 +68,10 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	}
 	defer analyzer.Unlock(lockFile)
 
+	if err := migrateIndex(indexDir, files); err != nil {
+		return err
+	}
+
 	// Initialize history logger
 	internal.InitHistoryLogger(memoDir, "watcher")
 	defer internal.CloseHistoryLogger()
@@ -75,18 +87,36 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	}()
 
 	// Create analyser
-	agentCfg := analyzer.AgentConfig{
-		APIKey: cfg.Agent.APIKey,
-		Model:  cfg.Agent.Model,
+	ana, err := newAnalyser(cfg, workDir, files)
+	if err != nil {
+		return err
+	}
+	ana.SetRecording(recordFlag)
+	manifest := loadManifest(workDir)
+	ana.SetManifest(manifest)
+	queue := loadQueue(workDir, restart)
+	ana.SetQueue(queue)
+	branches := openBranches(cfg, workDir, manifest, queue)
+
+	// Periodic rebuilds in a fresh session, swapped in blue/green
+	var watcher *analyzer.Watcher
+	analyse, noteRename := ana.Analyse, ana.NoteRename
+	var rotator *analyzer.Rotator
+	if cfg.Rebuild.Enabled {
+		rotator = analyzer.NewRotator(ana,
+			func() (*analyzer.Analyser, error) { return newAnalyser(cfg, workDir, files) },
+			func() []string { return watcher.Files() },
+			time.Duration(cfg.Rebuild.IntervalMinutes)*time.Minute,
+			time.Duration(cfg.Rebuild.StabilizeMinutes)*time.Minute)
+		analyse, noteRename = rotator.Analyse, rotator.NoteRename
 	}
-	ana := analyzer.NewAnalyser(agentCfg, workDir)
 
 	// Create watcher
-	watcher, err := analyzer.NewWatcher(workDir, cfg.Watch.IgnorePatterns, cfg.Watch.DebounceMs, cfg.Watch.MaxWaitMs, func(files []string) {
+	watcher, err = analyzer.NewWatcher(workDir, cfg.Watch.IgnorePatterns, cfg.Watch.DebounceMs, cfg.Watch.MaxWaitMs, func(files []string) {
 		internal.LogInfo("Triggered with %d changed files", len(files))
 		internal.LogDebug("Changed files: %v", files)
 		ctx := context.Background()
-		if err := ana.Analyse(ctx, files); err != nil {
+		if err := analyse(ctx, files); err != nil {
 			internal.LogError("Analysis failed: %v", err)
 		}
 	})
@@ -94,6 +124,37 @@ func runWatch(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	defer watcher.Close()
+	watcher.SetOnRename(noteRename)
+
+	// When HEAD moves to another branch, swap in its index and analyse what
+	// differs from its last analysis instead of the files git rewrote
+	if branches != nil {
+		watcher.SetBeforeFlush(func(files []string) []string {
+			branch, err := branches.Sync(manifest, queue)
+			if err != nil {
+				internal.LogError("Failed to switch branch: %v", err)
+				return files
+			}
+			if branch == "" {
+				return files
+			}
+			if rotator != nil {
+				rotator.Abandon()
+			}
+			return manifest.Changed(watcher.Files())
+		})
+	}
+
+	// Spending limits
+	var throttle *analyzer.Throttle
+	if limits := cfg.Budget.Limits(); limits != nil {
+		budget, err := analyzer.NewBudget(*limits, ana)
+		if err != nil {
+			return err
+		}
+		watcher.SetBudget(budget)
+		throttle = budget.Exhausted()
+	}
 
 	// Start async update check
 	updateCh := internal.CheckUpdateAsync(Version)
@@ -116,20 +177,47 @@ func runWatch(cmd *cobra.Command, args []string) error {
 		WorkDir:    workDir,
 		Version:    Version,
 		UpdateInfo: updateInfo,
+		Throttle:   throttle,
 	})
 
-	// Initial scan (unless --skip-scan is set)
+	// Finish an interrupted run before looking for new changes
+	if err := ana.Resume(context.Background()); err != nil {
+		internal.LogError("Analysis failed: %v", err)
+	}
+
+	// Initial scan: files changed since the last run, or all with --full
 	internal.LogInfo("Watcher started, workDir=%s", workDir)
-	if !skipScan {
+	switch {
+	case skipScan:
+		internal.LogInfo("Skipping initial scan (--skip-scan)")
+	case fullScan:
 		watcher.ScanAll()
 		internal.LogDebug("Initial scan completed")
-	} else {
-		internal.LogInfo("Skipping initial scan (--skip-scan)")
+	default:
+		watcher.ScanChanged(manifest)
+		internal.LogDebug("Initial scan completed")
+	}
+
+	// Files queued by git hooks while no watcher was running, and from now on
+	watcher.ScanInbox(manifest)
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
+	go watcher.WatchInbox(pollCtx, manifest, analyzer.PollInterval)
+	if branches != nil {
+		internal.LogInfo("Tracking the index of branch %s", branches.Current())
+		go branches.Watch(pollCtx, analyzer.PollInterval, watcher.Flush)
 	}
 
 	// Watch mode
 	internal.LogInfo("Memo watching: %s", workDir)
 
+	if rotator != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		internal.LogInfo("Rebuilding the index every %d minutes", cfg.Rebuild.IntervalMinutes)
+		go rotator.Run(ctx)
+	}
+
 	// Handle shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
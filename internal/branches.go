@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// BranchesDirName is the directory under .memo keeping the index and state
+// files of each branch not checked out, in <branch>/
+const BranchesDirName = "branches"
+
+// BranchFileName records which branch .memo/index belongs to. In a branch's
+// directory under .memo/branches it marks the parked index of that branch.
+const BranchFileName = "branch.json"
+
+// BranchState is the content of a branch file
+type BranchState struct {
+	Branch string `json:"branch"`
+}
+
+// ActiveBranch returns the branch .memo/index belongs to; "" if branches are
+// not tracked
+func ActiveBranch(memoDir string) string {
+	return readBranchFile(filepath.Join(memoDir, BranchFileName))
+}
+
+// BranchDir returns the directory of a branch's parked index and state files
+func BranchDir(memoDir, branch string) string {
+	return filepath.Join(memoDir, BranchesDirName, filepath.FromSlash(branch))
+}
+
+// BranchIndexDir returns the parked index of a branch
+func BranchIndexDir(memoDir, branch string) string {
+	return filepath.Join(BranchDir(memoDir, branch), "index")
+}
+
+// IsParked reports whether a branch has a parked index
+func IsParked(memoDir, branch string) bool {
+	return branch != "" && readBranchFile(filepath.Join(BranchDir(memoDir, branch), BranchFileName)) == branch
+}
+
+// MarkParked writes the marker of a branch's parked index
+func MarkParked(memoDir, branch string) error {
+	data, err := json.Marshal(BranchState{Branch: branch})
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(BranchDir(memoDir, branch), BranchFileName), data, 0644)
+}
+
+// ParkedBranches returns the branches with a parked index, sorted
+func ParkedBranches(memoDir string) []string {
+	root := filepath.Join(memoDir, BranchesDirName)
+	var branches []string
+	_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != BranchFileName || filepath.Dir(p) == root {
+			return nil
+		}
+		if branch := readBranchFile(p); branch != "" && filepath.Dir(p) == BranchDir(memoDir, branch) {
+			branches = append(branches, branch)
+		}
+		return nil
+	})
+	sort.Strings(branches)
+	return branches
+}
+
+// readBranchFile returns the branch recorded in a branch file; "" if it is
+// missing or invalid
+func readBranchFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var s BranchState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return ""
+	}
+	return s.Branch
+}
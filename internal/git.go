@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitChange is a file changed between two revisions, relative to the
+// directory git was run in
+type GitChange struct {
+	Status byte   // A added, M modified, D deleted, R renamed (C copies and T type changes are reported as A and M)
+	Path   string // current path; the deleted path for D
+	From   string // previous path of a rename
+}
+
+// git runs a git command in dir and returns its stdout
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// GitResolve returns the commit hash of a revision
+func GitResolve(dir, rev string) (string, error) {
+	out, err := git(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision: %s", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// GitChanges lists the files under dir changed from one revision to another.
+// An empty to compares against the working tree (tracked files only).
+func GitChanges(dir, from, to string) ([]GitChange, error) {
+	args := []string{"diff", "--name-status", "-z", "-M", "--relative", from}
+	if to != "" {
+		args = append(args, to)
+	}
+	args = append(args, "--")
+	out, err := git(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []GitChange
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(fields) && fields[i] != ""; {
+		status := fields[i][0]
+		switch status {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("git diff: truncated output")
+			}
+			c := GitChange{Status: 'A', Path: fields[i+2]}
+			if status == 'R' {
+				c.Status, c.From = 'R', fields[i+1]
+			}
+			changes = append(changes, c)
+			i += 3
+		default:
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("git diff: truncated output")
+			}
+			if status != 'A' && status != 'D' {
+				status = 'M'
+			}
+			changes = append(changes, GitChange{Status: status, Path: fields[i+1]})
+			i += 2
+		}
+	}
+	return changes, nil
+}
+
+// GitHooksDir returns the hooks directory of the repository containing dir,
+// honouring core.hooksPath
+func GitHooksDir(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	hooks := strings.TrimSpace(out)
+	if !filepath.IsAbs(hooks) {
+		hooks = filepath.Join(dir, hooks)
+	}
+	return hooks, nil
+}
+
+// GitBranch returns the checked-out branch of the repository containing dir,
+// or "" for a detached HEAD
+func GitBranch(dir string) (string, error) {
+	out, err := git(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err == nil {
+		return strings.TrimSpace(out), nil
+	}
+	if _, err := git(dir, "rev-parse", "--git-dir"); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// GitMergeBase returns the best common ancestor of two revisions
+func GitMergeBase(dir, a, b string) (string, error) {
+	out, err := git(dir, "merge-base", a, b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// GitCountCommits returns the number of commits reachable from to but not from
+func GitCountCommits(dir, from, to string) (int, error) {
+	out, err := git(dir, "rev-list", "--count", from+".."+to)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(out), "%d", &n); err != nil {
+		return 0, fmt.Errorf("git rev-list: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// provenanceFile is the sidecar next to an index that records, per entry,
+// the source files it was derived from and their content hashes
+const provenanceFile = "provenance.json"
+
+type provenanceEntry struct {
+	File    string            `json:"file"`
+	Field   string            `json:"field"`
+	Key     map[string]string `json:"key"`
+	Sources []struct {
+		Path   string `json:"path"`
+		SHA256 string `json:"sha256"`
+	} `json:"sources"`
+}
+
+// ChangedSources returns the source files that changed since the entries at
+// path in one of the built-in files were written, see Files.ChangedSources
+func ChangedSources(indexDir, workDir, path string) ([]string, error) {
+	return builtin.ChangedSources(indexDir, workDir, path)
+}
+
+// ChangedSources returns the source files that changed since the index
+// entries at path were written, for paths to an entry (or a value in it),
+// an array of entries or a whole file. Entries without provenance are
+// assumed fresh.
+func (f *Files) ChangedSources(indexDir, workDir, path string) ([]string, error) {
+	file, segments, err := f.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(indexDir), provenanceFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var prov struct {
+		Entries []provenanceEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &prov); err != nil {
+		return nil, err
+	}
+
+	// The entry a path to or into an array element refers to
+	var entry map[string]any
+	if len(segments) >= 2 && !segments[0].IsIndex && segments[1].IsIndex {
+		index, err := loadFile(indexDir, file)
+		if err != nil {
+			return nil, err
+		}
+		value, err := traverse(index, segments[:2])
+		if err != nil {
+			return nil, err
+		}
+		if entry, _ = value.(map[string]any); entry == nil {
+			return nil, nil
+		}
+	}
+
+	changed := make(map[string]bool)
+	hashes := make(map[string]string)
+	for _, e := range prov.Entries {
+		if e.File != file+".json" || len(segments) > 0 && e.Field != segments[0].Key || entry != nil && !keyMatches(entry, e.Key) {
+			continue
+		}
+		for _, src := range e.Sources {
+			sum, ok := hashes[src.Path]
+			if !ok {
+				sum = hashSource(filepath.Join(workDir, filepath.FromSlash(src.Path)))
+				hashes[src.Path] = sum
+			}
+			if sum != src.SHA256 {
+				changed[src.Path] = true
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(changed))
+	for p := range changed {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// keyMatches reports whether entry has the key field values of a provenance entry
+func keyMatches(entry map[string]any, key map[string]string) bool {
+	for k, v := range key {
+		if s, _ := entry[k].(string); s != v {
+			return false
+		}
+	}
+	return len(key) > 0
+}
+
+// hashSource returns the SHA-256 of a file, or "" if it does not exist
+func hashSource(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -18,27 +18,85 @@ type PathSegment struct {
 
 // ListKeysResult is the result of list_keys operation
 type ListKeysResult struct {
-	Type   string   `json:"type"`            // "dict" or "list"
-	Keys   []string `json:"keys,omitempty"`  // for dict
-	Length int      `json:"length,omitempty"` // for list
+	Type           string   `json:"type"`                      // "dict" or "list"
+	Keys           []string `json:"keys,omitempty"`            // for dict
+	Length         int      `json:"length,omitempty"`          // for list
+	Stale          bool     `json:"stale,omitempty"`           // see ChangedSources
+	ChangedSources []string `json:"changed_sources,omitempty"` // source files changed since the entries were written
 }
 
 // GetValueResult is the result of get_value operation
 type GetValueResult struct {
-	Value string `json:"value"`
+	Value          string   `json:"value"`
+	Stale          bool     `json:"stale,omitempty"`           // see ChangedSources
+	ChangedSources []string `json:"changed_sources,omitempty"` // source files changed since the entries were written
 }
 
-// Allowed index files
-var allowedFiles = map[string]bool{
-	"arch":      true,
-	"interface": true,
-	"stories":   true,
-	"issues":    true,
+// builtinFiles are the index files every index has
+var builtinFiles = []string{"arch", "interface", "stories", "issues"}
+
+// File is a project-defined index file, <Name>.json; Schema is shown to
+// agents in the tool descriptions
+type File struct {
+	Name   string
+	Schema string
 }
 
-// ParsePath parses a path like [arch][modules][0][name] into file and segments
-// Uses a state machine to handle escaping
+// Files are the queryable index files: the built-in ones and the
+// project-defined ones
+type Files struct {
+	custom  []File
+	allowed map[string]bool
+}
+
+// builtin has the built-in files only; the package-level queries use it
+var builtin = NewFiles(nil)
+
+// NewFiles returns the built-in files plus the project-defined ones, in order
+func NewFiles(custom []File) *Files {
+	f := &Files{allowed: make(map[string]bool)}
+	for _, name := range builtinFiles {
+		f.allowed[name] = true
+	}
+	for _, c := range custom {
+		if !f.allowed[c.Name] {
+			f.custom = append(f.custom, c)
+			f.allowed[c.Name] = true
+		}
+	}
+	return f
+}
+
+// names lists the queryable files, built-in ones first
+func (f *Files) names() string {
+	names := append([]string(nil), builtinFiles...)
+	for _, c := range f.custom {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParsePath parses a path into one of the built-in files, see Files.ParsePath
 func ParsePath(path string) (file string, segments []PathSegment, err error) {
+	return builtin.ParsePath(path)
+}
+
+// ParsePath parses a path like [arch][modules][0][name] into file and
+// segments; the file must be one of f
+func (f *Files) ParsePath(path string) (file string, segments []PathSegment, err error) {
+	file, segments, err = parsePath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !f.allowed[file] {
+		return "", nil, fmt.Errorf("invalid file: %s (allowed: %s)", file, f.names())
+	}
+	return file, segments, nil
+}
+
+// parsePath parses a path like [arch][modules][0][name] into file and segments
+// Uses a state machine to handle escaping
+func parsePath(path string) (file string, segments []PathSegment, err error) {
 	if len(path) == 0 {
 		return "", nil, fmt.Errorf("empty path")
 	}
@@ -115,12 +173,7 @@ func ParsePath(path string) (file string, segments []PathSegment, err error) {
 	if result[0].IsIndex {
 		return "", nil, fmt.Errorf("first segment must be file name, not index")
 	}
-	file = result[0].Key
-	if !allowedFiles[file] {
-		return "", nil, fmt.Errorf("invalid file: %s (allowed: arch, interface, stories, issues)", file)
-	}
-
-	return file, result[1:], nil
+	return result[0].Key, result[1:], nil
 }
 
 // validateKey checks for forbidden characters in keys
@@ -182,9 +235,15 @@ func loadFile(indexDir, file string) (any, error) {
 	return result, nil
 }
 
-// ListKeys returns keys/length for the value at the given path
+// ListKeys returns keys/length for the value at the given path in one of the
+// built-in files
 func ListKeys(indexDir, path string) (*ListKeysResult, error) {
-	file, segments, err := ParsePath(path)
+	return builtin.ListKeys(indexDir, path)
+}
+
+// ListKeys returns keys/length for the value at the given path
+func (f *Files) ListKeys(indexDir, path string) (*ListKeysResult, error) {
+	file, segments, err := f.ParsePath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -213,9 +272,15 @@ func ListKeys(indexDir, path string) (*ListKeysResult, error) {
 	}
 }
 
-// GetValue returns the JSON string of the value at the given path
+// GetValue returns the JSON string of the value at the given path in one of
+// the built-in files
 func GetValue(indexDir, path string) (*GetValueResult, error) {
-	file, segments, err := ParsePath(path)
+	return builtin.GetValue(indexDir, path)
+}
+
+// GetValue returns the JSON string of the value at the given path
+func (f *Files) GetValue(indexDir, path string) (*GetValueResult, error) {
+	file, segments, err := f.ParsePath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -84,8 +85,12 @@ type ToolCallResult struct {
 
 // Status represents the analysis status from status.json
 type Status struct {
-	Status string     `json:"status"`
-	Since  *time.Time `json:"since,omitempty"`
+	Status   string     `json:"status"`
+	Since    *time.Time `json:"since,omitempty"`
+	Throttle *struct {
+		Reason   string `json:"reason"`
+		Deferred int    `json:"deferred"`
+	} `json:"throttle,omitempty"`
 }
 
 type ContentItem struct {
@@ -97,22 +102,30 @@ type ContentItem struct {
 type Server struct {
 	indexDir string
 	memoDir  string
+	workDir  string
+	files    *Files
 	reader   *bufio.Reader
 	writer   io.Writer
 	history  *internal.HistoryLogger
 }
 
-// NewServer creates a new MCP server
-func NewServer(workDir string) *Server {
+// NewServer creates a new MCP server answering queries into files, or into
+// the built-in files if nil
+func NewServer(workDir string, files *Files) *Server {
 	memoDir := filepath.Join(workDir, ".memo")
 	// Ensure .memo directory exists
 	_ = os.MkdirAll(memoDir, 0755)
 
 	h, _ := internal.NewHistoryLogger(memoDir, "mcp") // ignore error, logging is optional
+	if files == nil {
+		files = builtin
+	}
 
 	return &Server{
 		indexDir: filepath.Join(memoDir, "index"),
 		memoDir:  memoDir,
+		workDir:  workDir,
+		files:    files,
 		reader:   bufio.NewReader(os.Stdin),
 		writer:   os.Stdout,
 		history:  h,
@@ -168,6 +181,35 @@ func (s *Server) getStatus() Status {
 	return status
 }
 
+// currentIndex returns the index of the checked-out branch. With per-branch
+// indexes (.memo/branch.json), .memo/index belongs to one branch and the
+// others are kept in .memo/branches/<name>/index; a branch without an index
+// yet is answered from .memo/index, with a warning.
+func (s *Server) currentIndex() (string, string) {
+	active := internal.ActiveBranch(s.memoDir)
+	if active == "" {
+		return s.indexDir, ""
+	}
+	branch, err := internal.GitBranch(s.workDir)
+	if err != nil || branch == "" || branch == active {
+		return s.indexDir, ""
+	}
+	if internal.IsParked(s.memoDir, branch) {
+		return internal.BranchIndexDir(s.memoDir, branch), ""
+	}
+	return s.indexDir, fmt.Sprintf("Data may be stale: no index for branch %s yet, showing branch %s", branch, active)
+}
+
+// changedSources returns the source files changed since the entries at path
+// were written; staleness is best effort and never fails a query
+func (s *Server) changedSources(indexDir, path string) []string {
+	changed, err := s.files.ChangedSources(indexDir, s.workDir, path)
+	if err != nil && s.history != nil {
+		s.history.LogError("provenance", err)
+	}
+	return changed
+}
+
 // tool descriptions with schema
 const schemaDesc = `Schema:
 - [arch]: {modules: [{name, description, interfaces, internal?}], relationships}
@@ -175,6 +217,17 @@ const schemaDesc = `Schema:
 - [stories]: {stories: [{title, tags, content}]}
 - [issues]: {issues: [{tags, title, description, locations: [{file, keyword, line}]}]}`
 
+const staleDesc = `stale is set when source files the entries were derived from changed since they were written (listed in changed_sources); verify such entries against the code.`
+
+// compactSchema returns a JSON Schema on one line, for tool descriptions
+func compactSchema(schema string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(schema)); err != nil {
+		return schema
+	}
+	return buf.String()
+}
+
 const whenToUse = `**IMPORTANT: Always check project state via memo BEFORE doing anything.**
 
 No matter what task you are working on - coding, debugging, refactoring, or answering questions - you MUST first use memo to understand the current project state. This ensures you have accurate, up-to-date context before making any changes or decisions.
@@ -192,10 +245,14 @@ No matter what task you are working on - coding, debugging, refactoring, or answ
 4. Accurate: Includes relationships, design decisions, and known issues`
 
 func (s *Server) tools() []Tool {
+	schemaDesc := schemaDesc
+	for _, f := range s.files.custom {
+		schemaDesc += fmt.Sprintf("\n- [%s]: %s", f.Name, compactSchema(f.Schema))
+	}
 	return []Tool{
 		{
 			Name:        "memo_list_keys",
-			Description: fmt.Sprintf("%s\n\n**Function:** List available keys at a path in .memo/index JSON files.\n\n%s\n\nReturns {type: 'dict'|'list', keys?: [...], length?: N}", whenToUse, schemaDesc),
+			Description: fmt.Sprintf("%s\n\n**Function:** List available keys at a path in .memo/index JSON files.\n\n%s\n\nReturns {type: 'dict'|'list', keys?: [...], length?: N, stale?: true, changed_sources?: [...]}\n\n%s", whenToUse, schemaDesc, staleDesc),
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -206,7 +263,7 @@ func (s *Server) tools() []Tool {
 		},
 		{
 			Name:        "memo_get_value",
-			Description: fmt.Sprintf("%s\n\n**Function:** Get JSON value at a path in .memo/index files.\n\n%s\n\nReturns {value: '<JSON string>'}", whenToUse, schemaDesc),
+			Description: fmt.Sprintf("%s\n\n**Function:** Get JSON value at a path in .memo/index files.\n\n%s\n\nReturns {value: '<JSON string>', stale?: true, changed_sources?: [...]}\n\n%s", whenToUse, schemaDesc, staleDesc),
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -314,11 +371,22 @@ func (s *Server) handleToolCall(id any, params *ToolCallParams) *Response {
 	var result any
 	var err error
 
+	indexDir, branchWarning := s.currentIndex()
 	switch params.Name {
 	case "memo_list_keys":
-		result, err = ListKeys(s.indexDir, args.Path)
+		var keys *ListKeysResult
+		if keys, err = s.files.ListKeys(indexDir, args.Path); err == nil {
+			keys.ChangedSources = s.changedSources(indexDir, args.Path)
+			keys.Stale = len(keys.ChangedSources) > 0
+		}
+		result = keys
 	case "memo_get_value":
-		result, err = GetValue(s.indexDir, args.Path)
+		var value *GetValueResult
+		if value, err = s.files.GetValue(indexDir, args.Path); err == nil {
+			value.ChangedSources = s.changedSources(indexDir, args.Path)
+			value.Stale = len(value.ChangedSources) > 0
+		}
+		result = value
 	default:
 		return s.errorResponse(id, -32602, fmt.Sprintf("Unknown tool: %s", params.Name))
 	}
@@ -342,6 +410,11 @@ func (s *Server) handleToolCall(id any, params *ToolCallParams) *Response {
 		if status.Since != nil {
 			warning += fmt.Sprintf(" (started %s ago)", time.Since(*status.Since).Round(time.Second))
 		}
+	} else if status.Status == "throttled" && status.Throttle != nil {
+		warning = fmt.Sprintf("Data may be stale: %d changed files wait for the spending budget (%s)", status.Throttle.Deferred, status.Throttle.Reason)
+	}
+	if branchWarning != "" {
+		warning = branchWarning
 	}
 
 	resultJSON, _ := json.Marshal(result)
@@ -380,9 +453,10 @@ func (s *Server) Close() error {
 	return nil
 }
 
-// Serve starts an MCP server for the given work directory
-func Serve(workDir string) error {
-	server := NewServer(workDir)
+// Serve starts an MCP server for the given work directory, answering queries
+// into files
+func Serve(workDir string, files *Files) error {
+	server := NewServer(workDir, files)
 	defer server.Close()
 	return server.Run()
 }
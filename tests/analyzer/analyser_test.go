@@ -300,3 +300,49 @@ func BenchmarkGenerateSessionID(b *testing.B) {
 		analyzer.GenerateSessionID(workDir)
 	}
 }
+
+func TestSplitIntoBatchesByWeight(t *testing.T) {
+	weights := map[string]int{
+		"small/a.go": 10, "small/b.go": 10,
+		"mid/a.go": 40, "mid/b.go": 40,
+		"big/huge.go": 500,
+		"big/c.go":    30,
+		"top.go":      5,
+	}
+	files := make([]string, 0, len(weights))
+	for f := range weights {
+		files = append(files, f)
+	}
+	weight := func(f string) int { return weights[f] }
+
+	batches := analyzer.SplitIntoBatchesByWeight(files, 100, weight)
+
+	// big/ is over budget and split; huge.go gets a batch of its own;
+	// the remaining groups are packed in path order
+	expected := [][]string{
+		{"big/c.go"},
+		{"big/huge.go"},
+		{"mid/a.go", "mid/b.go", "small/a.go", "small/b.go"},
+		{"top.go"},
+	}
+	require.Len(t, batches, len(expected))
+	for i := range expected {
+		assert.ElementsMatch(t, expected[i], batches[i])
+	}
+
+	// Under budget: a single batch
+	assert.Len(t, analyzer.SplitIntoBatchesByWeight(files, 1000, weight), 1)
+}
+
+func TestKeepRenamesTogether(t *testing.T) {
+	batches := [][]string{{"a/old.go", "a/other.go"}, {"b/new.go"}, {"c/gone.go"}}
+	result := analyzer.KeepRenamesTogether(batches, map[string]string{
+		"b/new.go":    "a/old.go",
+		"a/other2.go": "c/gone.go",
+	})
+	assert.Equal(t, [][]string{{"a/other.go"}, {"b/new.go", "a/old.go"}, {"c/gone.go"}}, result)
+
+	// A batch left empty is dropped
+	result = analyzer.KeepRenamesTogether([][]string{{"old.go"}, {"new.go"}}, map[string]string{"new.go": "old.go"})
+	assert.Equal(t, [][]string{{"new.go", "old.go"}}, result)
+}
@@ -0,0 +1,152 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validArch = `{"modules": [{"name": "main", "description": "entry point", "interfaces": "none"}], "relationships": ""}`
+
+// writeScript creates a script directory with the given turn files
+func writeScript(t *testing.T, turns map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range turns {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func newScriptedAnalyser(t *testing.T, workDir, scriptDir string) *analyzer.Analyser {
+	t.Helper()
+	ana, err := analyzer.NewAnalyser(analyzer.AgentConfig{
+		Backend:   analyzer.BackendScripted,
+		ScriptDir: scriptDir,
+	}, workDir)
+	require.NoError(t, err)
+	return ana
+}
+
+func TestScriptedBackend_RequiresScriptDir(t *testing.T) {
+	_, err := analyzer.NewBackend(analyzer.AgentConfig{Backend: analyzer.BackendScripted})
+	assert.Error(t, err)
+
+	_, err = analyzer.NewBackend(analyzer.AgentConfig{Backend: analyzer.BackendScripted, ScriptDir: t.TempDir()})
+	assert.Error(t, err, "empty script dir should be rejected")
+}
+
+func TestScriptedBackend_WritesIndex(t *testing.T) {
+	workDir := setupWorkDir(t)
+	scriptDir := writeScript(t, map[string]string{
+		"001.json": `{"text": "updating arch", "files": {"arch.json": ` + validArch + `}}`,
+	})
+
+	ana := newScriptedAnalyser(t, workDir, scriptDir)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "index", "arch.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, validArch, string(data))
+}
+
+func TestScriptedBackend_FeedbackLoop(t *testing.T) {
+	workDir := setupWorkDir(t)
+	// First turn writes broken JSON (as a verbatim string), second turn fixes it
+	scriptDir := writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": "{broken"}}`,
+		"002.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	})
+
+	ana := newScriptedAnalyser(t, workDir, scriptDir)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	result := analyzer.ValidateIndex(filepath.Join(workDir, ".memo", "index"))
+	assert.True(t, result.Valid, "feedback turn should repair the index: %v", result.Errors)
+}
+
+func TestScriptedBackend_ExhaustedScriptFailsValidation(t *testing.T) {
+	workDir := setupWorkDir(t)
+	scriptDir := writeScript(t, map[string]string{
+		"001.json": `{"files": {"issues.json": "not json"}}`,
+	})
+
+	ana := newScriptedAnalyser(t, workDir, scriptDir)
+	err := ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validation failed")
+}
+
+func TestScriptedBackend_TurnError(t *testing.T) {
+	workDir := setupWorkDir(t)
+	scriptDir := writeScript(t, map[string]string{
+		"001.json": `{"error": "model overloaded"}`,
+	})
+
+	ana := newScriptedAnalyser(t, workDir, scriptDir)
+	err := ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model overloaded")
+}
+
+func TestAnalyse_FlagsLargeFiles(t *testing.T) {
+	workDir := setupWorkDir(t)
+	big := filepath.Join(workDir, "big.go")
+	require.NoError(t, os.WriteFile(big, make([]byte, 4000), 0644)) // ~1000 tokens
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	ana.SetTokenBudget(500)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{big, filepath.Join(workDir, "main.go")}))
+
+	turns, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "turns", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, turns)
+	data, err := os.ReadFile(turns[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "## Large Files")
+	assert.Contains(t, string(data), "- big.go (~")
+	assert.NotContains(t, string(data), "- main.go (~")
+}
+
+func TestAnalyse_FailedBatchLeavesIndexUnchanged(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	before, err := os.ReadFile(filepath.Join(indexDir, "issues.json"))
+	require.NoError(t, err)
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"issues.json": "not json", "stories.json": null}}`,
+	}))
+	require.Error(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	after, err := os.ReadFile(filepath.Join(indexDir, "issues.json"))
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "broken JSON must not reach the index")
+	assert.FileExists(t, filepath.Join(indexDir, "stories.json"))
+	assert.True(t, analyzer.ValidateIndex(indexDir).Valid)
+	assert.NoDirExists(t, filepath.Join(workDir, ".memo", "staging"))
+}
+
+func TestAnalyse_CancelledBatchLeavesIndexUnchanged(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, ana.Analyse(ctx, []string{filepath.Join(workDir, "main.go")}))
+
+	data, err := os.ReadFile(filepath.Join(indexDir, "arch.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "entry point")
+}
@@ -0,0 +1,191 @@
+package analyzer_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubChatServer replays canned chat-completions replies and records requests
+type stubChatServer struct {
+	mu       sync.Mutex
+	replies  []string
+	requests []map[string]any
+	auth     []string
+}
+
+func (s *stubChatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	s.requests = append(s.requests, body)
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+
+	reply := `{"choices":[{"message":{"role":"assistant","content":"done"},"finish_reason":"stop"}]}`
+	if len(s.replies) > 0 {
+		reply = s.replies[0]
+		s.replies = s.replies[1:]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(reply))
+}
+
+// toolCallReply builds a chat-completions reply calling write_file
+func toolCallReply(t *testing.T, path, content string) string {
+	t.Helper()
+	args, err := json.Marshal(map[string]string{"path": path, "content": content})
+	require.NoError(t, err)
+	reply := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []any{map[string]any{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]any{"name": "write_file", "arguments": string(args)},
+				}},
+			},
+			"finish_reason": "tool_calls",
+		}},
+		"usage": map[string]any{"prompt_tokens": 100, "completion_tokens": 20},
+	}
+	data, err := json.Marshal(reply)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// setupWorkDir creates a project with a valid empty index
+func setupWorkDir(t *testing.T) string {
+	t.Helper()
+	workDir := t.TempDir()
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	require.NoError(t, os.MkdirAll(indexDir, 0755))
+	files := map[string]string{
+		"arch.json":      `{"modules": [], "relationships": ""}`,
+		"interface.json": `{"external": [], "internal": []}`,
+		"stories.json":   `{"stories": []}`,
+		"issues.json":    `{"issues": []}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(indexDir, name), []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n"), 0644))
+	return workDir
+}
+
+func TestNewBackend_Unknown(t *testing.T) {
+	_, err := analyzer.NewBackend(analyzer.AgentConfig{Backend: "nope"})
+	assert.Error(t, err)
+
+	_, err = analyzer.NewAnalyser(analyzer.AgentConfig{Backend: "nope"}, t.TempDir())
+	assert.Error(t, err, "NewAnalyser should reject unknown backends")
+}
+
+func TestNewBackend_Known(t *testing.T) {
+	for _, name := range []string{"", analyzer.BackendKimi, analyzer.BackendOpenAI} {
+		b, err := analyzer.NewBackend(analyzer.AgentConfig{Backend: name})
+		require.NoError(t, err, "backend %q", name)
+		assert.NotNil(t, b)
+	}
+}
+
+func TestOpenAIBackend_WritesIndex(t *testing.T) {
+	workDir := setupWorkDir(t)
+	arch := `{"modules": [{"name": "main", "description": "entry point", "interfaces": "none"}], "relationships": ""}`
+
+	// Batches edit a staging copy of the index, as told by the prompt
+	stub := &stubChatServer{replies: []string{toolCallReply(t, ".memo/staging/arch.json", arch)}}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	ana, err := analyzer.NewAnalyser(analyzer.AgentConfig{
+		Backend: analyzer.BackendOpenAI,
+		APIKey:  "test-key",
+		Model:   "test-model",
+		BaseURL: srv.URL,
+	}, workDir)
+	require.NoError(t, err)
+
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "index", "arch.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, arch, string(data))
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	require.Len(t, stub.requests, 2, "tool call round trip should take two requests")
+	assert.Equal(t, "test-model", stub.requests[0]["model"])
+	assert.Equal(t, "Bearer test-key", stub.auth[0])
+
+	// The initial prompt should mention the changed file
+	msgs := stub.requests[0]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	assert.Contains(t, last["content"], "main.go")
+
+	// The second request should carry the tool result
+	msgs = stub.requests[1]["messages"].([]any)
+	toolMsg := msgs[len(msgs)-1].(map[string]any)
+	assert.Equal(t, "tool", toolMsg["role"])
+	assert.Equal(t, "call_1", toolMsg["tool_call_id"])
+}
+
+func TestOpenAIBackend_WriteOutsideIndexDenied(t *testing.T) {
+	workDir := setupWorkDir(t)
+
+	stub := &stubChatServer{replies: []string{toolCallReply(t, "main.go", "overwritten")}}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	ana, err := analyzer.NewAnalyser(analyzer.AgentConfig{
+		Backend: analyzer.BackendOpenAI,
+		Model:   "test-model",
+		BaseURL: srv.URL,
+	}, workDir)
+	require.NoError(t, err)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	data, err := os.ReadFile(filepath.Join(workDir, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(data), "source files must not be writable")
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	msgs := stub.requests[1]["messages"].([]any)
+	toolMsg := msgs[len(msgs)-1].(map[string]any)
+	assert.True(t, strings.Contains(toolMsg["content"].(string), "write denied"))
+}
+
+func TestOpenAIBackend_APIError(t *testing.T) {
+	workDir := setupWorkDir(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": {"message": "invalid api key"}}`))
+	}))
+	defer srv.Close()
+
+	ana, err := analyzer.NewAnalyser(analyzer.AgentConfig{
+		Backend: analyzer.BackendOpenAI,
+		Model:   "test-model",
+		BaseURL: srv.URL,
+	}, workDir)
+	require.NoError(t, err)
+
+	err = ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid api key")
+}
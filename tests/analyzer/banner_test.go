@@ -57,6 +57,20 @@ func TestPrintBanner_LongPath(t *testing.T) {
 	assert.NotEmpty(t, output, "Expected non-empty banner output")
 }
 
+func TestPrintBanner_Throttled(t *testing.T) {
+	opts := analyzer.BannerOptions{
+		WorkDir:  "/test/path",
+		Version:  "1.0.0",
+		Throttle: &analyzer.Throttle{Reason: "day token budget exhausted"},
+	}
+
+	output := captureOutput(func() {
+		analyzer.PrintBanner(opts)
+	})
+
+	assert.Contains(t, output, "Throttled: day token budget exhausted")
+}
+
 func TestGetGreeting(t *testing.T) {
 	// Note: This test depends on current time, so we test the function exists and returns string
 	greeting := analyzer.GetGreeting()
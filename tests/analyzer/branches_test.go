@@ -0,0 +1,144 @@
+package analyzer_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGitWorkDir creates a work directory with an index, committed on main
+func setupGitWorkDir(t *testing.T) (string, func(args ...string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workDir := setupWorkDir(t)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = workDir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, output)
+	}
+	git("init", "-q")
+	git("checkout", "-q", "-b", "main")
+	git("add", "main.go")
+	git("commit", "-q", "-m", "initial")
+	return workDir, git
+}
+
+func TestBranches_SwitchAndRestore(t *testing.T) {
+	workDir, git := setupGitWorkDir(t)
+	memoDir := filepath.Join(workDir, ".memo")
+	writeArch(t, workDir, `"main"`)
+
+	branches, err := analyzer.OpenBranches(workDir)
+	require.NoError(t, err)
+	assert.Equal(t, "main", branches.Current())
+	assert.Equal(t, "main", analyzer.ActiveBranch(memoDir))
+	assert.False(t, branches.Moved())
+
+	// Same branch: nothing to do
+	branch, err := branches.Sync(nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, branch)
+
+	git("checkout", "-q", "-b", "feature")
+	assert.True(t, branches.Moved())
+	branch, err = branches.Sync(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "feature", branch)
+	assert.Contains(t, readArch(t, workDir), `"main"`, "a new branch starts from the index it forked from")
+	writeArch(t, workDir, `"feature"`)
+
+	git("checkout", "-q", "main")
+	_, err = branches.Sync(nil, nil)
+	require.NoError(t, err)
+	assert.Contains(t, readArch(t, workDir), `"main"`)
+	parked, err := os.ReadFile(filepath.Join(analyzer.BranchIndexDir(memoDir, "feature"), "arch.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `"feature"`, string(parked))
+}
+
+func TestBranches_SeedFromClosestBranch(t *testing.T) {
+	// A branch named like the index directory must not hide its parent
+	for _, release := range []string{"release", "team/index"} {
+		t.Run(release, func(t *testing.T) {
+			workDir, git := setupGitWorkDir(t)
+			writeArch(t, workDir, `"main"`)
+			branches, err := analyzer.OpenBranches(workDir)
+			require.NoError(t, err)
+
+			git("checkout", "-q", "-b", release)
+			_, err = branches.Sync(nil, nil)
+			require.NoError(t, err)
+			writeArch(t, workDir, `"release"`)
+			require.NoError(t, os.WriteFile(filepath.Join(workDir, "release.go"), []byte("package main\n"), 0644))
+			git("add", "release.go")
+			git("commit", "-q", "-m", "release")
+
+			git("checkout", "-q", "main")
+			_, err = branches.Sync(nil, nil)
+			require.NoError(t, err)
+
+			// hotfix forks from release, which is closer than the checked-out main
+			git("checkout", "-q", "-b", "hotfix", release)
+			_, err = branches.Sync(nil, nil)
+			require.NoError(t, err)
+			assert.Contains(t, readArch(t, workDir), `"release"`)
+		})
+	}
+}
+
+func TestBranches_ParksQueue(t *testing.T) {
+	workDir, git := setupGitWorkDir(t)
+	queue, err := analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	require.NoError(t, queue.Plan([][]string{{"main.go"}}))
+	branches, err := analyzer.OpenBranches(workDir)
+	require.NoError(t, err)
+
+	git("checkout", "-q", "-b", "feature")
+	_, err = branches.Sync(nil, queue)
+	require.NoError(t, err)
+	require.NoError(t, queue.Discard())
+
+	git("checkout", "-q", "main")
+	_, err = branches.Sync(nil, queue)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"main.go"}}, queue.Pending(), "main's plan comes back with its index")
+
+	git("checkout", "-q", "feature")
+	_, err = branches.Sync(nil, queue)
+	require.NoError(t, err)
+	assert.Empty(t, queue.Pending())
+}
+
+func TestBranches_KeepsObjectsOfParkedManifests(t *testing.T) {
+	workDir, git := setupGitWorkDir(t)
+	manifest, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	manifest.Update([]string{"main.go"})
+	require.NoError(t, manifest.Save())
+	branches, err := analyzer.OpenBranches(workDir)
+	require.NoError(t, err)
+
+	git("checkout", "-q", "-b", "feature")
+	_, err = branches.Sync(manifest, nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	manifest.Update([]string{"main.go"})
+	require.NoError(t, manifest.Save())
+
+	git("checkout", "-q", "main")
+	_, err = branches.Sync(manifest, nil)
+	require.NoError(t, err)
+	prev, ok := manifest.Previous("main.go")
+	require.True(t, ok, "the object main's manifest refers to is kept")
+	assert.Equal(t, "package main\n", prev)
+}
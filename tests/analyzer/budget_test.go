@@ -0,0 +1,169 @@
+package analyzer_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupBudgetWorkDir creates n files of about 120 estimated tokens each
+func setupBudgetWorkDir(t *testing.T, n int) (string, []string) {
+	t.Helper()
+	workDir := setupWorkDir(t)
+	var files []string
+	for i := 0; i < n; i++ {
+		path := filepath.Join(workDir, fmt.Sprintf("f%d.go", i))
+		require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", 400)), 0644))
+		files = append(files, path)
+	}
+	return workDir, files
+}
+
+// writeUsage records a run that used tokens in one prompt at the given time
+func writeUsage(t *testing.T, workDir string, at time.Time, tokens int) {
+	t.Helper()
+	line, err := json.Marshal(analyzer.UsageRecord{Time: at, Kind: analyzer.UsageRun, Initial: analyzer.TokenUsage{Prompts: 1, Input: tokens}})
+	require.NoError(t, err)
+	f, err := os.OpenFile(filepath.Join(workDir, ".memo", "usage.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	require.NoError(t, err)
+}
+
+func newTestBudget(t *testing.T, workDir string, limits analyzer.BudgetLimits) *analyzer.Budget {
+	t.Helper()
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{"001.json": `{}`}))
+	budget, err := analyzer.NewBudget(limits, ana)
+	require.NoError(t, err)
+	return budget
+}
+
+func TestBudget_WithinLimits(t *testing.T) {
+	workDir, files := setupBudgetWorkDir(t, 3)
+	budget := newTestBudget(t, workDir, analyzer.BudgetLimits{RunTokens: 1000, HourTokens: 1000})
+
+	now, throttle := budget.Check(files)
+	assert.Equal(t, files, now)
+	assert.Nil(t, throttle)
+}
+
+func TestBudget_RunBudgetReduces(t *testing.T) {
+	workDir, files := setupBudgetWorkDir(t, 3)
+	budget := newTestBudget(t, workDir, analyzer.BudgetLimits{RunTokens: 250})
+
+	now, throttle := budget.Check(files)
+	assert.Equal(t, files[:2], now)
+	require.NotNil(t, throttle)
+	assert.Equal(t, analyzer.BudgetReduced, throttle.Mode, "a run budget cannot be waited out")
+	assert.Equal(t, 1, throttle.Deferred)
+	assert.Contains(t, throttle.Reason, "run token budget")
+}
+
+func TestBudget_HourBudgetDefers(t *testing.T) {
+	workDir, files := setupBudgetWorkDir(t, 3)
+	used := time.Now().Add(-30 * time.Minute)
+	writeUsage(t, workDir, used, 900)
+	writeUsage(t, workDir, time.Now().Add(-2*time.Hour), 5000) // outside the window
+
+	budget := newTestBudget(t, workDir, analyzer.BudgetLimits{HourTokens: 1000})
+	now, throttle := budget.Check(files)
+	assert.Empty(t, now)
+	require.NotNil(t, throttle)
+	assert.Equal(t, analyzer.BudgetDefer, throttle.Mode)
+	assert.Equal(t, 3, throttle.Deferred)
+	require.NotNil(t, throttle.Until)
+	assert.WithinDuration(t, used.Add(time.Hour), *throttle.Until, time.Second)
+	assert.Nil(t, budget.Exhausted(), "100 tokens are still left")
+}
+
+func TestBudget_AboveWholeHourBudgetReduces(t *testing.T) {
+	workDir, files := setupBudgetWorkDir(t, 3)
+	budget := newTestBudget(t, workDir, analyzer.BudgetLimits{HourTokens: 250})
+
+	now, throttle := budget.Check(files)
+	assert.Equal(t, files[:2], now, "waiting would never make the change set fit")
+	require.NotNil(t, throttle)
+	assert.Equal(t, analyzer.BudgetReduced, throttle.Mode)
+	assert.Equal(t, 1, throttle.Deferred)
+
+	// With the budget partly used, the rest waits until what fits frees up
+	used := time.Now().Add(-30 * time.Minute)
+	writeUsage(t, workDir, used, 200)
+	now, throttle = budget.Check(files)
+	assert.Empty(t, now)
+	require.NotNil(t, throttle)
+	assert.Equal(t, analyzer.BudgetDefer, throttle.Mode)
+	require.NotNil(t, throttle.Until, "the watcher needs to know when to retry")
+	assert.WithinDuration(t, used.Add(time.Hour), *throttle.Until, time.Second)
+}
+
+func TestBudget_ReducedMode(t *testing.T) {
+	workDir, files := setupBudgetWorkDir(t, 3)
+	writeUsage(t, workDir, time.Now().Add(-time.Hour+time.Minute), 700)
+
+	budget := newTestBudget(t, workDir, analyzer.BudgetLimits{DayTokens: 1000, Mode: analyzer.BudgetReduced})
+	now, throttle := budget.Check(files)
+	assert.Equal(t, files[:2], now)
+	require.NotNil(t, throttle)
+	assert.Equal(t, analyzer.BudgetReduced, throttle.Mode)
+	assert.Contains(t, throttle.Reason, "day token budget")
+}
+
+func TestBudget_Exhausted(t *testing.T) {
+	workDir, _ := setupBudgetWorkDir(t, 0)
+	writeUsage(t, workDir, time.Now(), 10)
+
+	budget := newTestBudget(t, workDir, analyzer.BudgetLimits{DayRequests: 1})
+	throttle := budget.Exhausted()
+	require.NotNil(t, throttle)
+	assert.Contains(t, throttle.Reason, "day request budget")
+}
+
+func TestNewBudget_InvalidMode(t *testing.T) {
+	workDir := setupWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{"001.json": `{}`}))
+	_, err := analyzer.NewBudget(analyzer.BudgetLimits{Mode: "sometimes"}, ana)
+	assert.Error(t, err)
+}
+
+func TestWatcher_FlushThrottled(t *testing.T) {
+	workDir, _ := setupBudgetWorkDir(t, 3)
+
+	var mu sync.Mutex
+	var received []string
+	watcher, err := analyzer.NewWatcher(workDir, []string{".memo"}, 60000, 60000, func(files []string) {
+		mu.Lock()
+		received = append(received, files...)
+		mu.Unlock()
+	})
+	require.NoError(t, err)
+	defer watcher.Close()
+	watcher.SetBudget(newTestBudget(t, workDir, analyzer.BudgetLimits{RunTokens: 250}))
+
+	watcher.ScanAll()
+	watcher.Flush()
+
+	mu.Lock()
+	assert.Len(t, received, 2)
+	mu.Unlock()
+	status := analyzer.GetStatus(filepath.Join(workDir, ".memo"))
+	assert.Equal(t, "throttled", status.Status)
+	require.NotNil(t, status.Throttle)
+	assert.Equal(t, 2, status.Throttle.Deferred)
+
+	// The postponed files are analysed by the next flush
+	watcher.Flush()
+	mu.Lock()
+	assert.ElementsMatch(t, watcher.Files(), received)
+	mu.Unlock()
+}
@@ -0,0 +1,110 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// firstTurnPrompt returns the prompt of the first recorded turn
+func firstTurnPrompt(t *testing.T, workDir string) string {
+	t.Helper()
+	turns, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "turns", "001.json"))
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+	data, err := os.ReadFile(turns[0])
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestAnalyse_ReportsChangeKinds(t *testing.T) {
+	workDir := setupWorkDir(t)
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644))
+	}
+	write("gone.go", "package main\n\nfunc gone() {}\n")
+	write("old.go", "package main\n\nfunc moved() {}\n")
+	manifest, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	manifest.Update([]string{"main.go", "gone.go", "old.go"})
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("added.go", "package main\n")
+	require.NoError(t, os.Remove(filepath.Join(workDir, "gone.go")))
+	require.NoError(t, os.Rename(filepath.Join(workDir, "old.go"), filepath.Join(workDir, "new.go")))
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	ana.SetManifest(manifest)
+	ana.SetRecording(true)
+	var files []string
+	for _, f := range []string{"main.go", "added.go", "gone.go", "old.go", "new.go"} {
+		files = append(files, filepath.Join(workDir, f))
+	}
+	require.NoError(t, ana.Analyse(context.Background(), files))
+
+	prompt := firstTurnPrompt(t, workDir)
+	assert.Contains(t, prompt, `Added:\n- added.go`)
+	assert.Contains(t, prompt, `Modified:\n- main.go`)
+	assert.Contains(t, prompt, "- gone.go")
+	assert.Contains(t, prompt, "- old.go → new.go")
+	assert.NotContains(t, prompt, "- old.go\\n")
+
+	// The manifest follows the rename
+	assert.False(t, manifest.Has("old.go"))
+	assert.True(t, manifest.Has("new.go"))
+	assert.False(t, manifest.Has("gone.go"))
+}
+
+func TestAnalyse_NotedRename(t *testing.T) {
+	workDir := setupWorkDir(t)
+	require.NoError(t, os.Rename(filepath.Join(workDir, "main.go"), filepath.Join(workDir, "app.go")))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "app.go"), []byte("package main // edited\n"), 0644))
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	ana.SetRecording(true)
+	ana.NoteRename(filepath.Join(workDir, "main.go"), filepath.Join(workDir, "app.go"))
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go"), filepath.Join(workDir, "app.go")}))
+
+	assert.Contains(t, firstTurnPrompt(t, workDir), "- main.go → app.go")
+}
+
+func TestWatcher_PairsRenames(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath, newPath := filepath.Join(tmpDir, "old.txt"), filepath.Join(tmpDir, "new.txt")
+	require.NoError(t, os.WriteFile(oldPath, []byte("content"), 0644))
+
+	var mu sync.Mutex
+	var renames [][2]string
+	watcher, err := analyzer.NewWatcher(tmpDir, nil, 50, 200, func(files []string) {})
+	require.NoError(t, err)
+	defer watcher.Close()
+	watcher.SetOnRename(func(oldPath, newPath string) {
+		mu.Lock()
+		renames = append(renames, [2]string{oldPath, newPath})
+		mu.Unlock()
+	})
+	go watcher.Run()
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.Rename(oldPath, newPath))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(renames) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [2]string{oldPath, newPath}, renames[0])
+}
@@ -0,0 +1,86 @@
+package analyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeCoverage(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	for path, content := range map[string]string{
+		"store/db.go":        "package store\n\nfunc Open() {}\n",
+		"store/cache/lru.go": "package cache\n",
+		"api/handler.go":     "package api\n\nfunc Serve() {}\n",
+		"docs/guide.md":      "# Guide\n",
+		"scripts/x/y.sh":     "#!/bin/sh\n",
+		"ignored/skip.go":    "package ignored\n",
+	} {
+		path = filepath.Join(workDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	arch := `{"modules": [{"name": "Store", "description": "d", "interfaces": "i"}], "relationships": ""}`
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(arch), 0644))
+	iface := `{"external": [{"type": "func", "name": "api.Serve", "params": "", "description": "d"}], "internal": []}`
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "interface.json"), []byte(iface), 0644))
+	writeIssue(t, indexDir, "./main.go", "TODO", 3)
+
+	files := analyzer.ListFiles(workDir, []string{".memo", "ignored"})
+	cov, err := analyzer.ComputeCoverage(indexDir, workDir, files)
+	require.NoError(t, err)
+
+	entries := make(map[string][]string)
+	for _, f := range cov.Files {
+		entries[f.Path] = f.Entries
+	}
+	assert.Equal(t, map[string][]string{
+		"main.go":            {"issues.json: issues[0] t"},
+		"store/db.go":        {"arch.json: modules[0] Store"},
+		"store/cache/lru.go": {"arch.json: modules[0] Store"},
+		"api/handler.go":     {"interface.json: external[0] api.Serve"},
+		"docs/guide.md":      nil,
+		"scripts/x/y.sh":     nil,
+	}, entries)
+
+	assert.Equal(t, 4, cov.Covered)
+	assert.Equal(t, 6, cov.Total)
+	assert.InDelta(t, 66.7, cov.Percent, 0.1)
+	assert.Equal(t, []string{"docs", "scripts"}, cov.UncoveredDirs, "only the topmost uncovered directories")
+	assert.Equal(t, []string{"docs/guide.md", "scripts/x/y.sh"}, cov.Uncovered())
+
+	for _, d := range cov.Dirs {
+		if d.Path == "store" {
+			assert.Equal(t, analyzer.DirCoverage{Path: "store", Entries: []string{"arch.json: modules[0] Store"}, Files: 2, Covered: 2}, d)
+		}
+	}
+}
+
+func TestTextFiles(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for name, content := range map[string]string{
+		"main.go":   "package main\n",
+		"empty.txt": "",
+		"logo.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		files = append(files, path)
+	}
+	files = append(files, filepath.Join(dir, "gone.go"))
+
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "main.go"), filepath.Join(dir, "empty.txt")}, analyzer.TextFiles(files))
+}
+
+func TestComputeCoverage_InvalidIndex(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte("{"), 0644))
+
+	_, err := analyzer.ComputeCoverage(indexDir, workDir, nil)
+	assert.ErrorContains(t, err, "arch.json")
+}
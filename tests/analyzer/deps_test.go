@@ -0,0 +1,98 @@
+//go:build testing
+
+package analyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDepsProject creates a small multi-language project with cross-directory imports
+func setupDepsProject(t *testing.T) (string, []string) {
+	t.Helper()
+	workDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.22\n",
+		"api/handler.go":  "package api\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/store\"\n)\n",
+		"store/store.go":  "package store\n",
+		"store/cache.go":  "package store\n",
+		"util/strings.go": "package util\n",
+		"web/app.ts":      "import { get } from './lib/http';\n",
+		"web/lib/http.ts": "export const get = () => fetch('/');\n",
+		"py/main.py":      "import os\nfrom .helpers import fmt\n",
+		"py/helpers.py":   "def fmt(): pass\n",
+	}
+	var rel []string
+	for name, content := range files {
+		path := filepath.Join(workDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		if name != "go.mod" {
+			rel = append(rel, filepath.FromSlash(name))
+		}
+	}
+	return workDir, rel
+}
+
+// batchOf returns the index of the batch containing file
+func batchOf(batches [][]string, file string) int {
+	for i, b := range batches {
+		for _, f := range b {
+			if f == filepath.FromSlash(file) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func unitWeight(string) int { return 1 }
+
+func TestSplitByDeps_ClustersImports(t *testing.T) {
+	workDir, files := setupDepsProject(t)
+
+	batches := analyzer.SplitByDeps(workDir, files, 3, unitWeight)
+
+	total := 0
+	for _, b := range batches {
+		assert.LessOrEqual(t, len(b), 3, "batches must respect the budget")
+		total += len(b)
+	}
+	assert.Equal(t, len(files), total)
+
+	assert.Equal(t, batchOf(batches, "api/handler.go"), batchOf(batches, "store/store.go"), "Go import")
+	assert.Equal(t, batchOf(batches, "store/store.go"), batchOf(batches, "store/cache.go"), "same Go package")
+	assert.Equal(t, batchOf(batches, "web/app.ts"), batchOf(batches, "web/lib/http.ts"), "TS relative import")
+	assert.Equal(t, batchOf(batches, "py/main.py"), batchOf(batches, "py/helpers.py"), "Python relative import")
+
+	// Directory grouping cannot see the cross-directory import
+	byDir := analyzer.SplitIntoBatchesByWeight(files, 3, unitWeight)
+	assert.NotEqual(t, batchOf(byDir, "api/handler.go"), batchOf(byDir, "store/store.go"))
+}
+
+func TestSplitByDeps_RespectsBudget(t *testing.T) {
+	workDir, files := setupDepsProject(t)
+
+	batches := analyzer.SplitByDeps(workDir, files, 2, unitWeight)
+
+	for _, b := range batches {
+		assert.LessOrEqual(t, len(b), 2)
+	}
+	// Package edges are applied first, so the package stays together
+	assert.Equal(t, batchOf(batches, "store/store.go"), batchOf(batches, "store/cache.go"))
+	assert.NotEqual(t, batchOf(batches, "api/handler.go"), batchOf(batches, "store/store.go"))
+}
+
+func TestSetBatchStrategy(t *testing.T) {
+	ana, err := analyzer.NewAnalyser(analyzer.AgentConfig{Backend: analyzer.BackendScripted, ScriptDir: writeScript(t, map[string]string{"001.json": `{}`})}, t.TempDir())
+	require.NoError(t, err)
+
+	assert.NoError(t, ana.SetBatchStrategy(analyzer.BatchByDeps))
+	assert.NoError(t, ana.SetBatchStrategy(analyzer.BatchByDirectory))
+	assert.Error(t, ana.SetBatchStrategy("random"))
+}
@@ -0,0 +1,49 @@
+package analyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInbox_AppendDrain(t *testing.T) {
+	memoDir := filepath.Join(setupWorkDir(t), ".memo")
+
+	files, err := analyzer.DrainInbox(memoDir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	require.NoError(t, analyzer.AppendInbox(memoDir, []string{"a.go", "pkg/b.go"}))
+	require.NoError(t, analyzer.AppendInbox(memoDir, []string{"a.go"}))
+	files, err = analyzer.DrainInbox(memoDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go", filepath.Join("pkg", "b.go")}, files)
+
+	files, err = analyzer.DrainInbox(memoDir)
+	require.NoError(t, err)
+	assert.Empty(t, files, "drained files are removed")
+}
+
+func TestWatcher_ScanInbox(t *testing.T) {
+	workDir := setupWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "util.go"), []byte("package main\n"), 0644))
+	manifest, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	manifest.Update([]string{"main.go", "util.go"})
+
+	var got []string
+	watcher, err := analyzer.NewWatcher(workDir, []string{".memo"}, 50, 200, func(files []string) { got = files })
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	// Only queued files that changed since their analysis are added
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "util.go"), []byte("package main\n\nfunc util() {}\n"), 0644))
+	require.NoError(t, analyzer.AppendInbox(filepath.Join(workDir, ".memo"), []string{"main.go", "util.go", "gone.go"}))
+	assert.Equal(t, 1, watcher.ScanInbox(manifest))
+	watcher.Flush()
+	assert.Equal(t, []string{filepath.Join(workDir, "util.go")}, got)
+}
@@ -0,0 +1,77 @@
+package analyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const decisionsSchema = `{
+	"type": "object",
+	"properties": {
+		"decisions": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {"title": {"type": "string"}, "rationale": {"type": "string"}},
+				"required": ["title", "rationale"]
+			}
+		}
+	},
+	"required": ["decisions"]
+}`
+
+// builtinIndexFiles returns the index files without project-defined ones
+func builtinIndexFiles(t *testing.T) *analyzer.IndexFiles {
+	t.Helper()
+	files, err := analyzer.NewIndexFiles(nil)
+	require.NoError(t, err)
+	return files
+}
+
+func TestNewIndexFiles_Custom(t *testing.T) {
+	files, err := analyzer.NewIndexFiles([]analyzer.IndexFile{{Name: "decisions.json", Schema: decisionsSchema}})
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"decisions":[]}`, files.Initial()["decisions.json"], "initial content derived from the schema")
+	assert.Contains(t, files.Initial(), "arch.json")
+	require.Len(t, files.Custom(), 1)
+	assert.NotContains(t, analyzer.DefaultIndexFiles, "decisions.json", "the built-in files are left alone")
+
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	result := files.Validate(indexDir)
+	assert.False(t, result.Valid, "a missing custom file fails validation")
+	assert.True(t, analyzer.ValidateIndex(indexDir).Valid, "the built-in files know nothing of it")
+
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "decisions.json"), []byte(`{"decisions": [{"title": "x"}]}`), 0644))
+	result = files.Validate(indexDir)
+	assert.False(t, result.Valid)
+	assert.Contains(t, analyzer.FormatValidationErrors(result), "decisions.json")
+
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "decisions.json"), []byte(`{"decisions": [{"title": "x", "rationale": "y"}]}`), 0644))
+	assert.True(t, files.Validate(indexDir).Valid)
+}
+
+func TestNewIndexFiles_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		file analyzer.IndexFile
+	}{
+		{"invalid name", analyzer.IndexFile{Name: "../decisions.json", Schema: decisionsSchema}},
+		{"built-in name", analyzer.IndexFile{Name: "arch.json", Schema: decisionsSchema}},
+		{"invalid schema", analyzer.IndexFile{Name: "decisions.json", Schema: `{"type": 5}`}},
+		{"initial not matching", analyzer.IndexFile{Name: "decisions.json", Schema: decisionsSchema, Initial: `{}`}},
+		{"underivable initial", analyzer.IndexFile{Name: "decisions.json", Schema: `{"required": ["x"]}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := analyzer.NewIndexFiles([]analyzer.IndexFile{tt.file})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package analyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintIndex_Clean(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(validArch), 0644))
+
+	findings, err := analyzer.LintIndex(indexDir, workDir, analyzer.LintOptions{RequiredModules: []string{"main"}})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestLintIndex_SeveritiesAndRules(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	writeIssue(t, indexDir, "gone.go", "TODO", 3)
+
+	findings, err := analyzer.LintIndex(indexDir, workDir, analyzer.LintOptions{
+		Severity:        map[string]string{"issue-locations": analyzer.SeverityWarning},
+		MaxFileBytes:    10,
+		RequiredModules: []string{"core"},
+	})
+	require.NoError(t, err)
+
+	byRule := make(map[string][]analyzer.LintFinding)
+	for _, f := range findings {
+		byRule[f.Rule] = append(byRule[f.Rule], f)
+	}
+	require.Len(t, byRule["issue-locations"], 1)
+	assert.Equal(t, analyzer.LintFinding{
+		Rule:     "issue-locations",
+		Severity: analyzer.SeverityWarning,
+		File:     "issues.json",
+		Message:  byRule["issue-locations"][0].Message,
+	}, byRule["issue-locations"][0])
+	assert.True(t, strings.HasPrefix(byRule["issue-locations"][0].Message, "issues[0].locations[0]: file"))
+
+	require.Len(t, byRule[analyzer.LintRequiredModules], 1)
+	assert.Equal(t, analyzer.SeverityError, byRule[analyzer.LintRequiredModules][0].Severity)
+	assert.Contains(t, byRule[analyzer.LintRequiredModules][0].Message, `required module "core"`)
+	assert.Len(t, byRule[analyzer.LintSize], 4, "every index file exceeds 10 bytes")
+
+	// Turned off
+	findings, err = analyzer.LintIndex(indexDir, workDir, analyzer.LintOptions{
+		Severity: map[string]string{"issue-locations": analyzer.SeverityOff},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestLintIndex_SchemaErrorsSkipSemanticRules(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(`{"modules": []}`), 0644))
+	writeIssue(t, indexDir, "gone.go", "TODO", 3)
+
+	findings, err := analyzer.LintIndex(indexDir, workDir, analyzer.LintOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, findings)
+	for _, f := range findings {
+		assert.Equal(t, analyzer.LintSchema, f.Rule)
+		assert.Equal(t, "arch.json", f.File)
+	}
+}
+
+func TestLintIndex_InvalidOptions(t *testing.T) {
+	_, indexDir := setupRulesWorkDir(t)
+	_, err := analyzer.LintIndex(indexDir, "", analyzer.LintOptions{Severity: map[string]string{"nope": "error"}})
+	assert.ErrorContains(t, err, "unknown lint rule")
+	_, err = analyzer.LintIndex(indexDir, "", analyzer.LintOptions{Severity: map[string]string{"schema": "fatal"}})
+	assert.ErrorContains(t, err, "unknown severity")
+}
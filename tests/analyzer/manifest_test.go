@@ -0,0 +1,123 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadManifest_Missing(t *testing.T) {
+	m, err := analyzer.LoadManifest(t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestLoadManifest_Corrupt(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, ".memo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, ".memo", "manifest.json"), []byte("{bad"), 0644))
+
+	m, err := analyzer.LoadManifest(workDir)
+	assert.Error(t, err)
+	require.NotNil(t, m, "a corrupt manifest should fall back to an empty one")
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestManifest_Changed(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, ".memo"), 0755))
+	same := filepath.Join(workDir, "same.go")
+	modified := filepath.Join(workDir, "modified.go")
+	touched := filepath.Join(workDir, "touched.go")
+	deleted := filepath.Join(workDir, "deleted.go")
+	for _, p := range []string{same, modified, touched, deleted} {
+		require.NoError(t, os.WriteFile(p, []byte("package x\n"), 0644))
+	}
+
+	m, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	m.Update([]string{"same.go", "modified.go", "touched.go", "deleted.go"})
+	require.NoError(t, m.Save())
+
+	// Reload to check persistence
+	m, err = analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	assert.Equal(t, 4, m.Len())
+
+	require.NoError(t, os.WriteFile(modified, []byte("package y\n"), 0644))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(touched, later, later)) // new mtime, same content
+	require.NoError(t, os.Remove(deleted))
+	added := filepath.Join(workDir, "added.go")
+	require.NoError(t, os.WriteFile(added, []byte("package z\n"), 0644))
+
+	changed := m.Changed([]string{same, modified, touched, added})
+	assert.ElementsMatch(t, []string{modified, added, deleted}, changed)
+
+	// Recording the deletion removes the entry
+	m.Update([]string{"deleted.go"})
+	assert.Equal(t, 3, m.Len())
+}
+
+func TestManifest_UpdateReadKeepsFilesChangedDuringAnalysis(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, ".memo"), 0755))
+	edited := filepath.Join(workDir, "edited.go")
+	removed := filepath.Join(workDir, "removed.go")
+	steady := filepath.Join(workDir, "steady.go")
+	for _, p := range []string{edited, removed, steady} {
+		require.NoError(t, os.WriteFile(p, []byte("package x\n"), 0644))
+	}
+	files := []string{"edited.go", "removed.go", "steady.go"}
+	read := analyzer.HashFiles(workDir, files)
+
+	// Edited while the agent was working
+	require.NoError(t, os.WriteFile(edited, []byte("package y\n"), 0644))
+	require.NoError(t, os.Remove(removed))
+
+	m, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	m.UpdateRead(files, read)
+	assert.False(t, m.Has("edited.go"), "the version analysed is not on disk any more")
+	assert.False(t, m.Has("removed.go"))
+	assert.True(t, m.Has("steady.go"))
+	assert.Equal(t, []string{edited}, m.Changed([]string{edited, steady}))
+}
+
+func TestAnalyse_UpdatesManifest(t *testing.T) {
+	workDir := setupWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	m, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	ana.SetManifest(m)
+
+	mainGo := filepath.Join(workDir, "main.go")
+	require.NoError(t, ana.Analyse(context.Background(), []string{mainGo}))
+
+	reloaded, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reloaded.Len())
+	assert.Empty(t, reloaded.Changed([]string{mainGo}))
+}
+
+func TestAnalyse_FailedBatchNotInManifest(t *testing.T) {
+	workDir := setupWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"error": "agent crashed"}`,
+	}))
+	m, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	ana.SetManifest(m)
+
+	require.Error(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+	assert.Equal(t, 0, m.Len())
+	assert.NoFileExists(t, filepath.Join(workDir, ".memo", "manifest.json"))
+}
@@ -0,0 +1,101 @@
+//go:build testing
+
+package analyzer_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeIndexDir writes index files into a new temp directory
+func writeIndexDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func readJSONFile(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var obj map[string]any
+	require.NoError(t, json.Unmarshal(data, &obj))
+	return obj
+}
+
+func names(t *testing.T, list any, key string) []string {
+	t.Helper()
+	var out []string
+	for _, e := range list.([]any) {
+		out = append(out, e.(map[string]any)[key].(string))
+	}
+	return out
+}
+
+func TestMergeFragments_CombinesEntries(t *testing.T) {
+	base := writeIndexDir(t, map[string]string{
+		"arch.json":   `{"modules": [{"name": "a", "description": "old", "interfaces": ""}, {"name": "x", "description": "", "interfaces": ""}], "relationships": "base"}`,
+		"issues.json": `{"issues": []}`,
+	})
+	frag1 := writeIndexDir(t, map[string]string{
+		"arch.json":   `{"modules": [{"name": "a", "description": "new", "interfaces": ""}, {"name": "x", "description": "", "interfaces": ""}, {"name": "b", "description": "", "interfaces": ""}], "relationships": "base"}`,
+		"issues.json": `{"issues": [{"tags": ["todo"], "title": "one", "description": "", "locations": []}]}`,
+	})
+	frag2 := writeIndexDir(t, map[string]string{
+		"arch.json":   `{"modules": [{"name": "a", "description": "old", "interfaces": ""}, {"name": "c", "description": "", "interfaces": ""}], "relationships": "frag2"}`,
+		"issues.json": `{"issues": [{"tags": ["bug"], "title": "two", "description": "", "locations": []}]}`,
+	})
+	out := t.TempDir()
+
+	require.NoError(t, analyzer.MergeFragments(base, []string{frag1, frag2}, out))
+
+	arch := readJSONFile(t, filepath.Join(out, "arch.json"))
+	assert.Equal(t, []string{"a", "b", "c"}, names(t, arch["modules"], "name"), "x is deleted by frag2, b and c are added")
+	assert.Equal(t, "new", arch["modules"].([]any)[0].(map[string]any)["description"], "frag2 did not change a, so frag1's change is kept")
+	assert.Equal(t, "frag2", arch["relationships"])
+
+	issues := readJSONFile(t, filepath.Join(out, "issues.json"))
+	assert.Equal(t, []string{"one", "two"}, names(t, issues["issues"], "title"))
+}
+
+func TestMergeFragments_LastChangeWins(t *testing.T) {
+	base := writeIndexDir(t, map[string]string{
+		"interface.json": `{"external": [{"type": "cli", "name": "scan", "params": "", "description": "v0"}], "internal": []}`,
+	})
+	frag1 := writeIndexDir(t, map[string]string{
+		"interface.json": `{"external": [{"type": "cli", "name": "scan", "params": "", "description": "v1"}], "internal": []}`,
+	})
+	frag2 := writeIndexDir(t, map[string]string{
+		"interface.json": `{"external": [{"type": "cli", "name": "scan", "params": "", "description": "v2"}, {"type": "rest", "name": "scan", "params": "", "description": ""}], "internal": []}`,
+	})
+	out := t.TempDir()
+
+	require.NoError(t, analyzer.MergeFragments(base, []string{frag1, frag2}, out))
+
+	iface := readJSONFile(t, filepath.Join(out, "interface.json"))
+	external := iface["external"].([]any)
+	require.Len(t, external, 2, "entries are keyed by type and name")
+	assert.Equal(t, "v2", external[0].(map[string]any)["description"])
+}
+
+func TestMergeFragments_UnchangedFragment(t *testing.T) {
+	content := `{"stories": [{"title": "s", "tags": [], "content": ""}]}`
+	base := writeIndexDir(t, map[string]string{"stories.json": content})
+	frag := writeIndexDir(t, map[string]string{"stories.json": content})
+	out := t.TempDir()
+
+	require.NoError(t, analyzer.MergeFragments(base, []string{frag}, out))
+
+	data, err := os.ReadFile(filepath.Join(out, "stories.json"))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
@@ -0,0 +1,75 @@
+package analyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateIndex_DryRun(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "interface.json"), []byte(`{"external": []}`), 0644))
+	require.NoError(t, os.Remove(filepath.Join(indexDir, "stories.json")))
+
+	result, err := analyzer.MigrateIndex(indexDir, builtinIndexFiles(t), true)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.From)
+	assert.Equal(t, analyzer.IndexVersion, result.To)
+	require.Len(t, result.Applied, 1)
+	assert.Contains(t, result.Diff, "+++ b/meta.json")
+	assert.Contains(t, result.Diff, `+  "internal": []`)
+	assert.Contains(t, result.Diff, "+++ b/stories.json")
+
+	// Nothing written
+	version, err := analyzer.ReadIndexVersion(indexDir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.NoFileExists(t, filepath.Join(indexDir, "stories.json"))
+}
+
+func TestMigrateIndex_Apply(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "interface.json"), []byte(`{"external": []}`), 0644))
+
+	_, err := analyzer.MigrateIndex(indexDir, builtinIndexFiles(t), false)
+	require.NoError(t, err)
+	version, err := analyzer.ReadIndexVersion(indexDir)
+	require.NoError(t, err)
+	assert.Equal(t, analyzer.IndexVersion, version)
+	assert.True(t, analyzer.ValidateIndex(indexDir).Valid)
+
+	// Already current: nothing to do
+	result, err := analyzer.MigrateIndex(indexDir, builtinIndexFiles(t), false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Applied)
+	assert.Empty(t, result.Diff)
+}
+
+func TestMigrateIndex_NewerVersion(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "meta.json"), []byte(`{"version": 99}`), 0644))
+
+	_, err := analyzer.MigrateIndex(indexDir, builtinIndexFiles(t), false)
+	assert.ErrorContains(t, err, "newer")
+}
+
+func TestMigrateIndex_AddsCustomFiles(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	files, err := analyzer.NewIndexFiles([]analyzer.IndexFile{{Name: "decisions.json", Schema: decisionsSchema}})
+	require.NoError(t, err)
+
+	_, err = analyzer.MigrateIndex(indexDir, files, false)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(indexDir, "decisions.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"decisions": []}`, string(data))
+	assert.True(t, files.Validate(indexDir).Valid)
+}
@@ -0,0 +1,95 @@
+package analyzer_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest_KeepsPreviousContent(t *testing.T) {
+	workDir := t.TempDir()
+	path := filepath.Join(workDir, "a.go")
+	require.NoError(t, os.WriteFile(path, []byte("v1\n"), 0644))
+
+	manifest, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	manifest.Update([]string{"a.go"})
+	prev, ok := manifest.Previous("a.go")
+	require.True(t, ok)
+	assert.Equal(t, "v1\n", prev)
+
+	require.NoError(t, os.WriteFile(path, []byte("v2, longer\n"), 0644))
+	manifest.Update([]string{"a.go"})
+	prev, ok = manifest.Previous("a.go")
+	require.True(t, ok)
+	assert.Equal(t, "v2, longer\n", prev)
+
+	// Only the current version is kept
+	objects, err := filepath.Glob(filepath.Join(workDir, ".memo", "objects", "*", "*"))
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	require.NoError(t, os.Remove(path))
+	manifest.Update([]string{"a.go"})
+	_, ok = manifest.Previous("a.go")
+	assert.False(t, ok)
+	objects, err = filepath.Glob(filepath.Join(workDir, ".memo", "objects", "*", "*"))
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+// setupDiffWorkDir records a 40-line lib.go in the manifest and then changes one line
+func setupDiffWorkDir(t *testing.T) (string, *analyzer.Manifest) {
+	t.Helper()
+	workDir := setupWorkDir(t)
+	var lines []string
+	for i := 0; i < 40; i++ {
+		lines = append(lines, fmt.Sprintf("func f%d() {}", i))
+	}
+	path := filepath.Join(workDir, "lib.go")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	manifest, err := analyzer.LoadManifest(workDir)
+	require.NoError(t, err)
+	manifest.Update([]string{"lib.go"})
+
+	lines[20] = "func changed() {}"
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	return workDir, manifest
+}
+
+func TestAnalyse_SendsDiffOfModifiedFile(t *testing.T) {
+	workDir, manifest := setupDiffWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	ana.SetManifest(manifest)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "lib.go")}))
+
+	prompt := firstTurnPrompt(t, workDir)
+	assert.Contains(t, prompt, "## Diffs Since Last Analysis")
+	assert.Contains(t, prompt, `+++ b/lib.go`)
+	assert.Contains(t, prompt, `-func f20() {}\n+func changed() {}`)
+}
+
+func TestAnalyse_LargeDiffFallsBackToFile(t *testing.T) {
+	workDir, manifest := setupDiffWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	ana.SetManifest(manifest)
+	ana.SetMaxDiffTokens(10)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "lib.go")}))
+
+	prompt := firstTurnPrompt(t, workDir)
+	assert.NotContains(t, prompt, "## Diffs Since Last Analysis")
+	assert.Contains(t, prompt, `Modified:\n- lib.go`)
+}
@@ -0,0 +1,116 @@
+package analyzer_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// twoBatchBudget is a token budget that fits one directory of setupTwoBatchWorkDir but not both
+const twoBatchBudget = 100
+
+// setupTwoBatchWorkDir creates files in two directories, split into two batches under twoBatchBudget
+func setupTwoBatchWorkDir(t *testing.T) (string, []string) {
+	t.Helper()
+	workDir := setupWorkDir(t)
+	var files []string
+	for _, dir := range []string{"a", "b"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workDir, dir), 0755))
+		for i := 0; i < 3; i++ {
+			path := filepath.Join(workDir, dir, fmt.Sprintf("f%d.go", i))
+			require.NoError(t, os.WriteFile(path, []byte("package "+dir+"\n"), 0644))
+			files = append(files, path)
+		}
+	}
+	return workDir, files
+}
+
+// moduleTurn is a scripted turn for one batch adding a single module
+func moduleTurn(batch int, name string) string {
+	return fmt.Sprintf(`{"batch": %d, "files": {"arch.json": {"modules": [{"name": %q, "description": "analysed code", "interfaces": ""}], "relationships": ""}}}`, batch, name)
+}
+
+func TestAnalyse_ParallelMergesFragments(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": moduleTurn(2, "second"),
+	}))
+	ana.SetWorkers(2)
+	ana.SetTokenBudget(twoBatchBudget)
+
+	require.NoError(t, ana.Analyse(context.Background(), files))
+
+	memoDir := filepath.Join(workDir, ".memo")
+	data, err := os.ReadFile(filepath.Join(memoDir, "index", "arch.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"first"`)
+	assert.Contains(t, string(data), `"second"`)
+	assert.True(t, analyzer.ValidateIndex(filepath.Join(memoDir, "index")).Valid)
+	assert.NoDirExists(t, filepath.Join(memoDir, "fragments"), "fragments are removed after the merge")
+}
+
+func TestAnalyse_ParallelKeepsSuccessfulBatches(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": `{"batch": 2, "error": "agent crashed"}`,
+	}))
+	ana.SetWorkers(2)
+	ana.SetTokenBudget(twoBatchBudget)
+
+	err := ana.Analyse(context.Background(), files)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "batch 2/2")
+
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "index", "arch.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"first"`, "batch 1 should still be merged")
+}
+
+func TestAnalyse_ParallelSnapshotsSuccessfulBatches(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": `{"batch": 2, "error": "agent crashed"}`,
+	}))
+	ana.SetWorkers(2)
+	ana.SetTokenBudget(twoBatchBudget)
+	store := analyzer.OpenSnapshots(workDir, 0)
+	ana.SetSnapshots(store)
+
+	require.Error(t, ana.Analyse(context.Background(), files))
+
+	log, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, log, 1, "the merged batch should be snapshotted")
+	assert.Equal(t, []string{"a/f0.go", "a/f1.go", "a/f2.go"}, log[0].Files)
+}
+
+func TestReplay_ParallelRecording(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": moduleTurn(2, "second"),
+	}))
+	ana.SetWorkers(2)
+	ana.SetTokenBudget(twoBatchBudget)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), files))
+
+	recordings, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*"))
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+
+	result, err := analyzer.Replay(context.Background(), recordings[0])
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, 2, result.Meta.Workers)
+	assert.Empty(t, result.Diff)
+}
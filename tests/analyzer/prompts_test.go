@@ -0,0 +1,98 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPrompts_OverridesOnePrompt(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "analyse.md"), []byte("Custom analyse"), 0644))
+
+	prompts, err := analyzer.LoadPrompts(dir)
+	require.NoError(t, err)
+	require.Len(t, prompts, len(analyzer.PromptNames))
+	for _, p := range prompts {
+		if p.Name == "analyse" {
+			assert.Equal(t, filepath.Join(dir, "analyse.md"), p.Source)
+			assert.Equal(t, "Custom analyse", p.Text)
+		} else {
+			assert.Empty(t, p.Source, "%s should be built-in", p.Name)
+			assert.NotEmpty(t, p.Text)
+		}
+	}
+}
+
+func TestPrompt_Render(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := `Batch {{.Batch}}/{{.TotalBatches}} in {{.Language}}:{{range .Files}} {{.}}{{end}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "analyse.md"), []byte(tmpl), 0644))
+
+	prompts, err := analyzer.LoadPrompts(dir)
+	require.NoError(t, err)
+	text, err := prompts[1].Render(analyzer.PromptData{Files: []string{"a.go", "b.go"}, Batch: 2, TotalBatches: 3, Language: "German"})
+	require.NoError(t, err)
+	assert.Equal(t, "Batch 2/3 in German: a.go b.go", text)
+}
+
+func TestLoadPrompts_InvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "feedback.md"), []byte("{{.Errors"), 0644))
+
+	_, err := analyzer.LoadPrompts(dir)
+	assert.ErrorContains(t, err, "feedback")
+}
+
+func TestAnalyse_UsesPromptOverrides(t *testing.T) {
+	workDir := setupWorkDir(t)
+	dir := filepath.Join(workDir, ".memo", "prompts")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "analyse.md"),
+		[]byte(`Document batch {{.Batch}}: {{range .Files}}{{.}} {{end}}in {{.IndexDir}}`), 0644))
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	require.NoError(t, ana.SetPrompts(dir))
+	ana.SetLanguage("German")
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	turns, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "turns", "001.json"))
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+	data, err := os.ReadFile(turns[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Document batch 1: main.go in .memo/staging")
+	assert.Contains(t, string(data), "Write all descriptions in German")
+	assert.NotContains(t, string(data), "{{")
+}
+
+func TestAnalyse_FeedbackOverrideListsErrorsOnce(t *testing.T) {
+	workDir := setupWorkDir(t)
+	dir := filepath.Join(workDir, ".memo", "prompts")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "feedback.md"), []byte("Fix these:\n{{.Errors}}"), 0644))
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": "{broken"}}`,
+		"002.json": `{"files": {"arch.json": ` + validArch + `}}`,
+	}))
+	require.NoError(t, ana.SetPrompts(dir))
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	turns, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "turns", "002.json"))
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+	data, err := os.ReadFile(turns[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Fix these:")
+	assert.NotContains(t, string(data), "Validation errors:", "the template already lists the errors")
+}
@@ -0,0 +1,137 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/YoungY620/memo/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadProvenance(t *testing.T, workDir string) map[string][]string {
+	t.Helper()
+	p, err := analyzer.LoadProvenance(filepath.Join(workDir, ".memo", "provenance.json"))
+	require.NoError(t, err)
+	sources := make(map[string][]string)
+	for _, e := range p.Entries {
+		assert.False(t, e.Analysed.IsZero())
+		var paths []string
+		for _, src := range e.Sources {
+			assert.NotEmpty(t, src.SHA256, src.Path)
+			paths = append(paths, src.Path)
+		}
+		sources[e.File+" "+e.Field+" "+e.Key["name"]] = paths
+	}
+	return sources
+}
+
+func TestAnalyse_RecordsProvenance(t *testing.T) {
+	workDir := setupWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "util.go"), []byte("package main\n"), 0644))
+	twoModules := `{"files": {"arch.json": {"modules": [{"name": "main", "description": "entry point", "interfaces": "none"}, {"name": "util", "description": "helpers", "interfaces": "none"}], "relationships": ""}}}`
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+		"002.json": twoModules,
+		"003.json": `{"files": {"arch.json": {"modules": [{"name": "util", "description": "helpers", "interfaces": "none"}], "relationships": ""}}}`,
+	}))
+
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+	assert.Equal(t, map[string][]string{"arch.json modules main": {"main.go"}}, loadProvenance(t, workDir))
+
+	// An unchanged entry keeps its sources
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "util.go")}))
+	assert.Equal(t, map[string][]string{
+		"arch.json modules main": {"main.go"},
+		"arch.json modules util": {"util.go"},
+	}, loadProvenance(t, workDir))
+
+	// Removed entries are forgotten
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+	assert.Equal(t, map[string][]string{"arch.json modules util": {"util.go"}}, loadProvenance(t, workDir))
+}
+
+func TestAnalyse_AttributesSourcesPerEntry(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	util := filepath.Join(workDir, "util.go")
+	require.NoError(t, os.WriteFile(util, []byte("package main\n\n// TODO: cache\nfunc Helper() {}\n"), 0644))
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {
+			"arch.json": {"modules": [{"name": "main", "description": "entry point", "interfaces": "none"}, {"name": "util", "description": "helpers", "interfaces": "none"}], "relationships": ""},
+			"interface.json": {"external": [], "internal": [{"type": "func", "name": "Helper", "params": "", "description": "helps"}]},
+			"stories.json": {"stories": [{"title": "Startup", "tags": ["flow"], "content": "main calls the helpers"}]},
+			"issues.json": {"issues": [{"tags": ["todo"], "title": "No cache", "description": "d", "locations": [{"file": "util.go", "keyword": "TODO", "line": 3}]}]}
+		}}`,
+	}))
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go"), util}))
+
+	p, err := analyzer.LoadProvenance(filepath.Join(workDir, ".memo", "provenance.json"))
+	require.NoError(t, err)
+	sources := make(map[string][]string)
+	for _, e := range p.Entries {
+		var paths []string
+		for _, src := range e.Sources {
+			paths = append(paths, src.Path)
+		}
+		sources[e.File+" "+e.Key["name"]+e.Key["title"]] = paths
+	}
+	assert.Equal(t, map[string][]string{
+		"arch.json main":        {"main.go"},
+		"arch.json util":        {"util.go"},
+		"interface.json Helper": {"util.go"},
+		"issues.json No cache":  {"util.go"},
+		"stories.json Startup":  {"main.go", "util.go"}, // names no file: the whole batch
+	}, sources)
+
+	// Editing one file leaves the entries of the other fresh
+	require.NoError(t, os.WriteFile(util, []byte("package main\n"), 0644))
+	changed, err := mcp.ChangedSources(indexDir, workDir, "[arch][modules][0]")
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+	changed, err = mcp.ChangedSources(indexDir, workDir, "[arch][modules][1]")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"util.go"}, changed)
+}
+
+func TestAnalyse_RefreshesProvenanceOfUnchangedEntries(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": ` + validArch + `}}`,
+		"002.json": `{"text": "main is still the entry point"}`,
+	}))
+	main := filepath.Join(workDir, "main.go")
+	require.NoError(t, ana.Analyse(context.Background(), []string{main}))
+
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() {}\n"), 0644))
+	changed, err := mcp.ChangedSources(indexDir, workDir, "[arch][modules][0]")
+	require.NoError(t, err)
+	require.Equal(t, []string{"main.go"}, changed)
+
+	// A batch over the edited file that leaves the entry as it is
+	require.NoError(t, ana.Analyse(context.Background(), []string{main}))
+	changed, err = mcp.ChangedSources(indexDir, workDir, "[arch][modules][0]")
+	require.NoError(t, err)
+	assert.Empty(t, changed, "the entry should be fresh again")
+	assert.Equal(t, map[string][]string{"arch.json modules main": {"main.go"}}, loadProvenance(t, workDir))
+}
+
+func TestAnalyse_ParallelRecordsProvenance(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": moduleTurn(2, "second"),
+	}))
+	ana.SetWorkers(2)
+	ana.SetTokenBudget(twoBatchBudget)
+
+	require.NoError(t, ana.Analyse(context.Background(), files))
+	assert.Equal(t, map[string][]string{
+		"arch.json modules first":  {"a/f0.go", "a/f1.go", "a/f2.go"},
+		"arch.json modules second": {"b/f0.go", "b/f1.go", "b/f2.go"},
+	}, loadProvenance(t, workDir))
+}
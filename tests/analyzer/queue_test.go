@@ -0,0 +1,106 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_PlanAndComplete(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, ".memo"), 0755))
+	queuePath := filepath.Join(workDir, ".memo", "queue.json")
+
+	q, err := analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	assert.Empty(t, q.Pending())
+
+	require.NoError(t, q.Plan([][]string{{"a.go"}, {"b.go", "c.go"}}))
+	require.NoError(t, q.Complete([]string{"a.go"}))
+	assert.FileExists(t, queuePath)
+
+	// Reload to check persistence
+	q, err = analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, [][]string{{"b.go", "c.go"}}, q.Pending())
+
+	require.NoError(t, q.Complete([]string{"b.go", "c.go"}))
+	assert.NoFileExists(t, queuePath, "queue file should be removed when all batches are done")
+}
+
+func TestQueue_Discard(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, ".memo"), 0755))
+
+	q, err := analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	require.NoError(t, q.Plan([][]string{{"a.go"}}))
+	require.NoError(t, q.Discard())
+
+	q, err = analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestAnalyse_ResumesIncompleteBatches(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	queuePath := filepath.Join(workDir, ".memo", "queue.json")
+
+	// First run: batch 2 fails
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": `{"batch": 2, "error": "killed"}`,
+	}))
+	ana.SetTokenBudget(twoBatchBudget)
+	q, err := analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	ana.SetQueue(q)
+	require.Error(t, ana.Analyse(context.Background(), files))
+	require.FileExists(t, queuePath)
+
+	// Second run resumes only batch 2
+	ana = newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "first", "description": "first half", "interfaces": ""}, {"name": "second", "description": "second half", "interfaces": ""}], "relationships": ""}}}`,
+	}))
+	q, err = analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	require.Len(t, q.Pending(), 1)
+	for _, f := range q.Pending()[0] {
+		assert.Contains(t, f, "b"+string(filepath.Separator), "only batch 2 (directory b) should be pending")
+	}
+	ana.SetQueue(q)
+	require.NoError(t, ana.Resume(context.Background()))
+
+	assert.NoFileExists(t, queuePath)
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "index", "arch.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"second"`)
+}
+
+func TestAnalyse_FoldsPendingBatches(t *testing.T) {
+	workDir := setupWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "other.go"), []byte("package main\n"), 0644))
+
+	q, err := analyzer.LoadQueue(workDir)
+	require.NoError(t, err)
+	require.NoError(t, q.Plan([][]string{{"other.go"}}))
+
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{"001.json": `{}`}))
+	ana.SetQueue(q)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	metas, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "meta.json"))
+	require.NoError(t, err)
+	require.Len(t, metas, 1)
+	data, err := os.ReadFile(metas[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "other.go", "pending files should be analysed with the new changes")
+	assert.Equal(t, 0, len(q.Pending()))
+}
@@ -0,0 +1,128 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordScriptedRun runs a scripted analysis with recording enabled and returns the recording dir
+func recordScriptedRun(t *testing.T, turns map[string]string) (string, error) {
+	t.Helper()
+	workDir := setupWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, turns))
+	ana.SetRecording(true)
+
+	runErr := ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")})
+
+	recordings, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*"))
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+	return recordings[0], runErr
+}
+
+func TestRecording_WritesTurnsAndDiff(t *testing.T) {
+	dir, err := recordScriptedRun(t, map[string]string{
+		"001.json": `{"text": "first pass", "files": {"arch.json": "{broken"}}`,
+		"002.json": `{"text": "fixed", "files": {"arch.json": ` + validArch + `}}`,
+	})
+	require.NoError(t, err)
+
+	for _, name := range []string{"meta.json", "turns/001.json", "turns/002.json", "index-before/arch.json", "index-after/arch.json"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "turns", "003.json"))
+
+	turn, err := os.ReadFile(filepath.Join(dir, "turns", "002.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(turn), `"kind": "feedback"`)
+	assert.Contains(t, string(turn), "Validation errors:")
+
+	diff, err := os.ReadFile(filepath.Join(dir, "diff.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(diff), "+++ b/arch.json")
+	assert.Contains(t, string(diff), "entry point")
+}
+
+func TestReplay_ReproducesRecording(t *testing.T) {
+	dir, err := recordScriptedRun(t, map[string]string{
+		"001.json": `{"files": {"arch.json": "{broken"}}`,
+		"002.json": `{"files": {"arch.json": ` + validArch + `, "stories.json": {"stories": []}}}`,
+	})
+	require.NoError(t, err)
+
+	result, err := analyzer.Replay(context.Background(), dir)
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Empty(t, result.Diff, "replay should reproduce the recorded index")
+	assert.Equal(t, 2, result.Meta.Turns)
+
+	data, err := os.ReadFile(filepath.Join(result.IndexDir, "arch.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, validArch, string(data))
+}
+
+func TestReplay_ChecksIssueLocationsInRecordedProject(t *testing.T) {
+	workDir, _ := setupRulesWorkDir(t)
+	issues := `{"issues": [{"tags": ["todo"], "title": "t", "description": "d", "locations": [{"file": "main.go", "keyword": "TODO", "line": 3}]}]}`
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"issues.json": ` + issues + `}}`,
+	}))
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+	recordings, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*"))
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+
+	result, err := analyzer.Replay(context.Background(), recordings[0])
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Empty(t, result.Diff)
+	assert.Equal(t, workDir, result.Meta.WorkDir)
+}
+
+func TestReplay_UsesRecordedIndexFiles(t *testing.T) {
+	workDir := setupWorkDir(t)
+	files, err := analyzer.NewIndexFiles([]analyzer.IndexFile{{Name: "decisions.json", Schema: decisionsSchema}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, ".memo", "index", "decisions.json"), []byte(files.Initial()["decisions.json"]), 0644))
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"decisions.json": {"decisions": [{"title": "x", "rationale": "y"}]}}}`,
+	}))
+	ana.SetIndexFiles(files)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+	recordings, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*"))
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+
+	result, err := analyzer.Replay(context.Background(), recordings[0])
+	require.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Empty(t, result.Diff)
+	require.Len(t, result.Meta.IndexFiles, 1)
+	assert.Equal(t, "decisions.json", result.Meta.IndexFiles[0].Name)
+}
+
+func TestReplay_ReproducesFailure(t *testing.T) {
+	dir, err := recordScriptedRun(t, map[string]string{
+		"001.json": `{"files": {"issues.json": "not json"}}`,
+	})
+	require.Error(t, err)
+
+	result, err := analyzer.Replay(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Error(t, result.Err, "replay should fail the same way")
+	assert.Empty(t, result.Diff)
+	assert.NotEmpty(t, result.Meta.Error)
+}
+
+func TestReplay_NotARecording(t *testing.T) {
+	_, err := analyzer.Replay(context.Background(), t.TempDir())
+	assert.Error(t, err)
+}
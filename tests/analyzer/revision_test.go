@@ -0,0 +1,24 @@
+package analyzer_test
+
+import (
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevision_SaveLoad(t *testing.T) {
+	workDir := setupWorkDir(t)
+
+	rev, err := analyzer.LoadRevision(workDir)
+	require.NoError(t, err)
+	assert.Nil(t, rev, "no commit recorded yet")
+
+	require.NoError(t, analyzer.SaveRevision(workDir, "0123abcd"))
+	rev, err = analyzer.LoadRevision(workDir)
+	require.NoError(t, err)
+	require.NotNil(t, rev)
+	assert.Equal(t, "0123abcd", rev.Commit)
+	assert.False(t, rev.Time.IsZero())
+}
@@ -0,0 +1,94 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRotator creates a rotator whose rebuilds replay rebuildTurns
+func newTestRotator(t *testing.T, workDir string, active *analyzer.Analyser, rebuildTurns map[string]string, stabilize time.Duration) *analyzer.Rotator {
+	t.Helper()
+	scriptDir := writeScript(t, rebuildTurns)
+	return analyzer.NewRotator(active,
+		func() (*analyzer.Analyser, error) {
+			return analyzer.NewAnalyser(analyzer.AgentConfig{Backend: analyzer.BackendScripted, ScriptDir: scriptDir}, workDir)
+		},
+		func() []string { return []string{filepath.Join(workDir, "main.go")} },
+		time.Hour, stabilize)
+}
+
+func readArch(t *testing.T, workDir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "index", "arch.json"))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotator_RebuildSwapsIndex(t *testing.T) {
+	workDir := setupWorkDir(t)
+	writeArch(t, workDir, `{"modules": [{"name": "stale", "description": "", "interfaces": ""}], "relationships": ""}`)
+	active := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{"001.json": `{}`}))
+
+	rot := newTestRotator(t, workDir, active, map[string]string{"001.json": moduleTurn(0, "fresh")}, 0)
+	require.NoError(t, rot.Rebuild(context.Background()))
+
+	arch := readArch(t, workDir)
+	assert.Contains(t, arch, `"fresh"`)
+	assert.NotContains(t, arch, `"stale"`)
+	assert.NoDirExists(t, filepath.Join(workDir, ".memo", "index-rebuild"))
+	assert.NotSame(t, active, rot.Active(), "rebuild analyser should become active")
+
+	// The provenance of the rebuild replaces that of the old index
+	assert.Equal(t, map[string][]string{"arch.json modules fresh": {"main.go"}}, loadProvenance(t, workDir))
+	assert.NoFileExists(t, filepath.Join(workDir, ".memo", "provenance-rebuild.json"))
+}
+
+func TestRotator_FailedRebuildKeepsIndex(t *testing.T) {
+	workDir := setupWorkDir(t)
+	writeArch(t, workDir, `{"modules": [{"name": "stale", "description": "", "interfaces": ""}], "relationships": ""}`)
+	active := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{"001.json": `{}`}))
+
+	rot := newTestRotator(t, workDir, active, map[string]string{"001.json": `{"error": "agent crashed"}`}, 0)
+	require.Error(t, rot.Rebuild(context.Background()))
+
+	assert.Contains(t, readArch(t, workDir), `"stale"`)
+	assert.NoDirExists(t, filepath.Join(workDir, ".memo", "index-rebuild"))
+	assert.Same(t, active, rot.Active())
+}
+
+func TestRotator_MirrorsChangesDuringRebuild(t *testing.T) {
+	workDir := setupWorkDir(t)
+	active := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"stories.json": {"stories": [{"title": "live", "tags": ["rebuild"], "content": "written by the rebuild"}]}}}`,
+	}))
+	rot := newTestRotator(t, workDir, active, map[string]string{
+		"001.json": moduleTurn(0, "fresh"),
+		"002.json": `{"files": {"stories.json": {"stories": [{"title": "mirrored", "tags": ["watch"], "content": "written during the rebuild"}]}}}`,
+	}, 300*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- rot.Rebuild(context.Background()) }()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(workDir, ".memo", "index-rebuild"))
+		return err == nil
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// A change during the rebuild reaches the active index and the rebuild
+	require.NoError(t, rot.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "index", "stories.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"live"`)
+
+	require.NoError(t, <-done)
+	data, err = os.ReadFile(filepath.Join(workDir, ".memo", "index", "stories.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"mirrored"`, "swapped-in index should include the mirrored change")
+	assert.Contains(t, readArch(t, workDir), `"fresh"`)
+}
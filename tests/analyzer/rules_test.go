@@ -0,0 +1,166 @@
+package analyzer_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRulesWorkDir creates a work directory with a schema-valid index and a
+// source file whose line 3 holds a TODO
+func setupRulesWorkDir(t *testing.T) (string, string) {
+	t.Helper()
+	workDir := setupWorkDir(t)
+	source := "package main\n\n// TODO: handle errors\nfunc main() {}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "main.go"), []byte(source), 0644))
+	return workDir, filepath.Join(workDir, ".memo", "index")
+}
+
+func writeIssue(t *testing.T, indexDir, file, keyword string, line int) {
+	t.Helper()
+	issues := fmt.Sprintf(`{"issues": [{"tags": ["todo"], "title": "t", "description": "d", "locations": [{"file": %q, "keyword": %q, "line": %d}]}]}`, file, keyword, line)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "issues.json"), []byte(issues), 0644))
+}
+
+func TestValidateSemantics_Valid(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(validArch), 0644))
+	writeIssue(t, indexDir, "main.go", "TODO", 3)
+
+	result := analyzer.ValidateSemantics(indexDir, workDir)
+	assert.True(t, result.Valid, "%v", result.Errors)
+
+	// Line numbers may drift a little
+	writeIssue(t, indexDir, "main.go", "TODO", 1)
+	assert.True(t, analyzer.ValidateSemantics(indexDir, workDir).Valid)
+}
+
+func TestValidateSemantics_DuplicateNames(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(`{"modules": [
+		{"name": "core", "description": "a", "interfaces": ""},
+		{"name": "cli", "description": "b", "interfaces": ""},
+		{"name": "core", "description": "c", "interfaces": ""}
+	], "relationships": ""}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "interface.json"), []byte(`{
+		"external": [{"type": "cli", "name": "scan", "params": "", "description": "scans the project"}],
+		"internal": [
+			{"type": "func", "name": "Run", "params": "", "description": "runs a batch"},
+			{"type": "func", "name": "Run", "params": "", "description": "runs a batch"}
+		]
+	}`), 0644))
+
+	result := analyzer.ValidateSemantics(indexDir, workDir)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 2)
+	assert.Contains(t, result.Errors[0], `modules[2]: duplicate module name "core" (also modules[0])`)
+	assert.Contains(t, result.Errors[1], `internal[1]: duplicate interface name "Run"`)
+}
+
+func TestValidateSemantics_IssueLocations(t *testing.T) {
+	tests := []struct {
+		name, file, keyword string
+		line                int
+		want                string
+	}{
+		{"missing file", "gone.go", "TODO", 3, `file "gone.go" does not exist`},
+		{"line past the end", "main.go", "TODO", 9, "line 9 is outside main.go, which has 4 lines"},
+		{"keyword not near line", "main.go", "handleRequest", 3, `keyword "handleRequest" does not appear`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir, indexDir := setupRulesWorkDir(t)
+			writeIssue(t, indexDir, tt.file, tt.keyword, tt.line)
+
+			result := analyzer.ValidateSemantics(indexDir, workDir)
+			assert.False(t, result.Valid)
+			require.Len(t, result.Errors, 1)
+			assert.Contains(t, result.Errors[0], "issues.json: issues[0].locations[0]: "+tt.want)
+		})
+	}
+}
+
+func TestValidateSemantics_EmptyDescriptions(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(`{"modules": [
+		{"name": "core", "description": " ", "interfaces": "", "internal": {"submodules": [{"name": "sub", "description": "", "interfaces": ""}], "relationships": ""}}
+	], "relationships": ""}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "interface.json"), []byte(`{
+		"external": [{"type": "cli", "name": "scan", "params": "", "description": ""}],
+		"internal": []
+	}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "stories.json"), []byte(`{"stories": [{"title": "s", "tags": ["flow"], "content": ""}]}`), 0644))
+	issues := `{"issues": [{"tags": ["todo"], "title": "t", "description": "", "locations": [{"file": "main.go", "keyword": "TODO", "line": 3}]}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "issues.json"), []byte(issues), 0644))
+
+	result := analyzer.ValidateSemantics(indexDir, workDir)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 5, "%v", result.Errors)
+	assert.Contains(t, result.Errors[0], "arch.json: modules[0]: empty description")
+	assert.Contains(t, result.Errors[1], "arch.json: modules[0].internal.submodules[0]: empty description")
+	assert.Contains(t, result.Errors[2], "interface.json: external[0]: empty description")
+	assert.Contains(t, result.Errors[3], "stories.json: stories[0]: empty content")
+	assert.Contains(t, result.Errors[4], "issues.json: issues[0]: empty description")
+}
+
+func TestValidateSemantics_StoryTags(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "stories.json"), []byte(`{"stories": [
+		{"title": "tagged", "tags": ["flow"], "content": "c"},
+		{"title": "untagged", "tags": [], "content": "c"},
+		{"title": "blank", "tags": [""], "content": "c"}
+	]}`), 0644))
+
+	result := analyzer.ValidateSemantics(indexDir, workDir)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 2)
+	assert.Contains(t, result.Errors[0], "stories.json: stories[1]: no tags")
+	assert.Contains(t, result.Errors[1], "stories.json: stories[2]: no tags")
+}
+
+func TestAnalyse_SemanticFeedback(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	issue := func(file string) string {
+		return `{"issues": [{"tags": ["todo"], "title": "t", "description": "d", "locations": [{"file": "` + file + `", "keyword": "TODO", "line": 3}]}]}`
+	}
+	scriptDir := writeScript(t, map[string]string{
+		"001.json": `{"files": {"issues.json": ` + issue("src/main.go") + `}}`,
+		"002.json": `{"files": {"issues.json": ` + issue("main.go") + `}}`,
+	})
+
+	ana := newScriptedAnalyser(t, workDir, scriptDir)
+	ana.SetRecording(true)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	turns, err := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "turns", "002.json"))
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+	feedback, err := os.ReadFile(turns[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(feedback), `file \"src/main.go\" does not exist`)
+	assert.True(t, analyzer.ValidateSemantics(indexDir, workDir).Valid)
+}
+
+func TestAnalyse_SemanticFeedbackSkipsUntouchedEntries(t *testing.T) {
+	workDir, indexDir := setupRulesWorkDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "other.go"), []byte("package main\n"), 0644))
+	// Broken before the batch, in a file outside it
+	writeIssue(t, indexDir, "gone.go", "TODO", 3)
+	scriptDir := writeScript(t, map[string]string{
+		"001.json": `{"files": {"stories.json": {"stories": [{"title": "other", "tags": ["flow"], "content": "other.go is empty"}]}}}`,
+	})
+
+	ana := newScriptedAnalyser(t, workDir, scriptDir)
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "other.go")}))
+
+	// memo lint still checks the whole index
+	result := analyzer.ValidateSemantics(indexDir, workDir)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0], `file "gone.go" does not exist`)
+}
@@ -0,0 +1,215 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeArch(t *testing.T, workDir, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, ".memo", "index", "arch.json"), []byte(content), 0644))
+}
+
+func TestSnapshots_SaveAndRestore(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	store := analyzer.OpenSnapshots(workDir, 0)
+
+	first, err := store.Save(indexDir, []string{"main.go"}, "")
+	require.NoError(t, err)
+	require.NotNil(t, first)
+
+	// Unchanged index adds no snapshot
+	again, err := store.Save(indexDir, []string{"main.go"}, "")
+	require.NoError(t, err)
+	assert.Nil(t, again)
+
+	writeArch(t, workDir, `{"modules": [{"name": "changed", "description": "", "interfaces": ""}], "relationships": ""}`)
+	second, err := store.Save(indexDir, []string{"other.go"}, "")
+	require.NoError(t, err)
+	require.NotNil(t, second)
+
+	log, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	assert.Equal(t, first.ID, log[0].ID)
+	assert.Equal(t, []string{"other.go"}, log[1].Files)
+
+	snap, prev, err := store.Find(second.ID[:4])
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, snap.ID)
+	require.NotNil(t, prev)
+	assert.Equal(t, first.ID, prev.ID)
+
+	require.NoError(t, store.Restore(first.ID, indexDir))
+	data, err := os.ReadFile(filepath.Join(indexDir, "arch.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "changed")
+}
+
+func TestSnapshots_RestoreProvenance(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	sidecar := filepath.Join(workDir, ".memo", "provenance.json")
+	store := analyzer.OpenSnapshots(workDir, 0)
+
+	bare, err := store.Save(indexDir, nil, "")
+	require.NoError(t, err)
+	require.NotNil(t, bare)
+
+	writeArch(t, workDir, `{"modules": [{"name": "first", "description": "d", "interfaces": ""}], "relationships": ""}`)
+	require.NoError(t, os.WriteFile(sidecar, []byte(`{"entries": ["first"]}`), 0644))
+	first, err := store.Save(indexDir, nil, "")
+	require.NoError(t, err)
+	require.NotNil(t, first)
+
+	writeArch(t, workDir, `{"modules": [{"name": "second", "description": "d", "interfaces": ""}], "relationships": ""}`)
+	require.NoError(t, os.WriteFile(sidecar, []byte(`{"entries": ["second"]}`), 0644))
+
+	require.NoError(t, store.Restore(first.ID, indexDir))
+	data, err := os.ReadFile(sidecar)
+	require.NoError(t, err)
+	assert.Equal(t, `{"entries": ["first"]}`, string(data))
+
+	require.NoError(t, store.Restore(bare.ID, indexDir))
+	assert.NoFileExists(t, sidecar, "a snapshot without provenance leaves none")
+}
+
+func TestSnapshots_FindErrors(t *testing.T) {
+	workDir := setupWorkDir(t)
+	store := analyzer.OpenSnapshots(workDir, 0)
+	_, err := store.Save(filepath.Join(workDir, ".memo", "index"), nil, "")
+	require.NoError(t, err)
+
+	_, _, err = store.Find("zzzz")
+	assert.ErrorContains(t, err, "not found")
+	_, _, err = store.Find("")
+	assert.Error(t, err)
+}
+
+func TestSnapshots_Retention(t *testing.T) {
+	workDir := setupWorkDir(t)
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	store := analyzer.OpenSnapshots(workDir, 2)
+
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		writeArch(t, workDir, `{"modules": [{"name": "`+name+`", "description": "", "interfaces": ""}], "relationships": ""}`)
+		snap, err := store.Save(indexDir, nil, "")
+		require.NoError(t, err)
+		require.NotNil(t, snap)
+		ids = append(ids, snap.ID)
+	}
+
+	log, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	assert.Equal(t, ids[1], log[0].ID)
+	assert.NoFileExists(t, filepath.Join(workDir, ".memo", "snapshots", ids[0]+".tar.gz"))
+}
+
+func TestSnapshots_RecordBranch(t *testing.T) {
+	workDir := setupWorkDir(t)
+	memoDir := filepath.Join(workDir, ".memo")
+	store := analyzer.OpenSnapshots(workDir, 0)
+	setBranch := func(branch string) {
+		require.NoError(t, os.WriteFile(filepath.Join(memoDir, "branch.json"), []byte(`{"branch": "`+branch+`"}`), 0644))
+	}
+
+	setBranch("main")
+	first, err := store.Save(filepath.Join(memoDir, "index"), nil, "")
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.Equal(t, "main", first.Branch)
+
+	// The same index on another branch is a version of that branch
+	setBranch("feature")
+	second, err := store.Save(filepath.Join(memoDir, "index"), nil, "")
+	require.NoError(t, err)
+	require.NotNil(t, second)
+	assert.Equal(t, "feature", second.Branch)
+}
+
+func TestSnapshots_FindPreviousOnSameBranch(t *testing.T) {
+	workDir := setupWorkDir(t)
+	memoDir := filepath.Join(workDir, ".memo")
+	indexDir := filepath.Join(memoDir, "index")
+	store := analyzer.OpenSnapshots(workDir, 0)
+	save := func(branch, module string) *analyzer.Snapshot {
+		require.NoError(t, os.WriteFile(filepath.Join(memoDir, "branch.json"), []byte(`{"branch": "`+branch+`"}`), 0644))
+		writeArch(t, workDir, `{"modules": [{"name": "`+module+`", "description": "d", "interfaces": ""}], "relationships": ""}`)
+		snap, err := store.Save(indexDir, nil, "")
+		require.NoError(t, err)
+		require.NotNil(t, snap)
+		return snap
+	}
+
+	main1 := save("main", "m1")
+	feature1 := save("feature", "f1")
+	main2 := save("main", "m2")
+	feature2 := save("feature", "f2")
+
+	for _, tt := range []struct{ snap, want *analyzer.Snapshot }{
+		{main1, nil},
+		{feature1, nil},
+		{main2, main1},
+		{feature2, feature1},
+	} {
+		_, prev, err := store.Find(tt.snap.ID)
+		require.NoError(t, err)
+		if tt.want == nil {
+			assert.Nil(t, prev, tt.snap.Branch)
+		} else {
+			require.NotNil(t, prev)
+			assert.Equal(t, tt.want.ID, prev.ID, tt.snap.Branch)
+		}
+	}
+}
+
+func TestAnalyse_SavesSnapshot(t *testing.T) {
+	workDir := setupWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(0, "first"),
+	}))
+	store := analyzer.OpenSnapshots(workDir, 0)
+	ana.SetSnapshots(store)
+
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	log, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	assert.Equal(t, []string{"main.go"}, log[0].Files)
+	files, err := store.Files(log[0].ID)
+	require.NoError(t, err)
+	assert.Contains(t, files["arch.json"], `"first"`)
+}
+
+func TestAnalyse_SnapshotsBatchesCommittedBeforeFailure(t *testing.T) {
+	workDir, files := setupTwoBatchWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": moduleTurn(1, "first"),
+		"002.json": `{"batch": 2, "error": "agent crashed"}`,
+	}))
+	ana.SetTokenBudget(twoBatchBudget)
+	store := analyzer.OpenSnapshots(workDir, 0)
+	ana.SetSnapshots(store)
+
+	err := ana.Analyse(context.Background(), files)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "batch 2/2")
+
+	log, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, log, 1, "the committed batch should be snapshotted")
+	assert.Equal(t, []string{"a/f0.go", "a/f1.go", "a/f2.go"}, log[0].Files)
+	snap, err := store.Files(log[0].ID)
+	require.NoError(t, err)
+	assert.Contains(t, snap["arch.json"], `"first"`)
+}
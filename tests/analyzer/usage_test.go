@@ -0,0 +1,85 @@
+package analyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/YoungY620/memo/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyse_RecordsUsage(t *testing.T) {
+	workDir := setupWorkDir(t)
+	ana := newScriptedAnalyser(t, workDir, writeScript(t, map[string]string{
+		"001.json": `{"files": {"arch.json": "{broken"}, "usage": {"input": 1000, "output": 200}}`,
+		"002.json": `{"files": {"arch.json": ` + validArch + `}, "usage": {"input": 300, "output": 50}}`,
+	}))
+	require.NoError(t, ana.Analyse(context.Background(), []string{filepath.Join(workDir, "main.go")}))
+
+	records, err := analyzer.ReadUsage(workDir)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	batch, run := records[0], records[1]
+	assert.Equal(t, analyzer.UsageBatch, batch.Kind)
+	assert.Equal(t, 1, batch.Batch)
+	assert.Equal(t, analyzer.UsageRun, run.Kind)
+	assert.Equal(t, batch.Run, run.Run)
+	assert.Equal(t, analyzer.BackendScripted, run.Backend)
+
+	for _, rec := range records {
+		assert.Equal(t, analyzer.TokenUsage{Prompts: 1, Input: 1000, Output: 200}, rec.Initial)
+		assert.Equal(t, analyzer.TokenUsage{Prompts: 1, Input: 300, Output: 50}, rec.Feedback)
+	}
+}
+
+func TestReadUsage_SkipsInvalidLines(t *testing.T) {
+	workDir := setupWorkDir(t)
+	writeUsage(t, workDir, time.Now().Add(-time.Minute), 100)
+	f, err := os.OpenFile(filepath.Join(workDir, ".memo", "usage.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time": "2025-01-01T00:00:00Z", "kind": "ru` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	writeUsage(t, workDir, time.Now(), 200)
+
+	records, err := analyzer.ReadUsage(workDir)
+	require.NoError(t, err)
+	require.Len(t, records, 2, "the truncated line is skipped")
+	assert.Equal(t, 100, records[0].Initial.Input)
+	assert.Equal(t, 200, records[1].Initial.Input)
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	day1 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	records := []analyzer.UsageRecord{
+		{Time: day1, Kind: analyzer.UsageRun, Backend: "openai", Model: "gpt-4o",
+			Initial: analyzer.TokenUsage{Prompts: 2, Input: 100, Output: 10}, Feedback: analyzer.TokenUsage{Prompts: 1, Input: 20, Output: 5}},
+		{Time: day1, Kind: analyzer.UsageBatch, Backend: "openai", Model: "gpt-4o",
+			Initial: analyzer.TokenUsage{Prompts: 1, Input: 50, Output: 5}}, // included in the run above
+		{Time: day1, Kind: analyzer.UsageRun, Backend: "openai", Model: "gpt-4o",
+			Initial: analyzer.TokenUsage{Prompts: 1, Input: 40, Output: 4}},
+		{Time: day2, Kind: analyzer.UsageRun, Backend: "kimi",
+			Initial: analyzer.TokenUsage{Prompts: 1, Input: 7, Output: 1}},
+	}
+
+	summary := analyzer.SummarizeUsage(records)
+	require.Len(t, summary, 3)
+
+	assert.Equal(t, "2025-01-01", summary[0].Day)
+	assert.Equal(t, "gpt-4o", summary[0].Model)
+	assert.Equal(t, "initial", summary[0].Trigger)
+	assert.Equal(t, 2, summary[0].Runs)
+	assert.Equal(t, analyzer.TokenUsage{Prompts: 3, Input: 140, Output: 14}, summary[0].TokenUsage)
+
+	assert.Equal(t, "feedback", summary[1].Trigger)
+	assert.Equal(t, 25, summary[1].Total())
+
+	assert.Equal(t, "2025-01-02", summary[2].Day)
+	assert.Equal(t, "kimi default", summary[2].Model)
+}
@@ -72,6 +72,39 @@ func TestWatcher_ScanAll(t *testing.T) {
 	}
 }
 
+func TestWatcher_ScanChanged(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, ".memo"), 0755))
+	unchanged := filepath.Join(tmpDir, "unchanged.txt")
+	changed := filepath.Join(tmpDir, "changed.txt")
+	require.NoError(t, os.WriteFile(unchanged, []byte("same"), 0644))
+	require.NoError(t, os.WriteFile(changed, []byte("before"), 0644))
+
+	manifest, err := analyzer.LoadManifest(tmpDir)
+	require.NoError(t, err)
+	manifest.Update([]string{"unchanged.txt", "changed.txt"})
+	require.NoError(t, os.WriteFile(changed, []byte("after!"), 0644))
+
+	var mu sync.Mutex
+	var receivedFiles []string
+	onChange := func(files []string) {
+		mu.Lock()
+		receivedFiles = files
+		mu.Unlock()
+	}
+
+	watcher, err := analyzer.NewWatcher(tmpDir, []string{".memo"}, 50, 200, onChange)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	watcher.ScanChanged(manifest)
+	watcher.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{changed}, receivedFiles)
+}
+
 func TestWatcher_IgnorePatterns(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -5,6 +5,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -123,6 +124,36 @@ func TestInitIndex(t *testing.T) {
 	}
 }
 
+func TestGitignoreUpdated(t *testing.T) {
+	binary := buildBinary(t)
+	tmpDir := t.TempDir()
+
+	// A .gitignore written by an older memo, without a final newline
+	gitignorePath := filepath.Join(tmpDir, ".memo", ".gitignore")
+	if err := os.MkdirAll(filepath.Dir(gitignorePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(gitignorePath, []byte("# Runtime files - do not commit\nwatcher.lock\nstatus.json\nmine/"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "scan", "-p", tmpDir, "-c", "nonexistent.yaml")
+	_ = cmd.Run()
+
+	data, err := os.ReadFile(gitignorePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, want := range []string{"mine/", "recordings/", "objects/", "provenance.json"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("Expected %s in .gitignore:\n%s", want, data)
+		}
+	}
+	if strings.Count(string(data), "watcher.lock") != 1 {
+		t.Errorf("Listed entries should not be repeated:\n%s", data)
+	}
+}
+
 func TestLockFile(t *testing.T) {
 	// Skip on CI if running in parallel
 	if os.Getenv("CI") != "" {
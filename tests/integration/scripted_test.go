@@ -0,0 +1,707 @@
+package integration_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupScriptedProject creates a project and a script directory for the
+// scripted backend, returning (workDir, scriptDir).
+func setupScriptedProject(t *testing.T, turns map[string]string) (string, string) {
+	t.Helper()
+
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	scriptDir := t.TempDir()
+	for name, content := range turns {
+		if err := os.WriteFile(filepath.Join(scriptDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write turn %s: %v", name, err)
+		}
+	}
+	return workDir, scriptDir
+}
+
+// scriptedEnv returns the environment selecting the scripted backend
+func scriptedEnv(scriptDir string) []string {
+	return append(os.Environ(), "MEMO_AGENT_BACKEND=scripted", "MEMO_SCRIPT_DIR="+scriptDir)
+}
+
+// mcpGetValue starts `memo mcp` and queries a single path
+func mcpGetValue(t *testing.T, binary, workDir, path string, flags ...string) string {
+	t.Helper()
+
+	cmd := exec.Command(binary, append([]string{"mcp", "-p", workDir}, flags...)...)
+	stdin, _ := cmd.StdinPipe()
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start MCP server: %v", err)
+	}
+	defer func() { _ = cmd.Process.Kill() }()
+
+	args, _ := json.Marshal(map[string]any{"name": "memo_get_value", "arguments": map[string]string{"path": path}})
+	req := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": ` + string(args) + "}\n"
+	_, _ = stdin.Write([]byte(req))
+
+	line, err := bufio.NewReader(stdout).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read MCP response: %v", err)
+	}
+
+	var resp struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("Failed to parse MCP response: %v", err)
+	}
+	if resp.Result.IsError || len(resp.Result.Content) == 0 {
+		t.Fatalf("MCP query failed: %s", line)
+	}
+	return resp.Result.Content[0].Text
+}
+
+func TestScriptedScan_EndToEnd(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		// First turn writes an invalid index to exercise the validation feedback loop
+		"001.json": `{"text": "first pass", "files": {"arch.json": {"modules": [{"name": "main"}], "relationships": ""}}}`,
+		"002.json": `{"text": "fixed", "files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+
+	cmd := exec.Command(binary, "scan", "-p", workDir, "-c", "nonexistent.yaml")
+	cmd.Env = scriptedEnv(scriptDir)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Scan failed: %v\n%s", err, output)
+	}
+	if strings.Contains(string(output), "Analysis failed") {
+		t.Fatalf("Analysis should succeed after feedback turn:\n%s", output)
+	}
+
+	value := mcpGetValue(t, binary, workDir, "[arch][modules][0][description]")
+	if !strings.Contains(value, "program entry point") {
+		t.Errorf("MCP should serve the scripted index, got: %s", value)
+	}
+
+	output, err = exec.Command(binary, "usage", "-p", workDir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Usage failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "scripted default  initial") || !strings.Contains(string(output), "scripted default  feedback") {
+		t.Errorf("Usage should report initial and feedback prompts:\n%s", output)
+	}
+}
+
+func TestScriptedScan_SkipsUnchangedFiles(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+
+	scan := func(args ...string) string {
+		cmd := exec.Command(binary, append([]string{"scan", "-p", workDir, "-c", "nonexistent.yaml"}, args...)...)
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Scan failed: %v\n%s", err, output)
+		}
+		return string(output)
+	}
+
+	output := scan()
+	if !strings.Contains(output, "Triggered with 1 changed files") {
+		t.Fatalf("First scan should analyse main.go:\n%s", output)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".memo", "manifest.json")); err != nil {
+		t.Fatalf("Manifest should be written: %v", err)
+	}
+
+	output = scan()
+	if strings.Contains(output, "Triggered") {
+		t.Errorf("Second scan should skip unchanged files:\n%s", output)
+	}
+
+	output = scan("--full")
+	if !strings.Contains(output, "Triggered with 1 changed files") {
+		t.Errorf("--full should analyse all files:\n%s", output)
+	}
+}
+
+func TestScriptedScan_ResumesQueue(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"stories.json": {"stories": [{"title": "Resumed", "tags": ["resume"], "content": "the interrupted batch ran again"}]}}}`,
+	})
+	memoDir := filepath.Join(workDir, ".memo")
+	if err := os.MkdirAll(memoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	queue := `{"created_at": "2025-01-01T00:00:00Z", "batches": [{"files": ["done.go"], "done": true}, {"files": ["main.go"], "done": false}]}`
+
+	scan := func(args ...string) string {
+		if err := os.WriteFile(filepath.Join(memoDir, "queue.json"), []byte(queue), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(binary, append([]string{"scan", "-p", workDir, "-c", "nonexistent.yaml"}, args...)...)
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Scan failed: %v\n%s", err, output)
+		}
+		return string(output)
+	}
+
+	output := scan("--restart")
+	if !strings.Contains(output, "Discarding previous batch plan") || strings.Contains(output, "Resuming") {
+		t.Errorf("--restart should discard the queue:\n%s", output)
+	}
+
+	output = scan()
+	if !strings.Contains(output, "Resuming 1 of 2 batch(es)") {
+		t.Errorf("Scan should resume the pending batch:\n%s", output)
+	}
+	if _, err := os.Stat(filepath.Join(memoDir, "queue.json")); !os.IsNotExist(err) {
+		t.Errorf("Queue should be removed after the resumed run completes")
+	}
+}
+
+func TestScriptedScan_LogShowRollback(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, firstScript := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "first", "description": "first snapshot", "interfaces": ""}], "relationships": ""}}}`,
+	})
+	_, secondScript := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "second", "description": "second snapshot", "interfaces": ""}], "relationships": ""}}}`,
+	})
+
+	run := func(scriptDir string, args ...string) string {
+		cmd := exec.Command(binary, append(args, "-p", workDir)...)
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+
+	run(firstScript, "scan", "-c", "nonexistent.yaml")
+	run(secondScript, "scan", "-c", "nonexistent.yaml", "--full")
+
+	lines := strings.Split(strings.TrimSpace(run(firstScript, "log")), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 snapshots, got:\n%s", strings.Join(lines, "\n"))
+	}
+	newest, oldest := strings.Fields(lines[0])[0], strings.Fields(lines[1])[0]
+
+	output := run(firstScript, "show", newest[:6])
+	if !strings.Contains(output, "main.go") || !strings.Contains(output, `-{"modules": [{"name": "first"`) || !strings.Contains(output, `+{"modules": [{"name": "second"`) {
+		t.Errorf("show should list analysed files and the index diff:\n%s", output)
+	}
+
+	run(firstScript, "rollback", oldest, "-c", "nonexistent.yaml")
+	if got := mcpGetValue(t, binary, workDir, "[arch][modules][0][name]"); !strings.Contains(got, "first") {
+		t.Errorf("Rollback should restore the first index, got %s", got)
+	}
+	if output := run(firstScript, "log"); !strings.Contains(output, "rollback to "+oldest) {
+		t.Errorf("Rollback should be recorded as a snapshot:\n%s", output)
+	}
+}
+
+func TestScriptedScan_CustomIndexFile(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"decisions.json": {"decisions": [{"title": "Use JSON", "rationale": "readable"}]}}}`,
+	})
+
+	schema := `{"type": "object", "properties": {"decisions": {"type": "array", "items": {"type": "object", "required": ["title", "rationale"]}}}, "required": ["decisions"]}`
+	if err := os.WriteFile(filepath.Join(workDir, "decisions.schema.json"), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(workDir, "memo.yaml")
+	config := "index_files:\n  - name: decisions.json\n    schema: decisions.schema.json\n    prompt: Architecture decisions and their rationale\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(binary, "scan", "-p", workDir, "-c", configPath, "--record")
+	cmd.Env = scriptedEnv(scriptDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("scan failed: %v\n%s", err, output)
+	}
+
+	turns, _ := filepath.Glob(filepath.Join(workDir, ".memo", "recordings", "*", "turns", "001.json"))
+	if len(turns) != 1 {
+		t.Fatalf("Expected one recorded turn, got %v", turns)
+	}
+	if data, _ := os.ReadFile(turns[0]); !strings.Contains(string(data), "Architecture decisions and their rationale") {
+		t.Error("Prompt should describe the custom index file")
+	}
+
+	if got := mcpGetValue(t, binary, workDir, "[decisions][decisions][0][title]", "-c", configPath); !strings.Contains(got, "Use JSON") {
+		t.Errorf("Custom index file should be queryable, got %s", got)
+	}
+}
+
+func TestMigrate_OldIndex(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, _ := setupScriptedProject(t, nil)
+
+	// An index written before meta.json existed, missing interface.json
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"arch.json":    `{"modules": [], "relationships": ""}`,
+		"stories.json": `{"stories": []}`,
+		"issues.json":  `{"issues": []}`,
+	} {
+		if err := os.WriteFile(filepath.Join(indexDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(args ...string) string {
+		cmd := exec.Command(binary, append(args, "-p", workDir, "-c", "nonexistent.yaml")...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+
+	output := run("migrate", "--dry-run")
+	if !strings.Contains(output, "Would migrate index from version 0 to 1") || !strings.Contains(output, "+++ b/interface.json") {
+		t.Errorf("Dry run should show the migration and its diff:\n%s", output)
+	}
+	if _, err := os.Stat(filepath.Join(indexDir, "meta.json")); !os.IsNotExist(err) {
+		t.Error("Dry run must not modify the index")
+	}
+
+	run("migrate")
+	if data, err := os.ReadFile(filepath.Join(indexDir, "meta.json")); err != nil || !strings.Contains(string(data), `"version":1`) {
+		t.Errorf("meta.json should record version 1, got %s (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(indexDir, "interface.json")); err != nil {
+		t.Errorf("Migration should create interface.json: %v", err)
+	}
+	if output := run("migrate"); !strings.Contains(output, "up to date") {
+		t.Errorf("Second migrate should be a no-op:\n%s", output)
+	}
+}
+
+func TestScriptedScan_GitRevisions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = workDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	scan := func(args ...string) string {
+		cmd := exec.Command(binary, append([]string{"scan", "-p", workDir, "-c", "nonexistent.yaml"}, args...)...)
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Scan failed: %v\n%s", err, output)
+		}
+		return string(output)
+	}
+
+	git("init", "-q")
+	git("add", "main.go")
+	git("commit", "-q", "-m", "initial")
+	first := git("rev-parse", "HEAD")
+
+	// --incremental without a recorded commit falls back to a normal scan
+	output := scan("--incremental")
+	if !strings.Contains(output, "Triggered with 1 changed files") {
+		t.Fatalf("First scan should analyse main.go:\n%s", output)
+	}
+	data, err := os.ReadFile(filepath.Join(workDir, ".memo", "revision.json"))
+	if err != nil || !strings.Contains(string(data), first) {
+		t.Fatalf("revision.json should record %s, got %s (%v)", first, data, err)
+	}
+
+	// Rename main.go and add util.go
+	git("mv", "main.go", "app.go")
+	if err := os.WriteFile(filepath.Join(workDir, "util.go"), []byte("package main\n\nfunc util() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "util.go")
+	git("commit", "-q", "-m", "second")
+	second := git("rev-parse", "HEAD")
+
+	output = scan("--incremental")
+	if !strings.Contains(output, "Triggered with 3 changed files") {
+		t.Errorf("Incremental scan should analyse the rename and the new file:\n%s", output)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, ".memo", "revision.json")); !strings.Contains(string(data), second) {
+		t.Errorf("revision.json should record %s, got %s", second, data)
+	}
+	if output := scan("--incremental"); strings.Contains(output, "Triggered") {
+		t.Errorf("Nothing changed since the recorded commit:\n%s", output)
+	}
+
+	output = scan("--rev-range", first+".."+second)
+	if !strings.Contains(output, "Triggered with 3 changed files") {
+		t.Errorf("--rev-range should analyse the files changed in the range:\n%s", output)
+	}
+	output = scan("--since", first)
+	if !strings.Contains(output, "Triggered with 3 changed files") {
+		t.Errorf("--since should analyse the files changed since the revision:\n%s", output)
+	}
+
+	cmd := exec.Command(binary, "scan", "-p", workDir, "-c", "nonexistent.yaml", "--since", "no-such-branch")
+	cmd.Env = scriptedEnv(scriptDir)
+	if output, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(output), "unknown revision") {
+		t.Errorf("Unknown revision should fail the scan: %v\n%s", err, output)
+	}
+}
+
+func TestHooks_CommitTriggersScan(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = workDir
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	memo := func(args ...string) string {
+		cmd := exec.Command(binary, append(args, "-p", workDir)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+
+	git("init", "-q")
+	hooksDir := filepath.Join(workDir, ".git", "hooks")
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// An existing hook is kept
+	if err := os.WriteFile(filepath.Join(hooksDir, "post-merge"), []byte("#!/bin/sh\necho merged\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	memo("hooks", "install", "-c", filepath.Join(workDir, "nonexistent.yaml"))
+	for _, hook := range []string{"post-commit", "post-merge", "post-checkout"} {
+		data, err := os.ReadFile(filepath.Join(hooksDir, hook))
+		if err != nil || !strings.Contains(string(data), "hooks run "+hook) {
+			t.Fatalf("%s should run memo: %s (%v)", hook, data, err)
+		}
+	}
+
+	git("add", "main.go")
+	git("commit", "-q", "-m", "initial")
+	head := git("rev-parse", "HEAD")
+
+	// The commit returns at once; the background scan records the commit
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		data, _ := os.ReadFile(filepath.Join(workDir, ".memo", "revision.json"))
+		if strings.Contains(string(data), head) {
+			break
+		}
+		if time.Now().After(deadline) {
+			log, _ := os.ReadFile(filepath.Join(workDir, ".memo", "hooks.log"))
+			t.Fatalf("Commit should trigger a scan recording %s, got %s\nhooks.log:\n%s", head, data, log)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".memo", "index", "arch.json")); err != nil {
+		t.Errorf("Scan should write the index: %v", err)
+	}
+
+	memo("hooks", "uninstall")
+	for _, hook := range []string{"post-commit", "post-checkout"} {
+		if _, err := os.Stat(filepath.Join(hooksDir, hook)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", hook)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(hooksDir, "post-merge")); string(data) != "#!/bin/sh\necho merged\n" {
+		t.Errorf("Existing hook should be restored, got:\n%s", data)
+	}
+}
+
+func TestScriptedScan_PerBranchIndex(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	binary := buildBinary(t)
+	archTurn := func(description string) map[string]string {
+		return map[string]string{
+			"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "` + description + `", "interfaces": "none"}], "relationships": ""}}}`,
+		}
+	}
+	workDir, mainScript := setupScriptedProject(t, archTurn("main branch"))
+	_, featureScript := setupScriptedProject(t, archTurn("feature branch"))
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte("branches:\n  enabled: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = workDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	scan := func(scriptDir string) string {
+		cmd := exec.Command(binary, "scan", "-p", workDir, "-c", config)
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Scan failed: %v\n%s", err, output)
+		}
+		return string(output)
+	}
+	description := func() string {
+		return mcpGetValue(t, binary, workDir, "[arch][modules][0][description]")
+	}
+
+	git("init", "-q")
+	git("add", "main.go")
+	git("commit", "-q", "-m", "initial")
+	mainBranch := git("symbolic-ref", "--short", "HEAD")
+	scan(mainScript)
+	if got := description(); !strings.Contains(got, "main branch") {
+		t.Fatalf("Index should describe the main branch, got %s", got)
+	}
+
+	// A new branch is seeded from main and only its changes are analysed
+	git("checkout", "-q", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc main() { feature() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("commit", "-q", "-am", "feature")
+	output := scan(featureScript)
+	if !strings.Contains(output, "seeding it from "+mainBranch) || !strings.Contains(output, "Triggered with 1 changed files") {
+		t.Errorf("Scan should seed the feature index from %s and analyse main.go:\n%s", mainBranch, output)
+	}
+	if got := description(); !strings.Contains(got, "feature branch") {
+		t.Errorf("Index should describe the feature branch, got %s", got)
+	}
+
+	// MCP follows HEAD even before the index is switched
+	git("checkout", "-q", mainBranch)
+	if got := description(); !strings.Contains(got, "main branch") {
+		t.Errorf("MCP should answer from the main branch's index, got %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".memo", "branches", mainBranch, "index", "arch.json")); err != nil {
+		t.Errorf("The main branch's index should be parked: %v", err)
+	}
+
+	// Switching back restores the main index and its manifest
+	output = scan(featureScript)
+	if strings.Contains(output, "Triggered") {
+		t.Errorf("Nothing changed since the main branch was analysed:\n%s", output)
+	}
+	if got := description(); !strings.Contains(got, "main branch") {
+		t.Errorf("Index should describe the main branch again, got %s", got)
+	}
+}
+
+func TestLint_Formats(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+	scan := exec.Command(binary, "scan", "-p", workDir, "-c", "nonexistent.yaml")
+	scan.Env = scriptedEnv(scriptDir)
+	if output, err := scan.CombinedOutput(); err != nil {
+		t.Fatalf("Scan failed: %v\n%s", err, output)
+	}
+	lint := func(config string, args ...string) (string, error) {
+		cmd := exec.Command(binary, append([]string{"lint", "-p", workDir, "-c", config}, args...)...)
+		output, err := cmd.Output()
+		return string(output), err
+	}
+
+	if output, err := lint("nonexistent.yaml"); err != nil || !strings.Contains(output, "No problems found") {
+		t.Fatalf("Analysed index should pass: %v\n%s", err, output)
+	}
+
+	arch := `{"modules": [{"name": "main", "description": "a", "interfaces": "none"}, {"name": "main", "description": "b", "interfaces": "none"}], "relationships": ""}`
+	if err := os.WriteFile(filepath.Join(workDir, ".memo", "index", "arch.json"), []byte(arch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := lint("nonexistent.yaml")
+	if err == nil || !strings.Contains(output, "arch.json: error: modules[1]: duplicate module name") {
+		t.Errorf("Duplicate module should fail the lint: %v\n%s", err, output)
+	}
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 || len(exitErr.Stderr) > 0 {
+		t.Errorf("A failing lint should exit 1 with only the report: %v\n%s", err, output)
+	}
+	output, _ = lint("nonexistent.yaml", "--format", "github")
+	if !strings.HasPrefix(output, "::error file=.memo/index/arch.json,title=memo lint%3A unique-module-names::") {
+		t.Errorf("Unexpected GitHub annotation:\n%s", output)
+	}
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte("lint:\n  rules:\n    unique-module-names: warning\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err = lint(config, "--format", "json")
+	if err != nil {
+		t.Fatalf("Warnings should not fail the lint: %v\n%s", err, output)
+	}
+	var report struct {
+		Errors, Warnings int
+	}
+	if err := json.Unmarshal([]byte(output), &report); err != nil || report.Errors != 0 || report.Warnings != 1 {
+		t.Errorf("Unexpected JSON report (%v):\n%s", err, output)
+	}
+}
+
+func TestCoverage_QueueUncovered(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+	if err := os.MkdirAll(filepath.Join(workDir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "lib", "util.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) string {
+		cmd := exec.Command(binary, append(args, "-p", workDir, "-c", "nonexistent.yaml")...)
+		cmd.Env = scriptedEnv(scriptDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	// Binaries are neither counted nor queued
+	if err := os.WriteFile(filepath.Join(workDir, "lib", "logo.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("scan")
+
+	output := run("coverage")
+	if !strings.Contains(output, "Coverage: 1 of 2 files (50.0%)") || !strings.Contains(output, "lib/ (1 files)") {
+		t.Errorf("Unexpected coverage report:\n%s", output)
+	}
+
+	cmd := exec.Command(binary, "coverage", "--format", "json", "-p", workDir, "-c", "nonexistent.yaml")
+	data, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("coverage --format json failed: %v", err)
+	}
+	var report struct {
+		UncoveredDirs []string `json:"uncovered_dirs"`
+		Percent       float64  `json:"percent"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil || report.Percent != 50 || len(report.UncoveredDirs) != 1 {
+		t.Errorf("Unexpected JSON report (%v):\n%s", err, data)
+	}
+
+	// Queued files are analysed again although they did not change
+	if output := run("coverage", "--queue"); !strings.Contains(output, "Queued 1 uncovered files") {
+		t.Errorf("Expected the uncovered file to be queued:\n%s", output)
+	}
+	if output := run("scan"); !strings.Contains(output, "Triggered with 1 changed files") {
+		t.Errorf("Scan should analyse the queued file:\n%s", output)
+	}
+}
+
+func TestScriptedScan_StaleEntries(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"arch.json": {"modules": [{"name": "main", "description": "program entry point", "interfaces": "none"}], "relationships": ""}}}`,
+	})
+	scan := exec.Command(binary, "scan", "-p", workDir, "-c", "nonexistent.yaml")
+	scan.Env = scriptedEnv(scriptDir)
+	if output, err := scan.CombinedOutput(); err != nil {
+		t.Fatalf("Scan failed: %v\n%s", err, output)
+	}
+	if data, err := os.ReadFile(filepath.Join(workDir, ".memo", "provenance.json")); err != nil || !strings.Contains(string(data), `"path": "main.go"`) {
+		t.Fatalf("provenance.json should attribute the module to main.go, got %s (%v)", data, err)
+	}
+
+	if result := mcpGetValue(t, binary, workDir, "[arch][modules][0]"); strings.Contains(result, "stale") {
+		t.Errorf("Fresh entry reported stale: %s", result)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc main() { run() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := mcpGetValue(t, binary, workDir, "[arch][modules][0][name]")
+	if !strings.Contains(result, `"stale":true`) || !strings.Contains(result, `"changed_sources":["main.go"]`) {
+		t.Errorf("Entry should be stale after main.go changed: %s", result)
+	}
+}
+
+func TestScriptedWatch_EndToEnd(t *testing.T) {
+	binary := buildBinary(t)
+	workDir, scriptDir := setupScriptedProject(t, map[string]string{
+		"001.json": `{"files": {"stories.json": {"stories": [{"title": "Startup", "tags": ["flow"], "content": "main runs"}]}}}`,
+	})
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := "watch:\n  debounce_ms: 100\n  max_wait_ms: 1000\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cmd := exec.Command(binary, "watch", "-p", workDir, "-c", configPath)
+	cmd.Env = scriptedEnv(scriptDir)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	storiesPath := filepath.Join(workDir, ".memo", "index", "stories.json")
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(storiesPath); err == nil && strings.Contains(string(data), "Startup") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	value := mcpGetValue(t, binary, workDir, "[stories][stories][0][title]")
+	if !strings.Contains(value, "Startup") {
+		t.Errorf("Watcher should have applied the scripted turn, got: %s", value)
+	}
+}
@@ -0,0 +1,35 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/YoungY620/memo/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff_Equal(t *testing.T) {
+	assert.Empty(t, internal.UnifiedDiff("a", "b", "x\ny\n", "x\ny\n"))
+	assert.Empty(t, internal.UnifiedDiff("a", "b", "x\ny", "x\ny\n"), "trailing newline only")
+}
+
+func TestUnifiedDiff_Change(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+
+	expected := "--- a/f\n+++ b/f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"
+	assert.Equal(t, expected, internal.UnifiedDiff("a/f", "b/f", from, to))
+}
+
+func TestUnifiedDiff_AddAndDeleteFile(t *testing.T) {
+	assert.Equal(t, "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+x\n+y\n", internal.UnifiedDiff("a/f", "b/f", "", "x\ny\n"))
+	assert.Equal(t, "--- a/f\n+++ b/f\n@@ -1 +0,0 @@\n-x\n", internal.UnifiedDiff("a/f", "b/f", "x\n", ""))
+}
+
+func TestUnifiedDiff_SeparateHunks(t *testing.T) {
+	from := "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n"
+	to := "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n"
+
+	diff := internal.UnifiedDiff("a/f", "b/f", from, to)
+	assert.Contains(t, diff, "@@ -1,4 +1,4 @@\n-a\n+A\n")
+	assert.Contains(t, diff, "@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n")
+}
@@ -0,0 +1,41 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "arch.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	require.NoError(t, internal.WriteFileAtomic(path, []byte("new"), 0644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files should be left behind")
+}
+
+func TestCopyDir(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.json"), []byte("a"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	require.NoError(t, internal.CopyDir(src, dst))
+
+	data, err := os.ReadFile(filepath.Join(dst, "a.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	assert.NoDirExists(t, filepath.Join(dst, "sub"), "subdirectories are not copied")
+}
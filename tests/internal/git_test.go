@@ -0,0 +1,87 @@
+package internal_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initGitRepo creates a repository with one commit of a.txt and b.txt
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha\nalpha\nalpha\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("beta\n"), 0644))
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, output)
+}
+
+func TestGitResolve(t *testing.T) {
+	dir := initGitRepo(t)
+
+	commit, err := internal.GitResolve(dir, "HEAD")
+	require.NoError(t, err)
+	assert.Len(t, commit, 40)
+
+	_, err = internal.GitResolve(dir, "no-such-branch")
+	assert.ErrorContains(t, err, "unknown revision")
+}
+
+func TestGitChanges(t *testing.T) {
+	dir := initGitRepo(t)
+	base, err := internal.GitResolve(dir, "HEAD")
+	require.NoError(t, err)
+
+	runGit(t, dir, "mv", "a.txt", "renamed.txt")
+	runGit(t, dir, "rm", "-q", "b.txt")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("gamma\n"), 0644))
+	runGit(t, dir, "add", "c.txt")
+	runGit(t, dir, "commit", "-q", "-m", "second")
+
+	changes, err := internal.GitChanges(dir, base, "HEAD")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []internal.GitChange{
+		{Status: 'R', Path: "renamed.txt", From: "a.txt"},
+		{Status: 'D', Path: "b.txt"},
+		{Status: 'A', Path: "c.txt"},
+	}, changes)
+
+	// Against the working tree
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("gamma\ndelta\n"), 0644))
+	changes, err = internal.GitChanges(dir, "HEAD", "")
+	require.NoError(t, err)
+	assert.Equal(t, []internal.GitChange{{Status: 'M', Path: "c.txt"}}, changes)
+}
+
+func TestGitChanges_Subdirectory(t *testing.T) {
+	dir := initGitRepo(t)
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "d.txt"), []byte("delta\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("beta\nbeta\n"), 0644))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "second")
+
+	// Paths are relative to the directory, changes outside it are left out
+	changes, err := internal.GitChanges(sub, "HEAD~1", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, []internal.GitChange{{Status: 'A', Path: "d.txt"}}, changes)
+}
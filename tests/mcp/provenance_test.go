@@ -0,0 +1,79 @@
+package mcp_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YoungY620/memo/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupProvenance creates an index with two modules, each derived from one
+// source file, and returns (workDir, indexDir)
+func setupProvenance(t *testing.T) (string, string) {
+	t.Helper()
+	workDir := t.TempDir()
+	indexDir := filepath.Join(workDir, ".memo", "index")
+	require.NoError(t, os.MkdirAll(indexDir, 0755))
+	arch := `{"modules": [{"name": "api", "description": "d", "interfaces": "i"}, {"name": "store", "description": "d", "interfaces": "i"}], "relationships": ""}`
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "arch.json"), []byte(arch), 0644))
+
+	var entries []string
+	for _, name := range []string{"api", "store"} {
+		content := "package " + name + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, name+".go"), []byte(content), 0644))
+		sum := sha256.Sum256([]byte(content))
+		entries = append(entries, fmt.Sprintf(`{"file": "arch.json", "field": "modules", "key": {"name": %q}, "sources": [{"path": "%s.go", "sha256": %q}], "analysed": "2026-01-01T00:00:00Z"}`,
+			name, name, hex.EncodeToString(sum[:])))
+	}
+	prov := `{"entries": [` + entries[0] + "," + entries[1] + `]}`
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, ".memo", "provenance.json"), []byte(prov), 0644))
+	return workDir, indexDir
+}
+
+func TestChangedSources(t *testing.T) {
+	workDir, indexDir := setupProvenance(t)
+
+	changed, err := mcp.ChangedSources(indexDir, workDir, "[arch][modules][1]")
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "store.go"), []byte("package store\n\nfunc Open() {}\n"), 0644))
+	for path, want := range map[string][]string{
+		"[arch][modules][0]":              nil,
+		"[arch][modules][1]":              {"store.go"},
+		"[arch][modules][1][description]": {"store.go"},
+		"[arch][modules]":                 {"store.go"},
+		"[arch]":                          {"store.go"},
+		"[interface]":                     nil,
+	} {
+		changed, err := mcp.ChangedSources(indexDir, workDir, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, nilIfEmpty(changed), path)
+	}
+
+	// Deleted sources count as changed
+	require.NoError(t, os.Remove(filepath.Join(workDir, "api.go")))
+	changed, err = mcp.ChangedSources(indexDir, workDir, "[arch][modules][0][name]")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.go"}, changed)
+}
+
+func TestChangedSources_NoProvenance(t *testing.T) {
+	indexDir := setupTestIndex(t)
+	changed, err := mcp.ChangedSources(indexDir, filepath.Dir(filepath.Dir(indexDir)), "[arch][modules][0]")
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
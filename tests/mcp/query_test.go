@@ -315,3 +315,21 @@ func BenchmarkParsePath(b *testing.B) {
 		}
 	}
 }
+
+func TestFiles_Custom(t *testing.T) {
+	indexDir := setupTestIndex(t)
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "runbooks.json"), []byte(`{"runbooks": [{"title": "Deploy"}]}`), 0644))
+
+	_, err := mcp.GetValue(indexDir, "[runbooks][runbooks][0][title]")
+	require.Error(t, err, "project-defined files are not queryable by default")
+
+	files := mcp.NewFiles([]mcp.File{{Name: "runbooks", Schema: `{"type": "object"}`}})
+	result, err := files.GetValue(indexDir, "[runbooks][runbooks][0][title]")
+	require.NoError(t, err)
+	assert.Equal(t, `"Deploy"`, result.Value)
+
+	_, _, err = files.ParsePath("[invalid][key]")
+	assert.ErrorContains(t, err, "runbooks")
+	_, err = mcp.GetValue(indexDir, "[runbooks][runbooks][0][title]")
+	assert.Error(t, err, "other queries are unaffected")
+}
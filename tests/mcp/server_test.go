@@ -56,7 +56,7 @@ func newTestServer(t *testing.T) (*testServer, string) {
 func newTestServerWithMCP(t *testing.T) (*mcp.Server, string) {
 	ts, workDir := newTestServer(t)
 	_ = ts
-	server := mcp.NewServer(workDir)
+	server := mcp.NewServer(workDir, nil)
 	t.Cleanup(func() { server.Close() })
 	return server, workDir
 }
@@ -217,7 +217,7 @@ func TestServe_NonExistentDir(t *testing.T) {
 	tmpDir := t.TempDir()
 	t.Cleanup(internal.CloseHistoryLogger)
 
-	server := mcp.NewServer(tmpDir)
+	server := mcp.NewServer(tmpDir, nil)
 	t.Cleanup(func() { server.Close() })
 
 	if server == nil {
@@ -267,7 +267,7 @@ func TestServer_GetStatus_Analyzing(t *testing.T) {
 	data, _ := json.Marshal(statusData)
 	require.NoError(t, os.WriteFile(filepath.Join(memoDir, "status.json"), data, 0644))
 
-	server := mcp.NewServer(workDir)
+	server := mcp.NewServer(workDir, nil)
 	t.Cleanup(func() { server.Close() })
 	status := server.GetStatusFromServer()
 
@@ -275,6 +275,22 @@ func TestServer_GetStatus_Analyzing(t *testing.T) {
 	assert.NotNil(t, status.Since)
 }
 
+func TestServer_GetStatus_Throttled(t *testing.T) {
+	_, workDir := newTestServer(t)
+
+	memoDir := filepath.Join(workDir, ".memo")
+	data := `{"status": "throttled", "throttle": {"reason": "hour token budget exhausted", "mode": "defer", "deferred": 3}}`
+	require.NoError(t, os.WriteFile(filepath.Join(memoDir, "status.json"), []byte(data), 0644))
+
+	server := mcp.NewServer(workDir, nil)
+	t.Cleanup(func() { server.Close() })
+	status := server.GetStatusFromServer()
+
+	assert.Equal(t, "throttled", status.Status)
+	require.NotNil(t, status.Throttle)
+	assert.Equal(t, 3, status.Throttle.Deferred)
+}
+
 func TestServer_GetStatus_InvalidJSON(t *testing.T) {
 	_, workDir := newTestServer(t)
 
@@ -282,7 +298,7 @@ func TestServer_GetStatus_InvalidJSON(t *testing.T) {
 	memoDir := filepath.Join(workDir, ".memo")
 	require.NoError(t, os.WriteFile(filepath.Join(memoDir, "status.json"), []byte("invalid json"), 0644))
 
-	server := mcp.NewServer(workDir)
+	server := mcp.NewServer(workDir, nil)
 	t.Cleanup(func() { server.Close() })
 	status := server.GetStatusFromServer()
 